@@ -0,0 +1,239 @@
+package maestro
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/midbel/maestro/internal/ignore"
+)
+
+type artifactCache struct {
+	mu     sync.Mutex
+	file   string
+	hashes map[string]string
+}
+
+func (c *artifactCache) use(file string) *artifactCache {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file != file {
+		c.file = file
+		c.hashes = nil
+	}
+	return c
+}
+
+func (c *artifactCache) get(name string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return "", false
+	}
+	h, ok := c.hashes[name]
+	return h, ok
+}
+
+func (c *artifactCache) set(name, hash string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return err
+	}
+	c.hashes[name] = hash
+	buf, err := json.MarshalIndent(c.hashes, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(c.file); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := c.file + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.file)
+}
+
+func (c *artifactCache) load() error {
+	if c.hashes != nil {
+		return nil
+	}
+	c.hashes = make(map[string]string)
+	buf, err := os.ReadFile(c.file)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return json.Unmarshal(buf, &c.hashes)
+}
+
+type artifacts struct {
+	Executer
+	cmd      CommandSettings
+	root     string
+	excludes *ignore.Matcher
+	cache    *artifactCache
+	force    bool
+	stderr   io.Writer
+}
+
+func artifactExecuter(cmd CommandSettings, ex Executer, m *Maestro) Executer {
+	if len(cmd.Sources) == 0 || len(cmd.Targets) == 0 {
+		return ex
+	}
+	a := artifacts{
+		Executer: ex,
+		cmd:      cmd,
+		root:     filepath.Dir(m.MetaAbout.File),
+		excludes: m.Excludes,
+		force:    m.Force,
+	}
+	if m.MetaExec.Cache != "" {
+		a.cache = m.artifacts.use(m.MetaExec.Cache)
+	}
+	return &a
+}
+
+func (a *artifacts) SetErr(w io.Writer) {
+	a.stderr = w
+	a.Executer.SetErr(w)
+}
+
+func (a *artifacts) Execute(ctx context.Context, args []string) error {
+	var (
+		ok  bool
+		err error
+	)
+	switch {
+	case a.cache != nil:
+		ok, err = a.unchanged(args)
+	case len(args) == 0:
+		// modification times can not tell with which arguments the targets
+		// were built: commands given arguments are always executed
+		ok, err = a.newer()
+	}
+	if err != nil {
+		return err
+	}
+	if ok && !a.force {
+		if a.stderr != nil {
+			fmt.Fprintf(a.stderr, "%s: up to date", a.cmd.Command())
+			fmt.Fprintln(a.stderr)
+		}
+		return nil
+	}
+	if err := a.Executer.Execute(ctx, args); err != nil {
+		return err
+	}
+	if a.cache == nil {
+		return nil
+	}
+	hash, err := a.hash(args)
+	if err != nil {
+		return err
+	}
+	return a.cache.set(a.cmd.Command(), hash)
+}
+
+func (a *artifacts) files(patterns []string) ([]string, error) {
+	list, err := a.excludes.Glob(a.root, patterns...)
+	if err == nil {
+		sort.Strings(list)
+	}
+	return list, err
+}
+
+func (a *artifacts) newer() (bool, error) {
+	sources, err := a.files(a.cmd.Sources)
+	if err != nil {
+		return false, err
+	}
+	targets, err := a.files(a.cmd.Targets)
+	if err != nil || len(targets) == 0 {
+		return false, err
+	}
+	oldest, err := modTime(targets, false)
+	if err != nil {
+		return false, err
+	}
+	latest, err := modTime(sources, true)
+	if err != nil {
+		return false, err
+	}
+	return oldest.After(latest), nil
+}
+
+func (a *artifacts) unchanged(args []string) (bool, error) {
+	targets, err := a.files(a.cmd.Targets)
+	if err != nil || len(targets) == 0 {
+		return false, err
+	}
+	hash, err := a.hash(args)
+	if err != nil {
+		return false, err
+	}
+	prev, ok := a.cache.get(a.cmd.Command())
+	return ok && prev == hash, nil
+}
+
+func (a *artifacts) hash(args []string) (string, error) {
+	sources, err := a.files(a.cmd.Sources)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.New()
+	for _, arg := range args {
+		io.WriteString(sum, arg)
+		sum.Write([]byte{0})
+	}
+	for _, s := range sources {
+		if fi, err := os.Stat(s); err != nil {
+			return "", err
+		} else if fi.IsDir() {
+			continue
+		}
+		r, err := os.Open(s)
+		if err != nil {
+			return "", err
+		}
+		rel, _ := filepath.Rel(a.root, s)
+		io.WriteString(sum, filepath.ToSlash(rel))
+		sum.Write([]byte{0})
+		_, err = io.Copy(sum, r)
+		r.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+func modTime(files []string, latest bool) (time.Time, error) {
+	var when time.Time
+	for i, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return when, err
+		}
+		mod := fi.ModTime()
+		if i == 0 || (latest && mod.After(when)) || (!latest && mod.Before(when)) {
+			when = mod
+		}
+	}
+	return when, nil
+}
@@ -0,0 +1,158 @@
+package maestro_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/midbel/maestro"
+)
+
+func TestUpToDate(t *testing.T) {
+	var (
+		dir    = t.TempDir()
+		source = filepath.Join(dir, "src", "main.txt")
+		target = filepath.Join(dir, "bin", "app")
+	)
+	for _, f := range []string{source, target} {
+		if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte("original"), 0644); err != nil {
+			t.Fatalf("fail to write %s: %s", f, err)
+		}
+	}
+	mst, err := maestro.Decode(strings.NewReader(fmt.Sprintf(artifacts, dir)))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	mst.MetaAbout.File = filepath.Join(dir, maestro.DefaultFile)
+
+	var (
+		out  bytes.Buffer
+		errs bytes.Buffer
+		now  = time.Now()
+	)
+	mst.Stdout, mst.Stderr = &out, &errs
+
+	chtimes := func(file string, when time.Time) {
+		t.Helper()
+		if err := os.Chtimes(file, when, when); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chtimes(source, now.Add(-time.Hour))
+	chtimes(target, now)
+	if err := mst.Execute("build", nil); err != nil {
+		t.Fatalf("fail to execute: %s", err)
+	}
+	if got := errs.String(); !strings.Contains(got, "build: up to date") {
+		t.Errorf("build should be up to date, got %q", got)
+	}
+	if b, _ := os.ReadFile(target); string(b) != "original" {
+		t.Errorf("target should not be rebuilt, got %q", b)
+	}
+
+	errs.Reset()
+	chtimes(source, now.Add(time.Hour))
+	if err := mst.Execute("build", nil); err != nil {
+		t.Fatalf("fail to execute: %s", err)
+	}
+	if got := errs.String(); strings.Contains(got, "up to date") {
+		t.Errorf("build should not be up to date, got %q", got)
+	}
+	if b, _ := os.ReadFile(target); strings.TrimSpace(string(b)) != "built" {
+		t.Errorf("target should be rebuilt, got %q", b)
+	}
+
+	errs.Reset()
+	chtimes(source, now.Add(-time.Hour))
+	if err := mst.Execute("build", []string{"linux"}); err != nil {
+		t.Fatalf("fail to execute: %s", err)
+	}
+	if got := errs.String(); strings.Contains(got, "up to date") {
+		t.Errorf("build with arguments should not be up to date, got %q", got)
+	}
+	if b, _ := os.ReadFile(target); strings.TrimSpace(string(b)) != "built linux" {
+		t.Errorf("target should be rebuilt, got %q", b)
+	}
+}
+
+func TestUpToDateCache(t *testing.T) {
+	var (
+		dir    = t.TempDir()
+		source = filepath.Join(dir, "src", "main.txt")
+		target = filepath.Join(dir, "bin", "app")
+	)
+	for _, d := range []string{filepath.Join(dir, "assets"), filepath.Dir(source), filepath.Dir(target)} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(source, []byte("original"), 0644); err != nil {
+		t.Fatalf("fail to write %s: %s", source, err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "assets"), filepath.Join(dir, "src", "assets")); err != nil {
+		t.Fatal(err)
+	}
+	mst, err := maestro.Decode(strings.NewReader(fmt.Sprintf(cached, dir)))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	mst.MetaAbout.File = filepath.Join(dir, maestro.DefaultFile)
+	mst.MetaExec.Cache = filepath.Join(dir, ".maestro", "cache.json")
+
+	var (
+		out  bytes.Buffer
+		errs bytes.Buffer
+	)
+	mst.Stdout, mst.Stderr = &out, &errs
+
+	tests := []struct {
+		Args   []string
+		Skip   bool
+		Target string
+	}{
+		{Args: []string{"linux"}, Target: "built linux"},
+		{Args: []string{"linux"}, Skip: true, Target: "built linux"},
+		{Args: []string{"windows"}, Target: "built windows"},
+		{Args: []string{"windows"}, Skip: true, Target: "built windows"},
+		{Target: "built"},
+	}
+	for _, tt := range tests {
+		errs.Reset()
+		if err := mst.Execute("build", tt.Args); err != nil {
+			t.Fatalf("%v: fail to execute: %s", tt.Args, err)
+		}
+		if got := strings.Contains(errs.String(), "build: up to date"); got != tt.Skip {
+			t.Errorf("%v: up to date mismatched! want %t, got %t", tt.Args, tt.Skip, got)
+		}
+		if b, _ := os.ReadFile(target); strings.TrimSpace(string(b)) != tt.Target {
+			t.Errorf("%v: target mismatched! want %q, got %q", tt.Args, tt.Target, b)
+		}
+	}
+}
+
+const artifacts = `
+build(
+	sources = "src/*.txt",
+	targets = bin/app,
+	workdir = %s,
+): {
+	echo built $@ > bin/app
+}
+`
+
+const cached = `
+build(
+	sources = "src/*",
+	targets = bin/app,
+	workdir = %s,
+): {
+	echo built $@ > bin/app
+}
+`
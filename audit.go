@@ -0,0 +1,143 @@
+package maestro
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const httpHdrForwarded = "X-Forwarded-For"
+
+const (
+	auditHttp = "http"
+	auditSSH  = "ssh"
+
+	auditSyslog = "syslog"
+)
+
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	Source    string    `json:"source"`
+	Remote    string    `json:"remote"`
+	Principal string    `json:"principal,omitempty"`
+	Command   string    `json:"command"`
+	Args      []string  `json:"args,omitempty"`
+	Result    string    `json:"result"`
+	Error     string    `json:"error,omitempty"`
+	Elapsed   float64   `json:"elapsed"`
+}
+
+var auditMu sync.Mutex
+
+func createEntryAudit(source, remote, principal, name string, args []string, start time.Time, err error) AuditEntry {
+	e := AuditEntry{
+		Time:      start,
+		Source:    source,
+		Remote:    remote,
+		Principal: principal,
+		Command:   name,
+		Args:      args,
+		Result:    "ok",
+		Elapsed:   time.Since(start).Seconds(),
+	}
+	if err != nil {
+		e.Result = "failed"
+		e.Error = err.Error()
+	}
+	return e
+}
+
+func (m *Maestro) audit(e AuditEntry) {
+	if m.MetaExec.Audit == "" {
+		return
+	}
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	var err error
+	if m.MetaExec.Audit == auditSyslog {
+		err = writeSyslog(e)
+	} else {
+		err = appendAudit(m.MetaExec.Audit, e)
+	}
+	if err != nil {
+		fmt.Fprintf(m.Stderr, "audit: %s", err)
+		fmt.Fprintln(m.Stderr)
+	}
+}
+
+func appendAudit(file string, e AuditEntry) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	w, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	return json.NewEncoder(w).Encode(e)
+}
+
+func requestRemote(r *http.Request, proxies []string) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !trustedProxy(host, proxies) {
+		return host
+	}
+	list := strings.Split(r.Header.Get(httpHdrForwarded), ",")
+	for i := len(list) - 1; i >= 0; i-- {
+		str := strings.TrimSpace(list[i])
+		if str == "" {
+			break
+		}
+		host = str
+		if !trustedProxy(host, proxies) {
+			break
+		}
+	}
+	return host
+}
+
+func trustedProxy(host string, proxies []string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, p := range proxies {
+		if _, cidr, err := net.ParseCIDR(p); err == nil {
+			if cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if other := net.ParseIP(p); other != nil && other.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkProxies(proxies []string) error {
+	for _, p := range proxies {
+		if _, _, err := net.ParseCIDR(p); err == nil || net.ParseIP(p) != nil {
+			continue
+		}
+		return fmt.Errorf("%s: invalid proxy address", p)
+	}
+	return nil
+}
+
+func webhookPrincipal(r *http.Request) string {
+	if r.Header.Get(httpHdrGitlabToken) != "" {
+		return "webhook:gitlab"
+	}
+	return "webhook:github"
+}
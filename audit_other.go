@@ -0,0 +1,11 @@
+//go:build windows || plan9
+
+package maestro
+
+import (
+	"fmt"
+)
+
+func writeSyslog(e AuditEntry) error {
+	return fmt.Errorf("syslog not supported on this platform")
+}
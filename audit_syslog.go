@@ -0,0 +1,21 @@
+//go:build !windows && !plan9
+
+package maestro
+
+import (
+	"encoding/json"
+	"log/syslog"
+)
+
+func writeSyslog(e AuditEntry) error {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_AUTH, "maestro")
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return w.Info(string(buf))
+}
@@ -0,0 +1,90 @@
+package maestro
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	httpHdrAuth  = "Authorization"
+	bearerPrefix = "Bearer "
+)
+
+var errUnauthenticated = errors.New("missing or invalid token")
+
+type HttpToken struct {
+	Name     string
+	Token    string
+	Commands []string
+	Tags     []string
+	View     bool
+}
+
+func (t HttpToken) Allow(cmd CommandSettings) bool {
+	if t.View {
+		return false
+	}
+	if len(t.Commands) == 0 && len(t.Tags) == 0 {
+		return true
+	}
+	name := cmd.Command()
+	for _, pat := range t.Commands {
+		if ok, _ := filepath.Match(pat, name); ok {
+			return true
+		}
+	}
+	for _, tag := range cmd.Tags() {
+		for _, want := range t.Tags {
+			if tag == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (m *Maestro) authenticate(r *http.Request) (HttpToken, error) {
+	var zero HttpToken
+	if len(m.MetaHttp.Tokens) == 0 {
+		return zero, nil
+	}
+	str := r.Header.Get(httpHdrAuth)
+	if !strings.HasPrefix(str, bearerPrefix) {
+		return zero, errUnauthenticated
+	}
+	str = strings.TrimSpace(strings.TrimPrefix(str, bearerPrefix))
+	for _, t := range m.MetaHttp.Tokens {
+		secret, err := resolveSecret(t.Token)
+		if err != nil || secret == "" {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(str), []byte(secret)) == 1 {
+			return t, nil
+		}
+	}
+	return zero, errUnauthenticated
+}
+
+func (m *Maestro) authorize(r *http.Request, cmd CommandSettings) (string, error) {
+	if len(m.MetaHttp.Tokens) == 0 {
+		return "", nil
+	}
+	tok, err := m.authenticate(r)
+	if err != nil {
+		return "", err
+	}
+	principal := "token:" + tok.Name
+	if err := m.evaluate(); err != nil {
+		return principal, err
+	}
+	if c, ok := m.Commands.Get(cmd.Command()); ok {
+		cmd = c
+	}
+	if !tok.Allow(cmd) {
+		return principal, errForbidden
+	}
+	return principal, nil
+}
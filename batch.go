@@ -0,0 +1,99 @@
+package maestro
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/midbel/shlex"
+	"golang.org/x/sync/semaphore"
+)
+
+func (m *Maestro) Batch(args []string) error {
+	var (
+		set  = flag.NewFlagSet(CmdBatch, flag.ContinueOnError)
+		jobs = set.Int64("j", 1, "number of commands executed in parallel")
+	)
+	if err := parseFlags(set, args); err != nil {
+		return err
+	}
+	if *jobs <= 0 {
+		*jobs = 1
+	}
+	return m.batch(os.Stdin, *jobs)
+}
+
+func (m *Maestro) batch(r io.Reader, jobs int64) error {
+	var (
+		ctx    = interruptContext()
+		sema   = semaphore.NewWeighted(jobs)
+		scan   = bufio.NewScanner(r)
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		line   int
+		total  int
+		failed int
+	)
+	for scan.Scan() {
+		line++
+		str := strings.TrimSpace(scan.Text())
+		if str == "" || strings.HasPrefix(str, "#") {
+			continue
+		}
+		list, err := shlex.Split(strings.NewReader(str))
+		if err != nil || len(list) == 0 {
+			batchStatus(m.Stdout, line, str, 0, fmt.Errorf("invalid line"))
+			mu.Lock()
+			failed++
+			mu.Unlock()
+			total++
+			continue
+		}
+		if err := sema.Acquire(ctx, 1); err != nil {
+			break
+		}
+		total++
+		wg.Add(1)
+		go func(line int, name string, args []string) {
+			defer func() {
+				sema.Release(1)
+				wg.Done()
+			}()
+			var (
+				now = time.Now()
+				err = m.executeContext(ctx, name, args, m.Stdout, m.Stderr)
+			)
+			batchStatus(m.Stdout, line, name, time.Since(now), err)
+			if err != nil {
+				mu.Lock()
+				failed++
+				mu.Unlock()
+			}
+		}(line, list[0], list[1:])
+	}
+	wg.Wait()
+	if err := scan.Err(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d/%d command(s) failed", failed, total)
+	}
+	return nil
+}
+
+func batchStatus(w io.Writer, line int, name string, elapsed time.Duration, err error) {
+	status := "ok"
+	if err != nil {
+		status = fmt.Sprintf("failed: %s", err)
+	}
+	fmt.Fprintf(w, "batch: line %d: %s: %s (%.3fs)", line, name, status, elapsed.Seconds())
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,123 @@
+package maestro
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	blackoutDate  = "2006-01-02"
+	blackoutRange = ".."
+)
+
+var blackoutDays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+type blackoutWindow struct {
+	spec string
+	days [7]bool
+	from time.Time
+	to   time.Time
+}
+
+func (b blackoutWindow) contains(when time.Time) bool {
+	if b.from.IsZero() {
+		return b.days[when.Weekday()]
+	}
+	when = time.Date(when.Year(), when.Month(), when.Day(), 0, 0, 0, 0, time.Local)
+	return !when.Before(b.from) && !when.After(b.to)
+}
+
+type Blackout []blackoutWindow
+
+func parseBlackout(list []string) (Blackout, error) {
+	var b Blackout
+	for _, str := range list {
+		w, err := parseBlackoutWindow(str)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, w)
+	}
+	return b, nil
+}
+
+func (b Blackout) Active(when time.Time) (string, bool) {
+	for _, w := range b {
+		if w.contains(when) {
+			return w.spec, true
+		}
+	}
+	return "", false
+}
+
+func (b Blackout) merge(other Blackout) Blackout {
+	if len(other) == 0 {
+		return b
+	}
+	list := make(Blackout, 0, len(b)+len(other))
+	list = append(list, b...)
+	return append(list, other...)
+}
+
+func parseBlackoutWindow(str string) (blackoutWindow, error) {
+	w := blackoutWindow{
+		spec: str,
+	}
+	if from, to, ok := strings.Cut(str, blackoutRange); ok {
+		var err error
+		if w.from, err = parseBlackoutDate(from); err != nil {
+			return w, err
+		}
+		if w.to, err = parseBlackoutDate(to); err != nil {
+			return w, err
+		}
+		if w.to.Before(w.from) {
+			return w, fmt.Errorf("%s: end of blackout window before its start", str)
+		}
+		return w, nil
+	}
+	if when, err := parseBlackoutDate(str); err == nil {
+		w.from, w.to = when, when
+		return w, nil
+	}
+	for _, d := range strings.Split(str, ",") {
+		from, to, ok := strings.Cut(d, "-")
+		if !ok {
+			to = from
+		}
+		beg, err := parseBlackoutDay(from)
+		if err != nil {
+			return w, err
+		}
+		end, err := parseBlackoutDay(to)
+		if err != nil {
+			return w, err
+		}
+		for i := beg; ; i = (i + 1) % len(w.days) {
+			w.days[i] = true
+			if i == end {
+				break
+			}
+		}
+	}
+	return w, nil
+}
+
+func parseBlackoutDate(str string) (time.Time, error) {
+	when, err := time.ParseInLocation(blackoutDate, strings.TrimSpace(str), time.Local)
+	if err != nil {
+		return when, fmt.Errorf("%s: invalid date (YYYY-MM-DD expected)", str)
+	}
+	return when, nil
+}
+
+func parseBlackoutDay(str string) (int, error) {
+	str = strings.ToLower(strings.TrimSpace(str))
+	for i, d := range blackoutDays {
+		if strings.HasPrefix(str, d) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%s: invalid day of week", str)
+}
@@ -0,0 +1,89 @@
+package maestro
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type costItem struct {
+	Name string
+	Cost float64
+}
+
+func (m *Maestro) checkBudget(name string, option ctreeOption) error {
+	if m.Budget <= 0 || m.MetaExec.Dry {
+		return nil
+	}
+	items, err := m.costs(name, option)
+	if err != nil {
+		return err
+	}
+	var (
+		total float64
+		width = len("total")
+	)
+	for _, i := range items {
+		total += i.Cost
+		if n := len(i.Name); n > width {
+			width = n
+		}
+	}
+	if total <= m.Budget {
+		return nil
+	}
+	fmt.Fprintf(m.Stderr, "cost of %s:", name)
+	fmt.Fprintln(m.Stderr)
+	for _, i := range items {
+		fmt.Fprintf(m.Stderr, "  %-*s  %s", width, i.Name, formatCost(i.Cost))
+		fmt.Fprintln(m.Stderr)
+	}
+	fmt.Fprintf(m.Stderr, "  %-*s  %s", width, "total", formatCost(total))
+	fmt.Fprintln(m.Stderr)
+	return fmt.Errorf("%s: cost %s exceeds budget %s: %w", name, formatCost(total), formatCost(m.Budget), errForbidden)
+}
+
+func (m *Maestro) costs(name string, option ctreeOption) ([]costItem, error) {
+	var items []costItem
+	add := func(cmd CommandSettings) {
+		if cmd.Cost > 0 {
+			items = append(items, costItem{Name: cmd.Command(), Cost: cmd.Cost})
+		}
+	}
+	cmd, err := m.Commands.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	hooks := func(names []string) error {
+		for _, n := range names {
+			c, err := m.Commands.Lookup(n)
+			if err != nil {
+				return err
+			}
+			add(c)
+		}
+		return nil
+	}
+	if err := hooks(m.Before); err != nil {
+		return nil, err
+	}
+	if !option.NoDeps {
+		deps, err := m.walkDependencies(cmd.Deps, option)
+		if err != nil {
+			return nil, err
+		}
+		deps.walk(func(n depNode) {
+			if n.Reason == "" && n.Selected {
+				add(n.Cmd)
+			}
+		})
+	}
+	add(cmd)
+	if err := hooks(m.After); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func formatCost(cost float64) string {
+	return strconv.FormatFloat(cost, 'f', -1, 64)
+}
@@ -0,0 +1,60 @@
+package maestro_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/midbel/maestro"
+)
+
+func TestBudget(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(budgeted))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	tests := []struct {
+		Budget float64
+		NoDeps bool
+		Err    string
+	}{
+		{Budget: 0},
+		{Budget: 15},
+		{Budget: 10, Err: "cost 12.5 exceeds budget 10"},
+		{Budget: 10, NoDeps: true},
+	}
+	var (
+		buf  bytes.Buffer
+		errs bytes.Buffer
+	)
+	mst.Stdout, mst.Stderr = &buf, &errs
+	for _, tt := range tests {
+		buf.Reset()
+		mst.Budget, mst.NoDeps = tt.Budget, tt.NoDeps
+
+		err := mst.Execute("deploy", nil)
+		if tt.Err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.Err) {
+				t.Errorf("budget %.1f: expected error %q, got %v", tt.Budget, tt.Err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("budget %.1f: unexpected error: %s", tt.Budget, err)
+			continue
+		}
+		if got := buf.String(); !strings.Contains(got, "deployed") {
+			t.Errorf("budget %.1f: deploy not executed: %s", tt.Budget, got)
+		}
+	}
+}
+
+const budgeted = `
+build(cost = 2.5): {
+	echo built
+}
+
+deploy(cost = 10): build {
+	echo deployed
+}
+`
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -26,8 +27,9 @@ performed regularly in a project whatever its nature. It could be the
 development of a program, administration of a single server or a set of
 virtual machines,...
 
-To do that, maestro needs only a single file, by default called maestro.mf,
-and make all the commands available in the file as sub commands of itself.
+To do that, maestro needs only a single file, by default called maestro.mf
+(or maestro.yaml when written in YAML), and make all the commands available
+in the file as sub commands of itself.
 
 Moreover, to make the file and its commands easier to use, maestro creates
 a help message for the input maestro file and foreach of commands defined
@@ -35,6 +37,9 @@ in it.
 
 maestro makes available some default sub commands:
 
+batch:    read commands (one per line with its arguments) from stdin and execute
+          them sequentially or, with -j N, N at a time. The status of each
+          line is printed once its command is done
 default:  same as calling maestro without arguments, it will call the command
           configured with the meta DEFAULT
 all:      call all the commands defined in the meta ALL in order
@@ -43,50 +48,151 @@ help:     without arguments, maestro will print a help message generated from
 				  command
 version:  print the version of the maestro file defined via the meta VERSION
           and exit
-listen:   run a HTTP server and execute command from the name available in the
-          last element of the URL
+listen:   run a HTTP server exposing the commands under /commands/<name>. The
+          output of the command is streamed back to the client. TLS is used
+          when the metas HTTP_CERT_FILE and HTTP_CERT_KEY are set. /healthz
+          and /readyz can be used as liveness and readiness probes. With -s,
+          the scheduled commands are executed alongside the server
+graph:    print the dependency tree of a command (the DEFAULT command if none
+          is given). With --full, the hooks (BEFORE, AFTER, ERROR, SUCCESS)
+          and the schedules attached to each command are also shown. With
+          --format dot or --format json, the dependency graph is printed as
+          Graphviz DOT or JSON
+deps:     execute only the dependencies of a command. With --only direct,
+          the dependencies of the dependencies are not executed. With
+          --with-root (or --skip-root=false), the command is executed after
+          its dependencies
+run:      execute a named run defined with the runs instruction, that is a
+          command with its preset options and arguments. Additional arguments
+          are appended to the preset ones. Without name, list the named runs
+lint:     check the maestro file for dependency cycles, unknown dependencies,
+          metas referencing unknown commands, undefined variables used in
+          scripts and unused options. All problems found are printed
+encrypt:  encrypt the given values (or the lines read from stdin) with age
+          for the recipients given with -r (or for the identities of
+          MAESTRO_AGE_KEY or of the file MAESTRO_AGE_KEY_FILE). The output
+          can be used as the value of variables in the maestro file
+schema:   print the JSON schema of the maestro file format (metas, command
+          properties, options, schedules,...) to be used by editors and
+          external validators
+diff:     compare two maestro files (or the given one with the current one)
+          and print the commands added, removed or changed with their
+          changed dependencies, options, arguments, schedules and scripts
+explain:  print what executing a command would do without executing it: the
+          variables referenced by its script and where they come from, the
+          hooks, its dependencies in order (with the reason of the skipped
+          ones), its hosts, schedules and settings, and the expanded scripts
+completion: print the completion script for bash, zsh or fish of the
+          visible commands of the maestro file, their aliases and options.
+          The values of the options checked with oneof are proposed
+which-file: print the maestro file used and why it was picked: given with
+          -f, MAESTRO_FILE, found in the current directory (maestro.mf,
+          maestro.yaml then .maestro/maestro.mf) or in a parent directory
+order:    print the command and its dependencies in the order they are
+          executed, one (namespaced) name per line. Designed to be piped to
+          other tools
 schedule: run commands that have a schedule property set properly at the given
-          interval of time
+          interval of time. Use -t to only consider commands with a given tag,
+          -l to list the next runs, -d to print what would be run without
+          running it and -j to print the upcoming runs as JSON. An interrupt
+          stops all the schedulers once their running commands are done or
+          cancelled after the delay set via the meta SCHEDULE_GRACE
+stats:    print statistics (runs, failures, average duration) of the commands
+          recorded in the history file set via the meta HISTORY. Nothing is
+          ever sent over the network
+log:      print the last runs (most recent first) recorded in the history
+          file, optionally of the given command only. Use -n to limit the
+          number of runs shown and -f to only show the failed ones. With a
+          command, its last failure and average duration are also printed
+test:     run the commands tagged with test (or test:<name>) and check their
+          results against the expect property. A report is printed in TAP
+          (default) or JUnit XML format. If the maestro file defines its own
+          test command, this one is executed instead
+export:   convert the commands and their dependencies into a GitHub Actions
+          workflow or a GitLab CI pipeline (--format github|gitlab). Each job
+          calls maestro for one command and the dependencies are kept via
+          needs and stages. With --format schtasks, the schedules of the
+          commands are converted into a PowerShell script registering them
+          in the Windows Task Scheduler and, with --format launchd, into
+          launchd plists. With --format shell, each command is written as a
+          standalone POSIX shell script in the directory given by -o (bin by
+          default). -dir <directory> is a shortcut for --format shell -o
+          <directory>
+entrypoint: run maestro as the entrypoint of a container. The command to
+          execute is read from MAESTRO_CMD, the given arguments or the meta
+          DEFAULT. Signals are forwarded to the child processes and, when
+          running as PID 1, orphaned processes are reaped. With -s, the
+          scheduled commands are run as sidecars
 
 Options:
 
+  --budget N                              refuse to execute a command when the summed cost of the
+                                          commands to execute exceeds N
+  --config FILE                           read machine specific metas from the TOML FILE (default:
+                                          the maestro file with the .toml extension, if it exists)
   -d, --dry                               only print commands that will be executed
+                                          and the changes since the last run
   -D NAME[=VALUE], --define NAME[=VALUE]  define NAME with optional value
-  -f FILE, --file FILE                    read FILE as a maestro file
+  -f FILE, --file FILE                    read FILE as a maestro file (default: MAESTRO_FILE or the
+                                          first maestro file found, see which-file)
+  --force                                 execute commands even if their targets are up to date
+                                          or during one of their blackout windows
+  --github                                group output and annotate failed commands for GitHub Actions
   -i, --ignore                            ignore all errors from command
+  --inject-failure NAME[:ATTEMPT]         make NAME fail without executing it, on every attempt or
+                                          only on the given ATTEMPT (repeatable). For testing retries,
+                                          error hooks and notifications
   -I DIR, --includes DIR                  search DIR for included maestro files
   -k, --skip                              don't execute command's dependencies
+  --skip-dep PATTERN                      don't execute the dependencies matching PATTERN (repeatable)
+  --dep NAME:ARGS                         append ARGS to the arguments given to the dependency NAME (repeatable)
+  --only-dep PATTERN                      only execute the dependencies matching PATTERN (repeatable)
   -p, --with-prefix                       prefix each output line with the name of the command
-  -r, --remote                            execute commands on remote server
+  -r, --remote[=auto]                     execute commands on remote server. With auto, commands
+                                          without hosts are executed locally
+  --report FORMAT=FILE                    write a report (junit, tap) of the executed commands to FILE
   -t, --trace                             add tracing information with command execution
   -v, --version                           print maestro version and exit
+  -y, --yes                               execute the commands having the confirm property without asking
 `
 
 func main() {
+	if err := maestro.ExecHardened(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(126)
+	}
 	flag.Usage = func() {
 		fmt.Fprintln(os.Stderr, help)
 		os.Exit(2)
 	}
 	var (
-		file    = maestro.DefaultFile
+		file    string
 		mst     = maestro.New()
 		version bool
 	)
-	if str, ok := os.LookupEnv(MaestroEnv); ok && str != "" {
-		file = str
-	}
+	mst.Github = os.Getenv("GITHUB_ACTIONS") == "true"
 
 	options := []Option{
 		{Short: "I", Long: "includes", Desc: "search include files in directories", Ptr: &mst.Includes},
 		{Short: "d", Long: "dry", Desc: "only print commands that will be executed", Ptr: &mst.MetaExec.Dry},
 		{Short: "i", Long: "ignore", Desc: "ignore errors from command", Ptr: &mst.MetaExec.Ignore},
 		{Short: "f", Long: "file", Desc: "read file as maestro file", Ptr: &file},
+		{Long: "config", Desc: "read metas from TOML file (default: maestro.toml next to the maestro file)", Ptr: &mst.Config},
 		{Short: "k", Long: "skip", Desc: "skip command dependencies", Ptr: &mst.NoDeps},
+		{Long: "force", Desc: "execute commands even if their targets are up to date or during a blackout window", Ptr: &mst.Force},
+		{Short: "y", Long: "yes", Desc: "execute commands requiring a confirmation without asking", Ptr: &mst.Yes},
+		{Long: "budget", Desc: "maximum cost of the commands to execute", Ptr: &mst.Budget},
 		{Short: "r", Long: "remote", Desc: "execute command on remote server(s)", Ptr: &mst.Remote},
 		{Short: "t", Long: "trace", Desc: "add tracing information command execution", Ptr: &mst.MetaExec.Trace},
 		{Short: "v", Long: "version", Desc: "print maestro version and exit", Ptr: &version},
 		{Short: "D", Long: "define", Desc: "set variables", Ptr: &mst.Locals},
 		{Short: "p", Long: "with-prefix", Desc: "add a prefix to each output line", Ptr: &mst.WithPrefix},
+		{Long: "skip-dep", Desc: "skip dependencies matching pattern", Ptr: &mst.SkipDeps},
+		{Long: "only-dep", Desc: "only execute dependencies matching pattern", Ptr: &mst.OnlyDeps},
+		{Long: "dep", Desc: "give additional arguments to a dependency", Ptr: &mst.DepArgs},
+		{Long: "inject-failure", Desc: "make a command fail (on the given attempt)", Ptr: &mst.Failures},
+		{Long: "report", Desc: "write a report of the executed commands", Ptr: &mst.Report},
+		{Long: "github", Desc: "emit GitHub Actions annotations", Ptr: &mst.Github},
 	}
 
 	parseArgs(options)
@@ -97,11 +203,24 @@ func main() {
 		return
 	}
 
+	mst.Location = maestro.Locate(file, os.Getenv(MaestroEnv))
+	file = mst.Location.File
+	if cmd, args := arguments(); cmd == maestro.CmdWhichFile {
+		exit(mst.WhichFile(args), file)
+		return
+	}
 	err := mst.Load(file)
 	if err != nil {
 		exit(err, file)
 	}
-	switch cmd, args := arguments(); cmd {
+	cmd, args := arguments()
+	mst.Globals = os.Args[1 : len(os.Args)-flag.NArg()]
+	if cmd != maestro.CmdLint {
+		if err := mst.Validate(); err != nil {
+			exit(err, file)
+		}
+	}
+	switch cmd {
 	case maestro.CmdListen, maestro.CmdServe:
 		err = mst.ListenAndServe(args)
 	case maestro.CmdHelp:
@@ -117,11 +236,42 @@ func main() {
 		err = mst.ExecuteDefault(args)
 	case maestro.CmdSchedule:
 		err = mst.Schedule(args)
-	case maestro.CmdGraph:
-		if len(args) > 0 {
-			cmd = args[0]
+	case maestro.CmdStats:
+		err = mst.Stats(args)
+	case maestro.CmdTest:
+		if _, ok := mst.Commands.Get(cmd); ok {
+			err = mst.Execute(cmd, args)
+			break
 		}
-		err = mst.Graph(cmd)
+		err = mst.Test(args)
+	case maestro.CmdExport:
+		err = mst.Export(args)
+	case maestro.CmdEntrypoint:
+		err = mst.Entrypoint(args)
+	case maestro.CmdOrder:
+		err = mst.Order(args)
+	case maestro.CmdDeps:
+		err = mst.Deps(args)
+	case maestro.CmdBatch:
+		err = mst.Batch(args)
+	case maestro.CmdLint:
+		err = mst.Lint(args)
+	case maestro.CmdRun:
+		err = mst.Run(args)
+	case maestro.CmdEncrypt:
+		err = mst.Encrypt(args)
+	case maestro.CmdLog:
+		err = mst.Log(args)
+	case maestro.CmdSchema:
+		err = mst.Schema(args)
+	case maestro.CmdDiff:
+		err = mst.Diff(args)
+	case maestro.CmdExplain:
+		err = mst.Explain(args)
+	case maestro.CmdCompletion:
+		err = mst.Completion(args)
+	case maestro.CmdGraph:
+		err = mst.Graph(args)
 	default:
 		err = mst.Execute(cmd, args)
 	}
@@ -132,6 +282,22 @@ func exit(err error, file string) {
 	if err == nil {
 		return
 	}
+	var usage maestro.UsageError
+	if errors.As(err, &usage) {
+		if errors.Is(usage.Err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stdout, usage.Usage)
+			return
+		}
+		fmt.Fprintln(os.Stderr, usage)
+		var suggest maestro.SuggestionError
+		if errors.As(usage.Err, &suggest) {
+			sort.Strings(suggest.Others)
+			fmt.Fprintf(os.Stderr, "similar option(s): %s", strings.Join(suggest.Others, ", "))
+			fmt.Fprintln(os.Stderr)
+		}
+		fmt.Fprintln(os.Stderr, usage.Usage)
+		os.Exit(2)
+	}
 	switch err := err.(type) {
 	case maestro.SuggestionError:
 		printSuggestion(err)
@@ -226,6 +392,13 @@ func parseArgs(options []Option) {
 			if o.Long != "" {
 				flag.BoolVar(v, o.Long, *v, o.Desc)
 			}
+		case *float64:
+			if o.Short != "" {
+				flag.Float64Var(v, o.Short, *v, o.Desc)
+			}
+			if o.Long != "" {
+				flag.Float64Var(v, o.Long, *v, o.Desc)
+			}
 		default:
 		}
 	}
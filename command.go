@@ -1,6 +1,7 @@
 package maestro
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"errors"
@@ -8,12 +9,19 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/midbel/distance"
 	"github.com/midbel/maestro/internal/env"
 	"github.com/midbel/maestro/internal/help"
+	"github.com/midbel/maestro/internal/ordered"
 	"github.com/midbel/tish"
 )
 
@@ -38,13 +46,22 @@ type CommandDep struct {
 	Bg        bool
 	Optional  bool
 	Mandatory bool
+
+	root bool
 }
 
 func (c CommandDep) Key() string {
-	if c.Space == "" {
-		return c.Name
+	return joinSpace(c.Space, c.Name)
+}
+
+func joinSpace(space, name string) string {
+	if space == "" {
+		return name
 	}
-	return fmt.Sprintf("%s::%s", c.Space, c.Name)
+	if name == "" {
+		return space
+	}
+	return fmt.Sprintf("%s::%s", space, name)
 }
 
 type CommandOption struct {
@@ -53,11 +70,16 @@ type CommandOption struct {
 	Help     string
 	Required bool
 	Flag     bool
+	List     bool
+
+	Exclusive string
+	Choices   []string
 
 	Default     string
 	DefaultFlag bool
 	Target      string
 	TargetFlag  bool
+	TargetList  []string
 
 	Valid ValidateFunc
 }
@@ -66,6 +88,9 @@ func (o CommandOption) Validate() error {
 	if o.Flag {
 		return nil
 	}
+	if o.List {
+		return o.validateList()
+	}
 	if o.Required && o.Target == "" {
 		return fmt.Errorf("%s/%s: missing value", o.Short, o.Long)
 	}
@@ -75,16 +100,182 @@ func (o CommandOption) Validate() error {
 	return o.Valid(o.Target)
 }
 
+func (o CommandOption) validateList() error {
+	if o.Required && len(o.TargetList) == 0 {
+		return fmt.Errorf("%s/%s: missing value", o.Short, o.Long)
+	}
+	if o.Valid == nil {
+		return nil
+	}
+	for _, v := range o.TargetList {
+		if err := o.Valid(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func optionUsage(o CommandOption) string {
+	var str strings.Builder
+	if o.Short != "" {
+		str.WriteString("-")
+		str.WriteString(o.Short)
+	}
+	if o.Short != "" && o.Long != "" {
+		str.WriteString("/")
+	}
+	if o.Long != "" {
+		str.WriteString("--")
+		str.WriteString(o.Long)
+	}
+	return str.String()
+}
+
+func checkExclusive(options []CommandOption, set *flag.FlagSet) error {
+	var (
+		names  = make(map[string]int)
+		groups = make(map[string]int)
+		err    error
+	)
+	for i, o := range options {
+		if o.Exclusive == "" {
+			continue
+		}
+		for _, n := range []string{o.Short, o.Long} {
+			if n != "" {
+				names[n] = i
+			}
+		}
+	}
+	set.Visit(func(f *flag.Flag) {
+		i, ok := names[f.Name]
+		if !ok || err != nil {
+			return
+		}
+		g := options[i].Exclusive
+		if j, ok := groups[g]; ok && j != i {
+			err = fmt.Errorf("%s and %s can not be used together", optionUsage(options[j]), optionUsage(options[i]))
+		}
+		groups[g] = i
+	})
+	return err
+}
+
+const (
+	argOptional = "?"
+	argVariadic = "..."
+)
+
 type CommandArg struct {
-	Name  string
-	Valid ValidateFunc
+	Name     string
+	Optional bool
+	Variadic bool
+	Valid    ValidateFunc
 }
 
 func (a CommandArg) Validate(arg string) error {
 	if a.Valid == nil {
 		return nil
 	}
-	return a.Valid(arg)
+	if err := a.Valid(arg); err != nil {
+		return fmt.Errorf("%s: %w", a.Name, err)
+	}
+	return nil
+}
+
+func (a CommandArg) Required() bool {
+	return !a.Optional && !a.Variadic
+}
+
+func (a CommandArg) spec() string {
+	switch {
+	case a.Variadic:
+		return a.Name + argVariadic
+	case a.Optional:
+		return a.Name + argOptional
+	default:
+		return a.Name
+	}
+}
+
+type ExportFilter []string
+
+func (f ExportFilter) Keep(name string) bool {
+	var (
+		keep    = true
+		include bool
+	)
+	for _, pat := range f {
+		if strings.HasPrefix(pat, "!") {
+			if ok, _ := filepath.Match(pat[1:], name); ok {
+				return false
+			}
+			continue
+		}
+		if !include {
+			keep, include = false, true
+		}
+		if ok, _ := filepath.Match(pat, name); ok {
+			keep = true
+		}
+	}
+	return keep
+}
+
+func (f ExportFilter) Apply(ev map[string]string) map[string]string {
+	if len(f) == 0 {
+		return ev
+	}
+	others := make(map[string]string)
+	for k, v := range ev {
+		if f.Keep(k) {
+			others[k] = v
+		}
+	}
+	return others
+}
+
+type CommandStdin struct {
+	File    string
+	Content string
+}
+
+func (c CommandStdin) Open() (io.ReadCloser, error) {
+	switch {
+	case c.File != "":
+		return os.Open(c.File)
+	case c.Content != "":
+		return io.NopCloser(strings.NewReader(c.Content + "\n")), nil
+	default:
+		return nil, nil
+	}
+}
+
+type CommandExpect struct {
+	Code   int64
+	Output *regexp.Regexp
+	Files  []string
+}
+
+// Check compares the result of a command to the expectations. The expected
+// files are resolved from dir.
+func (e CommandExpect) Check(code int, output io.Reader, dir string) []string {
+	var list []string
+	if int64(code) != e.Code {
+		list = append(list, fmt.Sprintf("exit code mismatched! want %d, got %d", e.Code, code))
+	}
+	if e.Output != nil && !e.Output.MatchReader(bufio.NewReader(output)) {
+		list = append(list, fmt.Sprintf("output does not match %s", e.Output))
+	}
+	for _, f := range e.Files {
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(dir, f)
+		}
+		if _, err := os.Stat(f); err != nil {
+			list = append(list, fmt.Sprintf("%s: file not created", f))
+		}
+	}
+	return list
 }
 
 type CommandScript []string
@@ -102,28 +293,66 @@ func (c CommandScript) Reader() io.Reader {
 
 type CommandSettings struct {
 	Visible bool
+	File    string
+	Pos     Position
 
+	Space      string
 	Name       string
 	Alias      []string
+	Presets    map[string][]string
 	Short      string
 	Desc       string
 	Categories []string
 
-	Retry   int64
-	WorkDir string
-	Timeout time.Duration
+	Retry    int64
+	Delay    time.Duration
+	Backoff  float64
+	MaxDelay time.Duration
+	WorkDir  string
+	Umask    string
+	User     string
+	Timeout  time.Duration
+	Lazy     bool
+	Confirm  bool
+	Sandbox  bool
+	Cost     float64
+	Blackout Blackout
+	NeedsEnv []string
+
+	NoNewPrivs bool
+	Seccomp    []string
 
-	Hosts     []string
-	Deps      []CommandDep
-	Options   []CommandOption
-	Args      []CommandArg
-	Schedules []Schedule
-	Lines     CommandScript
+	Sources []string
+	Targets []string
 
-	As map[string]string
-	Ev map[string]string
+	MaxConcurrent int64
+	Policy        string
+	RateLimit     int64
+	Debounce      time.Duration
+	Secret        string
+	HttpMap       []HttpField
 
-	locals *env.Env
+	Hosts        []string
+	HostSettings map[string]HostSSH
+	Uploads      []Transfer
+	Downloads    []Transfer
+	Deps         []CommandDep
+	Options      []CommandOption
+	Args         []CommandArg
+	Schedules    []Schedule
+	Lines        CommandScript
+	Expect       CommandExpect
+	Stdin        CommandStdin
+
+	As       *ordered.Map[string, string]
+	Ev       *ordered.Map[string, string]
+	EnvFiles []EnvFile
+	Filter   ExportFilter
+	Inherit  bool
+
+	locals  *env.Env
+	secrets secretSet
+	preset  []string
 }
 
 func NewCommmandSettings(name string) (CommandSettings, error) {
@@ -132,10 +361,12 @@ func NewCommmandSettings(name string) (CommandSettings, error) {
 
 func NewCommandSettingsWithLocals(name string, locals *env.Env) (CommandSettings, error) {
 	cmd := CommandSettings{
-		Name:   name,
-		locals: locals,
-		Ev:     make(map[string]string),
-		As:     make(map[string]string),
+		Name:    name,
+		Inherit: true,
+		locals:  locals,
+		secrets: make(secretSet),
+		Ev:      ordered.New[string, string](),
+		As:      ordered.New[string, string](),
 	}
 	if cmd.locals == nil {
 		cmd.locals = env.EmptyEnv()
@@ -144,7 +375,7 @@ func NewCommandSettingsWithLocals(name string, locals *env.Env) (CommandSettings
 }
 
 func (s CommandSettings) Command() string {
-	return s.Name
+	return joinSpace(s.Space, s.Name)
 }
 
 func (s CommandSettings) About() string {
@@ -162,30 +393,55 @@ func (s CommandSettings) Tags() []string {
 	return s.Categories
 }
 
+func (s CommandSettings) IsTest() bool {
+	for _, t := range s.Categories {
+		if t == "test" || strings.HasPrefix(t, "test:") {
+			return true
+		}
+	}
+	return false
+}
+
 func (s CommandSettings) Usage() string {
-	var str strings.Builder
-	str.WriteString(s.Name)
+	var (
+		str    strings.Builder
+		groups = make(map[string][]string)
+	)
 	for _, o := range s.Options {
-		str.WriteString(" ")
-		str.WriteString("[")
-		if o.Short != "" {
-			str.WriteString("-")
-			str.WriteString(o.Short)
+		if o.Exclusive != "" {
+			groups[o.Exclusive] = append(groups[o.Exclusive], optionUsage(o))
 		}
-		if o.Short != "" && o.Long != "" {
-			str.WriteString("/")
-		}
-		if o.Long != "" {
-			str.WriteString("--")
-			str.WriteString(o.Long)
+	}
+	str.WriteString(s.Command())
+	for _, o := range s.Options {
+		usage := optionUsage(o)
+		if o.Exclusive != "" {
+			list, ok := groups[o.Exclusive]
+			if !ok {
+				continue
+			}
+			usage = strings.Join(list, " | ")
+			delete(groups, o.Exclusive)
 		}
+		str.WriteString(" ")
+		str.WriteString("[")
+		str.WriteString(usage)
 		str.WriteString("]")
 	}
 	for _, a := range s.Args {
 		str.WriteString(" ")
-		str.WriteString("<")
+		if a.Required() {
+			str.WriteString("<")
+			str.WriteString(a.Name)
+			str.WriteString(">")
+			continue
+		}
+		str.WriteString("[")
 		str.WriteString(a.Name)
-		str.WriteString(">")
+		if a.Variadic {
+			str.WriteString(argVariadic)
+		}
+		str.WriteString("]")
 	}
 	return str.String()
 }
@@ -195,24 +451,165 @@ func (s CommandSettings) Blocked() bool {
 }
 
 func (s CommandSettings) Remote() bool {
-	return len(s.Hosts) > 0
+	return len(s.RemoteHosts()) > 0
+}
+
+func (s CommandSettings) Local() bool {
+	for _, h := range s.Hosts {
+		if h == HostLocal {
+			return true
+		}
+	}
+	return false
+}
+
+func (s CommandSettings) RemoteHosts() []string {
+	var list []string
+	for _, h := range s.Hosts {
+		if h != HostLocal {
+			list = append(list, h)
+		}
+	}
+	return list
+}
+
+func (s CommandSettings) Environ() map[string]string {
+	ev := make(map[string]string)
+	if s.Inherit {
+		for _, str := range os.Environ() {
+			k, v, _ := strings.Cut(str, "=")
+			ev[k] = v
+		}
+	}
+	for _, k := range s.Ev.Keys() {
+		ev[k], _ = s.Ev.Get(k)
+	}
+	return s.Filter.Apply(ev)
+}
+
+// evaluate gives the settings with the command substitutions of the tags
+// evaluated and, unless the command is lazy, of the hosts and the workdir
+func (s CommandSettings) evaluate() (CommandSettings, error) {
+	if !s.Lazy {
+		return s.compute()
+	}
+	var err error
+	s.Categories, err = s.expandList(s.Categories)
+	return s, err
+}
+
+func (s CommandSettings) compute() (CommandSettings, error) {
+	var err error
+	if s.Hosts, err = s.expandList(s.Hosts); err != nil {
+		return s, err
+	}
+	sort.Strings(s.Hosts)
+	if s.Categories, err = s.expandList(s.Categories); err != nil {
+		return s, err
+	}
+	if s.WorkDir != "" {
+		if s.WorkDir, err = s.expand(s.WorkDir); err != nil {
+			return s, err
+		}
+	}
+	s.Lazy = false
+	return s, nil
+}
+
+func (s CommandSettings) expand(str string) (string, error) {
+	if !strings.Contains(str, "$(") {
+		return str, nil
+	}
+	var (
+		buf  bytes.Buffer
+		opts = []tish.ShellOption{
+			tish.WithEnv(s.locals.Copy()),
+			tish.WithExport(s.Environ()),
+			tish.WithStdout(&buf),
+		}
+	)
+	sh, err := tish.New(opts...)
+	if err == nil {
+		err = sh.Execute(context.TODO(), "echo "+str, s.Command(), nil)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %s: %w", s.Command(), str, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func (s CommandSettings) expandList(list []string) ([]string, error) {
+	var res []string
+	for _, str := range list {
+		v, err := s.expand(str)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, strings.Fields(v)...)
+	}
+	return res, nil
+}
+
+func (s CommandSettings) workDir() string {
+	dir := s.WorkDir
+	if dir != "" && !filepath.IsAbs(dir) && s.File != "" {
+		dir = filepath.Join(filepath.Dir(s.File), dir)
+	}
+	return dir
 }
 
 func (s CommandSettings) Prepare(options ...tish.ShellOption) (Executer, error) {
+	if s.Lazy {
+		var err error
+		if s, err = s.compute(); err != nil {
+			return nil, err
+		}
+	}
+	environ, err := s.environ()
+	if err != nil {
+		return nil, err
+	}
 	list := []tish.ShellOption{
 		tish.WithEnv(s.locals.Copy()),
-		tish.WithExport(s.Ev),
-		tish.WithAlias(s.As),
+		tish.WithExport(environ),
+		tish.WithAlias(s.As.Map()),
+	}
+	if dir := s.workDir(); dir != "" {
+		list = append(list, tish.WithCwd(dir))
+	}
+	umask := -1
+	if s.Umask != "" {
+		if umask, err = parseUmask(s.Umask); err != nil {
+			return nil, err
+		}
+	}
+	find, err := s.execFinder(options, umask)
+	if err != nil {
+		return nil, err
+	}
+	if find != nil {
+		list = append(list, tish.WithFinder(find))
 	}
 	sh, err := tish.New(append(options, list...)...)
 	if err != nil {
 		return nil, err
 	}
+	if find != nil {
+		find.sh = sh
+	}
 	cmd := command{
-		name:    s.Command(),
-		retry:   s.Retry,
-		timeout: s.Timeout,
-		shell:   sh,
+		name:     s.Command(),
+		file:     s.File,
+		pos:      s.Pos,
+		retry:    s.Retry,
+		delay:    s.Delay,
+		backoff:  s.Backoff,
+		maxDelay: s.MaxDelay,
+		timeout:  s.Timeout,
+		stdin:    s.Stdin,
+		preset:   s.preset,
+		sandbox:  s.Sandbox,
+		shell:    sh,
 	}
 	cmd.help, _ = s.Help()
 	cmd.script = append(cmd.script, s.Lines...)
@@ -223,15 +620,55 @@ func (s CommandSettings) Prepare(options ...tish.ShellOption) (Executer, error)
 	return &cmd, nil
 }
 
+func (s CommandSettings) execFinder(options []tish.ShellOption, umask int) (*execFinder, error) {
+	find := execFinder{
+		harden:  s.NoNewPrivs || len(s.Seccomp) > 0,
+		seccomp: s.Seccomp,
+		umask:   umask,
+	}
+	if s.User != "" {
+		u, err := user.Lookup(s.User)
+		if err != nil {
+			if u, err = user.LookupId(s.User); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.Command(), err)
+			}
+		}
+		if curr, err := user.Current(); err != nil || curr.Uid != u.Uid {
+			find.user = u
+		}
+	}
+	// tish gives its own environment to the commands it runs when the
+	// environment to export is empty
+	if find.user == nil && !find.harden && find.umask < 0 && s.Inherit {
+		return nil, nil
+	}
+	next, err := tish.New(options...)
+	if err != nil {
+		return nil, err
+	}
+	find.next = next
+	return &find, nil
+}
+
 type command struct {
 	name string
 	help string
 	deps []CommandDep
 
-	retry   int64
-	timeout time.Duration
+	file string
+	pos  Position
+
+	retry    int64
+	delay    time.Duration
+	backoff  float64
+	maxDelay time.Duration
+	timeout  time.Duration
+	stdin    CommandStdin
+	failures []int
+	sandbox  bool
 
 	script  CommandScript
+	preset  []string
 	args    []CommandArg
 	options []CommandOption
 
@@ -289,7 +726,41 @@ func (c *command) Script(args []string) ([]string, error) {
 	return list, nil
 }
 
+func (c *command) variables(args []string, environ map[string]string) (map[string]string, error) {
+	if _, err := c.parseArgs(args); err != nil {
+		return nil, err
+	}
+	var (
+		refs    = make(map[string]bool)
+		defined = make(map[string]struct{})
+		vars    = make(map[string]string)
+	)
+	for _, str := range c.script {
+		scanReferences(str, refs, defined)
+	}
+	for ident := range refs {
+		if _, ok := defined[ident]; ok {
+			continue
+		}
+		if _, ok := shellSpecials[ident]; ok {
+			continue
+		}
+		if _, ok := environ[ident]; ok {
+			continue
+		}
+		vs, err := c.shell.Resolve(ident)
+		if err != nil || vs == nil {
+			continue
+		}
+		vars[ident] = strings.Join(vs, " ")
+	}
+	return vars, nil
+}
+
 func (c *command) Execute(ctx context.Context, args []string) error {
+	if c.sandbox && !sandboxed {
+		return fmt.Errorf("%s: %w", c.name, errSandbox)
+	}
 	args, err := c.parseArgs(args)
 	if err != nil {
 		return err
@@ -302,7 +773,22 @@ func (c *command) Execute(ctx context.Context, args []string) error {
 		ctx, cancel = context.WithTimeout(ctx, c.timeout)
 		defer cancel()
 	}
+	wait := c.delay
 	for i := int64(0); i < c.retry; i++ {
+		if i > 0 && wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return ctx.Err()
+			case <-t.C:
+			}
+			wait = c.nextDelay(wait)
+		}
+		if c.injected(i + 1) {
+			err = fmt.Errorf("%s: failure injected (attempt %d)", c.name, i+1)
+			continue
+		}
 		err = c.execute(ctx, args)
 		if err == nil {
 			break
@@ -318,20 +804,114 @@ func (c *command) execute(ctx context.Context, args []string) error {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
-	c.shell.Run(ctx, c.script.Reader(), c.name, args)
-	return nil
+	r, err := c.stdin.Open()
+	if err != nil {
+		return fmt.Errorf("%s: stdin: %w", c.name, err)
+	}
+	if r != nil {
+		defer r.Close()
+		c.shell.SetIn(r)
+	}
+	err = c.shell.Run(ctx, c.script.Reader(), c.name, args)
+	if errors.Is(err, tish.ErrExit) {
+		return exitStatus(err)
+	}
+	if err == nil || isExit(err) || ctx.Err() != nil {
+		return err
+	}
+	return fmt.Errorf("%s: %s: %w", c.where(), c.name, err)
+}
+
+func (c *command) injected(attempt int64) bool {
+	for _, n := range c.failures {
+		if n == 0 || int64(n) == attempt {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *command) nextDelay(wait time.Duration) time.Duration {
+	if c.backoff > 1 {
+		wait = time.Duration(float64(wait) * c.backoff)
+	}
+	if c.maxDelay > 0 && wait > c.maxDelay {
+		wait = c.maxDelay
+	}
+	return wait
+}
+
+// ExitError is returned when a script is ended by the exit builtin. tish only
+// gives the code in the message of the error wrapping tish.ErrExit, so it is
+// extracted once, when the script ends, and the callers use errors.As.
+type ExitError struct {
+	Code int
+	err  error
+}
+
+func exitStatus(err error) error {
+	e := ExitError{
+		Code: 1,
+		err:  err,
+	}
+	str := err.Error()
+	str = str[strings.LastIndex(str, tish.ErrExit.Error())+len(tish.ErrExit.Error()):]
+	if n, err := strconv.Atoi(strings.TrimPrefix(str, ": ")); err == nil {
+		e.Code = int(uint8(n))
+	}
+	return e
+}
+
+func (e ExitError) Error() string {
+	return e.err.Error()
+}
+
+func (e ExitError) Unwrap() error {
+	return e.err
+}
+
+func (e ExitError) ExitCode() int {
+	return e.Code
+}
+
+func isExit(err error) bool {
+	var (
+		code tish.ExitCode
+		exit *exec.ExitError
+	)
+	return errors.As(err, &code) || errors.As(err, &exit) || errors.Is(err, tish.ErrExit)
+}
+
+func (c *command) where() string {
+	if c.file == "" {
+		return fmt.Sprintf("line %d", c.pos.Line)
+	}
+	return fmt.Sprintf("%s:%d", c.file, c.pos.Line)
 }
 
 func (c *command) parseArgs(args []string) ([]string, error) {
+	if len(c.preset) > 0 {
+		args = append(append([]string{}, c.preset...), args...)
+	}
 	set, err := c.prepareArgs(args)
 	if err != nil {
 		return nil, err
 	}
-	define := func(name, value string) error {
+	if err := checkExclusive(c.options, set); err != nil {
+		return nil, UsageError{
+			Command: c.name,
+			Usage:   strings.TrimSpace(c.help),
+			Err:     err,
+		}
+	}
+	defineList := func(name string, values []string) error {
 		if name == "" {
 			return nil
 		}
-		return c.shell.Define(name, []string{value})
+		return c.shell.Define(name, values)
+	}
+	define := func(name, value string) error {
+		return defineList(name, []string{value})
 	}
 	defineFlag := func(name string, value bool) error {
 		return define(name, strconv.FormatBool(value))
@@ -341,10 +921,14 @@ func (c *command) parseArgs(args []string) ([]string, error) {
 			return nil, err
 		}
 		var e1, e2 error
-		if o.Flag {
+		switch {
+		case o.Flag:
 			e1 = defineFlag(o.Short, o.TargetFlag)
 			e2 = defineFlag(o.Long, o.TargetFlag)
-		} else {
+		case o.List:
+			e1 = defineList(o.Short, o.TargetList)
+			e2 = defineList(o.Long, o.TargetList)
+		default:
 			e1 = define(o.Short, o.Target)
 			e2 = define(o.Long, o.Target)
 		}
@@ -352,21 +936,58 @@ func (c *command) parseArgs(args []string) ([]string, error) {
 			return nil, err
 		}
 	}
-	if z := len(c.args); z > 0 && set.NArg() < z {
-		return nil, fmt.Errorf("%s: no enough argument supplied! expected %d, got %d", c.name, z, set.NArg())
+	rest := trimTerminator(args, set.Args())
+	if z := requiredArgs(c.args); z > 0 && len(rest) < z {
+		return nil, fmt.Errorf("%s: no enough argument supplied! expected %d, got %d", c.name, z, len(rest))
 	}
-	return set.Args(), nil
+	for i, a := range c.args {
+		var values []string
+		switch {
+		case a.Variadic && i < len(rest):
+			values = rest[i:]
+		case i < len(rest):
+			values = rest[i : i+1]
+		}
+		for _, v := range values {
+			if err := a.Validate(v); err != nil {
+				return nil, err
+			}
+		}
+		if err := defineList(a.Name, values); err != nil {
+			return nil, err
+		}
+	}
+	return rest, nil
+}
+
+func requiredArgs(args []CommandArg) int {
+	var n int
+	for _, a := range args {
+		if a.Required() {
+			n++
+		}
+	}
+	return n
+}
+
+func trimTerminator(args, rest []string) []string {
+	if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+		return rest
+	}
+	for i := range rest {
+		if rest[i] == "--" {
+			return append(rest[:i:i], rest[i+1:]...)
+		}
+	}
+	return rest
 }
 
 func (c *command) prepareArgs(args []string) (*flag.FlagSet, error) {
 	var (
-		set  = flag.NewFlagSet(c.name, flag.ExitOnError)
+		set  = flag.NewFlagSet(c.name, flag.ContinueOnError)
 		seen = make(map[string]struct{})
 	)
-	set.Usage = func() {
-		fmt.Fprintln(os.Stdout, strings.TrimSpace(c.help))
-		os.Exit(1)
-	}
+	set.SetOutput(io.Discard)
 	check := func(name string) error {
 		if name == "" {
 			return nil
@@ -391,12 +1012,29 @@ func (c *command) prepareArgs(args []string) (*flag.FlagSet, error) {
 		}
 		return err
 	}
+	attachList := func(name, help string, target flag.Value) error {
+		err := check(name)
+		if err == nil && name != "" {
+			set.Var(target, name, help)
+		}
+		return err
+	}
 	for i, o := range c.options {
 		var e1, e2 error
-		if o.Flag {
+		switch {
+		case o.Flag:
 			e1 = attachFlag(o.Short, o.Help, o.DefaultFlag, &c.options[i].TargetFlag)
 			e2 = attachFlag(o.Long, o.Help, o.DefaultFlag, &c.options[i].TargetFlag)
-		} else {
+		case o.List:
+			values := optionList{
+				list: &c.options[i].TargetList,
+			}
+			if o.Default != "" {
+				c.options[i].TargetList = append(c.options[i].TargetList[:0], o.Default)
+			}
+			e1 = attachList(o.Short, o.Help, &values)
+			e2 = attachList(o.Long, o.Help, &values)
+		default:
 			e1 = attach(o.Short, o.Help, o.Default, &c.options[i].Target)
 			e2 = attach(o.Long, o.Help, o.Default, &c.options[i].Target)
 		}
@@ -405,11 +1043,99 @@ func (c *command) prepareArgs(args []string) (*flag.FlagSet, error) {
 		}
 	}
 	if err := set.Parse(args); err != nil {
-		return nil, err
+		return nil, UsageError{
+			Command: c.name,
+			Usage:   strings.TrimSpace(c.help),
+			Err:     c.suggestOption(err),
+		}
 	}
 	return set, nil
 }
 
+const undefinedOption = "flag provided but not defined: "
+
+func (c *command) suggestOption(err error) error {
+	msg := err.Error()
+	if !strings.HasPrefix(msg, undefinedOption) {
+		return err
+	}
+	name := strings.TrimLeft(strings.TrimPrefix(msg, undefinedOption), "-")
+	if len(name) <= distance.DefaultDistance {
+		return err
+	}
+	var names []string
+	for _, o := range c.options {
+		for _, n := range []string{o.Short, o.Long} {
+			if n != "" {
+				names = append(names, n)
+			}
+		}
+	}
+	names = distance.Levenshtein(name, names)
+	if len(names) == 0 {
+		return err
+	}
+	for i := range names {
+		names[i] = optionName(names[i])
+	}
+	return SuggestionError{
+		Err:    err,
+		Others: names,
+	}
+}
+
+func parseFlags(set *flag.FlagSet, args []string) error {
+	set.SetOutput(io.Discard)
+	if err := set.Parse(args); err != nil {
+		var usage strings.Builder
+		fmt.Fprintf(&usage, "usage: %s", set.Name())
+		fmt.Fprintln(&usage)
+		set.SetOutput(&usage)
+		set.PrintDefaults()
+		return UsageError{
+			Command: set.Name(),
+			Usage:   strings.TrimSpace(usage.String()),
+			Err:     err,
+		}
+	}
+	return nil
+}
+
+type UsageError struct {
+	Command string
+	Usage   string
+	Err     error
+}
+
+func (u UsageError) Error() string {
+	return fmt.Sprintf("%s: %s", u.Command, u.Err)
+}
+
+func (u UsageError) Unwrap() error {
+	return u.Err
+}
+
+type optionList struct {
+	list *[]string
+	set  bool
+}
+
+func (o *optionList) Set(str string) error {
+	if !o.set {
+		*o.list = (*o.list)[:0]
+		o.set = true
+	}
+	*o.list = append(*o.list, str)
+	return nil
+}
+
+func (o *optionList) String() string {
+	if o.list == nil {
+		return ""
+	}
+	return strings.Join(*o.list, ", ")
+}
+
 type shellCommand struct {
 	cmd  Executer
 	args []string
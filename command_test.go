@@ -0,0 +1,102 @@
+package maestro_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/midbel/maestro"
+)
+
+func TestRetryDelay(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(retried))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("flaky")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	ex, err := cmd.Prepare()
+	if err != nil {
+		t.Fatalf("fail to prepare command: %s", err)
+	}
+	var (
+		buf bytes.Buffer
+		now = time.Now()
+	)
+	ex.SetOut(&buf)
+	if err := ex.Execute(context.TODO(), nil); err == nil {
+		t.Fatalf("flaky should fail after all its attempts")
+	}
+	if elapsed := time.Since(now); elapsed < 60*time.Millisecond {
+		t.Errorf("retries should be delayed (20ms + 40ms), elapsed %s", elapsed)
+	}
+	if got := strings.Count(buf.String(), "attempt"); got != 3 {
+		t.Errorf("attempts mismatched! want 3, got %d", got)
+	}
+}
+
+const retried = `
+flaky(
+	retry     = 3,
+	delay     = 20ms,
+	backoff   = 2,
+	max_delay = 1s,
+): {
+	echo attempt
+	exit 1
+}
+`
+
+func TestPresets(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(presets))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	tests := []struct {
+		Name string
+		Args []string
+		Want string
+	}{
+		{Name: "deploy", Want: "dev"},
+		{Name: "prod", Want: "prod"},
+		{Name: "prod", Args: []string{"--env", "test"}, Want: "test"},
+		{Name: "stage", Want: "stage"},
+	}
+	for _, tt := range tests {
+		cmd, err := mst.Commands.Lookup(tt.Name)
+		if err != nil {
+			t.Fatalf("%s: command not found: %s", tt.Name, err)
+		}
+		ex, err := cmd.Prepare()
+		if err != nil {
+			t.Fatalf("%s: fail to prepare command: %s", tt.Name, err)
+		}
+		var buf bytes.Buffer
+		ex.SetOut(&buf)
+		if err := ex.Execute(context.TODO(), tt.Args); err != nil {
+			t.Fatalf("%s: fail to execute: %s", tt.Name, err)
+		}
+		if got := strings.TrimSpace(buf.String()); got != tt.Want {
+			t.Errorf("%s: output mismatched! want %q, got %q", tt.Name, tt.Want, got)
+		}
+	}
+}
+
+const presets = `
+deploy(
+	alias = (
+		prod  = "--env prod",
+		stage = "--env stage",
+	),
+	options = (
+		long    = env,
+		default = dev,
+	),
+): {
+	echo $env
+}
+`
@@ -0,0 +1,321 @@
+package maestro
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	shellBash = "bash"
+	shellZsh  = "zsh"
+	shellFish = "fish"
+)
+
+func (m *Maestro) Completion(args []string) error {
+	set := flag.NewFlagSet(CmdCompletion, flag.ContinueOnError)
+	if err := parseFlags(set, args); err != nil {
+		return err
+	}
+	var cmds []CommandSettings
+	for _, c := range m.Commands.Values() {
+		if c.Blocked() {
+			continue
+		}
+		cmds = append(cmds, c)
+	}
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].Command() < cmds[j].Command()
+	})
+	globals := globalOptions()
+	switch shell := set.Arg(0); shell {
+	case shellBash:
+		completeBash(m.Stdout, cmds, globals)
+	case shellZsh:
+		completeZsh(m.Stdout, cmds, globals)
+	case shellFish:
+		completeFish(m.Stdout, cmds, globals)
+	case "":
+		return fmt.Errorf("%s: shell expected (%s, %s, %s)", CmdCompletion, shellBash, shellZsh, shellFish)
+	default:
+		return fmt.Errorf("%s: unsupported shell (%s, %s, %s expected)", shell, shellBash, shellZsh, shellFish)
+	}
+	return nil
+}
+
+func completeBash(w io.Writer, cmds []CommandSettings, globals []globalOption) {
+	var (
+		names  []string
+		opts   []string
+		values []string
+	)
+	for _, c := range cmds {
+		names = append(names, commandNames(c)...)
+	}
+	for _, g := range globals {
+		opts = append(opts, g.Name)
+		if g.Value {
+			values = append(values, g.Name)
+		}
+	}
+	fmt.Fprintln(w, "_maestro_complete() {")
+	fmt.Fprintln(w, "\tlocal cur prev cmd i")
+	fmt.Fprintln(w, "\tcur=\"${COMP_WORDS[COMP_CWORD]}\"")
+	fmt.Fprintln(w, "\tprev=\"${COMP_WORDS[COMP_CWORD-1]}\"")
+	fmt.Fprintln(w, "\tfor ((i=1; i < COMP_CWORD; i++)); do")
+	fmt.Fprintln(w, "\t\tcase \"${COMP_WORDS[i]}\" in")
+	if len(values) > 0 {
+		fmt.Fprintf(w, "\t\t%s) ((i++)) ;;", strings.Join(values, "|"))
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "\t\t-*) ;;")
+	fmt.Fprintln(w, "\t\t*) cmd=\"${COMP_WORDS[i]}\"; break ;;")
+	fmt.Fprintln(w, "\t\tesac")
+	fmt.Fprintln(w, "\tdone")
+	fmt.Fprintln(w, "\tif [[ -z \"$cmd\" ]]; then")
+	if len(values) > 0 {
+		fmt.Fprintf(w, "\t\tcase \"$prev\" in %s) return ;; esac", strings.Join(values, "|"))
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "\t\tif [[ \"$cur\" == -* ]]; then")
+	fmt.Fprintf(w, "\t\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\"))", shellQuote(strings.Join(opts, " ")))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "\t\telse")
+	fmt.Fprintf(w, "\t\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\"))", shellQuote(strings.Join(names, " ")))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "\t\tfi")
+	fmt.Fprintln(w, "\t\treturn")
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, "\tcase \"$cmd\" in")
+	for _, c := range cmds {
+		if len(c.Options) == 0 {
+			continue
+		}
+		var opts []string
+		fmt.Fprintf(w, "\t%s)", strings.Join(commandNames(c), "|"))
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "\t\tcase \"$prev\" in")
+		for _, o := range c.Options {
+			names := optionNames(o)
+			opts = append(opts, names...)
+			if o.Flag {
+				continue
+			}
+			fmt.Fprintf(w, "\t\t%s)", strings.Join(names, "|"))
+			if len(o.Choices) > 0 {
+				fmt.Fprintf(w, " COMPREPLY=($(compgen -W %s -- \"$cur\"));", shellQuote(strings.Join(o.Choices, " ")))
+			}
+			fmt.Fprintln(w, " return ;;")
+		}
+		fmt.Fprintln(w, "\t\tesac")
+		fmt.Fprintln(w, "\t\tif [[ \"$cur\" == -* ]]; then")
+		fmt.Fprintf(w, "\t\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\"))", shellQuote(strings.Join(opts, " ")))
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "\t\tfi")
+		fmt.Fprintln(w, "\t\t;;")
+	}
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "complete -o default -F _maestro_complete maestro")
+}
+
+func completeZsh(w io.Writer, cmds []CommandSettings, globals []globalOption) {
+	fmt.Fprintln(w, "#compdef maestro")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "_maestro() {")
+	fmt.Fprintln(w, "\tlocal state line")
+	fmt.Fprintln(w, "\tlocal -a commands")
+	fmt.Fprintln(w, "\tcommands=(")
+	for _, c := range cmds {
+		for _, n := range commandNames(c) {
+			desc := strings.ReplaceAll(n, ":", "\\:")
+			if about := firstLine(c.About()); about != "" {
+				desc = fmt.Sprintf("%s:%s", desc, about)
+			}
+			fmt.Fprintf(w, "\t\t%s", shellQuote(desc))
+			fmt.Fprintln(w)
+		}
+	}
+	fmt.Fprintln(w, "\t)")
+	fmt.Fprint(w, "\t_arguments -C")
+	for _, g := range globals {
+		spec := g.Name
+		if g.Value {
+			spec += ":value:_default"
+		}
+		fmt.Fprintf(w, " \\\n\t\t%s", shellQuote(spec))
+	}
+	fmt.Fprint(w, " \\\n\t\t'1: :->command' \\\n\t\t'*:: :->args'")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "\tcase $state in")
+	fmt.Fprintln(w, "\tcommand)")
+	fmt.Fprintln(w, "\t\t_describe 'command' commands")
+	fmt.Fprintln(w, "\t\t;;")
+	fmt.Fprintln(w, "\targs)")
+	fmt.Fprintln(w, "\t\tcase $line[1] in")
+	for _, c := range cmds {
+		if len(c.Options) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\t\t%s)", strings.Join(commandNames(c), "|"))
+		fmt.Fprintln(w)
+		groups := make(map[string][]string)
+		for _, o := range c.Options {
+			if o.Exclusive != "" {
+				groups[o.Exclusive] = append(groups[o.Exclusive], optionNames(o)...)
+			}
+		}
+		fmt.Fprint(w, "\t\t\t_arguments")
+		for _, o := range c.Options {
+			fmt.Fprintf(w, " \\\n\t\t\t\t%s", zshOption(o, groups[o.Exclusive]))
+		}
+		fmt.Fprint(w, " \\\n\t\t\t\t'*:file:_files'")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "\t\t\t;;")
+	}
+	fmt.Fprintln(w, "\t\tesac")
+	fmt.Fprintln(w, "\t\t;;")
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "_maestro \"$@\"")
+}
+
+func zshOption(o CommandOption, group []string) string {
+	var (
+		names = optionNames(o)
+		spec  strings.Builder
+	)
+	if len(group) == 0 {
+		group = names
+	}
+	if o.List {
+		spec.WriteString("*")
+	} else if len(group) > 1 {
+		spec.WriteString("(")
+		spec.WriteString(strings.Join(group, " "))
+		spec.WriteString(")")
+	}
+	prefix := spec.String()
+	spec.Reset()
+	if help := firstLine(o.Help); help != "" {
+		spec.WriteString("[")
+		spec.WriteString(strings.NewReplacer("[", "\\[", "]", "\\]").Replace(help))
+		spec.WriteString("]")
+	}
+	if !o.Flag {
+		spec.WriteString(":value:")
+		if len(o.Choices) > 0 {
+			spec.WriteString("(")
+			spec.WriteString(strings.Join(o.Choices, " "))
+			spec.WriteString(")")
+		} else {
+			spec.WriteString("_default")
+		}
+	}
+	if len(names) == 1 {
+		return shellQuote(prefix + names[0] + spec.String())
+	}
+	str := fmt.Sprintf("%s{%s}", shellQuote(prefix), strings.Join(names, ","))
+	if spec.Len() > 0 {
+		str += shellQuote(spec.String())
+	}
+	return str
+}
+
+func completeFish(w io.Writer, cmds []CommandSettings, globals []globalOption) {
+	for _, g := range globals {
+		name := strings.TrimLeft(g.Name, "-")
+		fmt.Fprint(w, "complete -c maestro -n __fish_use_subcommand")
+		if len(name) == 1 {
+			fmt.Fprintf(w, " -s %s", fishQuote(name))
+		} else {
+			fmt.Fprintf(w, " -l %s", fishQuote(name))
+		}
+		if g.Value {
+			fmt.Fprint(w, " -r")
+		}
+		fmt.Fprintln(w)
+	}
+	for _, c := range cmds {
+		for _, n := range commandNames(c) {
+			fmt.Fprintf(w, "complete -c maestro -n __fish_use_subcommand -a %s", fishQuote(n))
+			if about := firstLine(c.About()); about != "" {
+				fmt.Fprintf(w, " -d %s", fishQuote(about))
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	for _, c := range cmds {
+		cond := fishQuote("__fish_seen_subcommand_from " + strings.Join(commandNames(c), " "))
+		for _, o := range c.Options {
+			fmt.Fprintf(w, "complete -c maestro -n %s", cond)
+			if len(o.Short) == 1 {
+				fmt.Fprintf(w, " -s %s", fishQuote(o.Short))
+			} else if o.Short != "" {
+				fmt.Fprintf(w, " -o %s", fishQuote(o.Short))
+			}
+			if o.Long != "" {
+				fmt.Fprintf(w, " -l %s", fishQuote(o.Long))
+			}
+			if help := firstLine(o.Help); help != "" {
+				fmt.Fprintf(w, " -d %s", fishQuote(help))
+			}
+			switch {
+			case o.Flag:
+			case len(o.Choices) > 0:
+				fmt.Fprintf(w, " -x -a %s", fishQuote(strings.Join(o.Choices, " ")))
+			default:
+				fmt.Fprint(w, " -r")
+			}
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+type globalOption struct {
+	Name  string
+	Value bool
+}
+
+func globalOptions() []globalOption {
+	var list []globalOption
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		opt := globalOption{
+			Name:  optionName(f.Name),
+			Value: true,
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			opt.Value = false
+		}
+		list = append(list, opt)
+	})
+	return list
+}
+
+func commandNames(cmd CommandSettings) []string {
+	return append([]string{cmd.Command()}, cmd.Alias...)
+}
+
+func optionNames(o CommandOption) []string {
+	var names []string
+	if o.Short != "" {
+		names = append(names, "-"+o.Short)
+	}
+	if o.Long != "" {
+		names = append(names, "--"+o.Long)
+	}
+	return names
+}
+
+func firstLine(str string) string {
+	str, _, _ = strings.Cut(strings.TrimSpace(str), "\n")
+	return str
+}
+
+func fishQuote(str string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(str) + "'"
+}
@@ -0,0 +1,82 @@
+package maestro_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/midbel/maestro"
+)
+
+func TestCompletion(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(completionFile))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("build")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	if c := cmd.Options[0].Choices; strings.Join(c, " ") != "prod dev" {
+		t.Errorf("choices mismatched! want [prod dev], got %v", c)
+	}
+	var buf bytes.Buffer
+	mst.Stdout = &buf
+	want := map[string][]string{
+		"bash": {
+			"'build b'",
+			"build|b)",
+			"-e|--env) COMPREPLY=($(compgen -W 'prod dev' -- \"$cur\")); return ;;",
+			"'-e --env -v'",
+		},
+		"zsh": {
+			"'build:build the project'",
+			"'(-e --env)'{-e,--env}'[environment]:value:(prod dev)'",
+		},
+		"fish": {
+			"-a 'b' -d 'build the project'",
+			"-s 'e' -l 'env' -d 'environment' -x -a 'prod dev'",
+		},
+	}
+	for shell, parts := range want {
+		buf.Reset()
+		if err := mst.Completion([]string{shell}); err != nil {
+			t.Errorf("%s: fail to generate completion: %s", shell, err)
+			continue
+		}
+		got := buf.String()
+		for _, p := range parts {
+			if !strings.Contains(got, p) {
+				t.Errorf("%s: %q not found in completion script", shell, p)
+			}
+		}
+		if strings.Contains(got, "secret") {
+			t.Errorf("%s: hidden command should not be completed", shell)
+		}
+	}
+	if err := mst.Completion([]string{"ksh"}); err == nil {
+		t.Errorf("unsupported shell should be rejected")
+	}
+}
+
+const completionFile = `
+build(
+	short   = "build the project",
+	alias   = b,
+	options = (
+		short = e,
+		long  = env,
+		help  = environment,
+		check = oneof("prod" "dev"),
+	), (
+		short = v,
+		flag  = true,
+	),
+): {
+	echo $env $v
+}
+
+%secret: {
+	echo secret
+}
+`
@@ -0,0 +1,125 @@
+package maestro
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+const configExt = ".toml"
+
+var configForbidden = []string{metaInclude, metaEnvFile, metaExport}
+
+func (m *Maestro) loadConfig(file string) error {
+	config := m.Config
+	if config == "" {
+		config = strings.TrimSuffix(file, filepath.Ext(file)) + configExt
+		if _, err := os.Stat(config); errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+	}
+	r, err := os.Open(config)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var doc map[string]interface{}
+	md, err := toml.NewDecoder(r).Decode(&doc)
+	if err != nil {
+		return fmt.Errorf("%s: %w", config, err)
+	}
+	var str strings.Builder
+	for _, k := range md.Keys() {
+		values, err := configValues(configLookup(doc, k))
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", config, k, err)
+		}
+		if values == nil {
+			continue
+		}
+		meta := strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(strings.Join(k, "_")))
+		for _, f := range configForbidden {
+			if meta == f {
+				return fmt.Errorf("%s: %s can only be set in the maestro file", config, k)
+			}
+		}
+		for i := range values {
+			if values[i], err = quoteValue(values[i], false); err != nil {
+				return fmt.Errorf("%s: %s: %w", config, k, err)
+			}
+		}
+		fmt.Fprintf(&str, ".%s = %s", meta, strings.Join(values, " "))
+		fmt.Fprintln(&str)
+	}
+	d, err := NewDecoderWithEnv(strings.NewReader(str.String()), m.Locals)
+	if err != nil {
+		return err
+	}
+	d.setFile(config)
+	if err := d.decodeMetas(m); err != nil {
+		return fmt.Errorf("%s: %w", config, err)
+	}
+	return nil
+}
+
+func configLookup(doc map[string]interface{}, key toml.Key) interface{} {
+	var v interface{} = doc
+	for _, k := range key {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[k]
+	}
+	return v
+}
+
+// configValues gives the values of a key of the configuration file. Tables
+// give no values since their keys are given separately
+func configValues(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return nil, nil
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, x := range v {
+			str, err := configScalar(x)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, str)
+		}
+		return list, nil
+	default:
+		str, err := configScalar(v)
+		if err != nil {
+			return nil, err
+		}
+		return []string{str}, nil
+	}
+}
+
+func configScalar(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339), nil
+	default:
+		return "", fmt.Errorf("tables and arrays of tables can not be used as value")
+	}
+}
@@ -0,0 +1,219 @@
+package maestro
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+const (
+	encPrefix = "enc[AGE-"
+	encSuffix = "]"
+)
+
+const (
+	EnvKey     = "MAESTRO_AGE_KEY"
+	EnvKeyFile = "MAESTRO_AGE_KEY_FILE"
+)
+
+var (
+	errNoKey       = errors.New("no age identity (set MAESTRO_AGE_KEY or MAESTRO_AGE_KEY_FILE)")
+	errNoRecipient = errors.New("no age recipient (use -r or set MAESTRO_AGE_KEY or MAESTRO_AGE_KEY_FILE)")
+)
+
+func isEncrypted(str string) bool {
+	return strings.HasPrefix(str, encPrefix) && strings.HasSuffix(str, encSuffix)
+}
+
+func encryptionIdentities() ([]age.Identity, error) {
+	var r io.Reader
+	if key := os.Getenv(EnvKey); key != "" {
+		r = strings.NewReader(key)
+	} else if file := os.Getenv(EnvKeyFile); file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	} else {
+		return nil, errNoKey
+	}
+	list, err := age.ParseIdentities(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid age identity: %w", err)
+	}
+	return list, nil
+}
+
+func encryptValue(value string, recipients ...age.Recipient) (string, error) {
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipients...)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.WriteString(w, value); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return encPrefix + base64.StdEncoding.EncodeToString(buf.Bytes()) + encSuffix, nil
+}
+
+func decryptValue(value string, identities ...age.Identity) (string, error) {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, encPrefix), encSuffix)
+	buf, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	r, err := age.Decrypt(bytes.NewReader(buf), identities...)
+	if err != nil {
+		return "", fmt.Errorf("fail to decrypt value: %w", err)
+	}
+	plain, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("fail to decrypt value: %w", err)
+	}
+	return string(plain), nil
+}
+
+func decryptValues(list []string) ([]string, error) {
+	var identities []age.Identity
+	for i := range list {
+		if !isEncrypted(list[i]) {
+			continue
+		}
+		if identities == nil {
+			ids, err := encryptionIdentities()
+			if err != nil {
+				return nil, err
+			}
+			identities = ids
+		}
+		str, err := decryptValue(list[i], identities...)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = str
+	}
+	return list, nil
+}
+
+// secretSet keeps the decrypted values of the variables that were given
+// encrypted in the maestro file, so that they are never written in clear.
+type secretSet map[string][]string
+
+func (s secretSet) decrypt(ident string, list []string) ([]string, error) {
+	var pos []int
+	for i := range list {
+		if isEncrypted(list[i]) {
+			pos = append(pos, i)
+		}
+	}
+	list, err := decryptValues(list)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ident, err)
+	}
+	for _, i := range pos {
+		s[ident] = append(s[ident], list[i])
+	}
+	return list, nil
+}
+
+func (s secretSet) Has(ident string) bool {
+	_, ok := s[ident]
+	return ok
+}
+
+func (s secretSet) Contains(str string) bool {
+	for _, vs := range s {
+		for _, v := range vs {
+			if v != "" && strings.Contains(str, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s secretSet) Copy() secretSet {
+	x := make(secretSet)
+	for k, vs := range s {
+		x[k] = append([]string{}, vs...)
+	}
+	return x
+}
+
+type recipientList []age.Recipient
+
+func (r *recipientList) Set(str string) error {
+	x, err := age.ParseX25519Recipient(str)
+	if err != nil {
+		return err
+	}
+	*r = append(*r, x)
+	return nil
+}
+
+func (r *recipientList) String() string {
+	var list []string
+	for _, x := range *r {
+		if s, ok := x.(fmt.Stringer); ok {
+			list = append(list, s.String())
+		}
+	}
+	return strings.Join(list, ", ")
+}
+
+func (m *Maestro) Encrypt(args []string) error {
+	var (
+		set        = flag.NewFlagSet(CmdEncrypt, flag.ContinueOnError)
+		recipients recipientList
+	)
+	set.Var(&recipients, "r", "encrypt for the given age recipient (can be repeated)")
+	if err := parseFlags(set, args); err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		list, err := encryptionIdentities()
+		if errors.Is(err, errNoKey) {
+			return errNoRecipient
+		}
+		if err != nil {
+			return err
+		}
+		for _, i := range list {
+			if x, ok := i.(*age.X25519Identity); ok {
+				recipients = append(recipients, x.Recipient())
+			}
+		}
+	}
+	values := set.Args()
+	if len(values) == 0 {
+		scan := bufio.NewScanner(os.Stdin)
+		for scan.Scan() {
+			values = append(values, scan.Text())
+		}
+		if err := scan.Err(); err != nil {
+			return err
+		}
+	}
+	for _, v := range values {
+		str, err := encryptValue(v, recipients...)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(m.Stdout, "%q", str)
+		fmt.Fprintln(m.Stdout)
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 	"time"
 
 	"golang.org/x/sync/errgroup"
@@ -21,6 +22,96 @@ type ctreeOption struct {
 	Prefix bool
 	Trace  bool
 	NoDeps bool
+	Direct bool
+	Github bool
+	Record *recordSet
+	Skip   Patterns
+	Only   Patterns
+	Args   Overrides
+}
+
+func (o ctreeOption) skipped(name string) bool {
+	return o.Skip.Match(name)
+}
+
+func (o ctreeOption) selected(name string) bool {
+	return len(o.Only) == 0 || o.Only.Match(name)
+}
+
+const (
+	reasonExecuted = "already executed"
+	reasonSkipped  = "skipped by --skip-dep"
+	reasonNotFound = "optional and not found"
+)
+
+// depNode is a dependency of a command. Reason is set when the dependency is
+// not executed and Selected is false when only its own dependencies are
+// executed (--only-dep)
+type depNode struct {
+	CommandDep
+	Cmd      CommandSettings
+	Reason   string
+	Selected bool
+	Deps     depTree
+}
+
+type depTree []depNode
+
+// walk calls fn for each node once the dependencies of the node are walked,
+// ie in the order the dependencies are executed
+func (t depTree) walk(fn func(depNode)) {
+	for _, n := range t {
+		n.Deps.walk(fn)
+		fn(n)
+	}
+}
+
+// walkDependencies decides which dependencies of a command are executed. It is
+// used to execute the command as well as to compute its cost and explain it
+func (m *Maestro) walkDependencies(deps []CommandDep, option ctreeOption) (depTree, error) {
+	var (
+		seen     = make(map[string]struct{})
+		empty    = struct{}{}
+		traverse func([]CommandDep) (depTree, error)
+	)
+	traverse = func(deps []CommandDep) (depTree, error) {
+		var tree depTree
+		for _, d := range deps {
+			node := depNode{
+				CommandDep: d,
+			}
+			if _, ok := seen[d.Key()]; ok && !d.Mandatory {
+				node.Reason = reasonExecuted
+				tree = append(tree, node)
+				continue
+			}
+			if option.skipped(d.Key()) {
+				node.Reason = reasonSkipped
+				tree = append(tree, node)
+				continue
+			}
+			seen[d.Key()] = empty
+			c, err := m.Commands.Lookup(d.Key())
+			if err != nil {
+				if d.Optional && !d.Mandatory {
+					node.Reason = reasonNotFound
+					tree = append(tree, node)
+					continue
+				}
+				return nil, m.suggest(err, d.Key())
+			}
+			node.Cmd = c
+			node.Selected = option.selected(d.Key())
+			if !option.Direct {
+				if node.Deps, err = traverse(c.Deps); err != nil {
+					return nil, err
+				}
+			}
+			tree = append(tree, node)
+		}
+		return tree, nil
+	}
+	return traverse(deps)
 }
 
 type ctree struct {
@@ -48,10 +139,22 @@ func createTree(root executer) (ctree, error) {
 }
 
 func (c *ctree) Execute(ctx context.Context, stdout, stderr io.Writer) error {
-	go io.Copy(stdout, c.stdout)
-	go io.Copy(stderr, c.stderr)
-
-	return c.root.Execute(ctx, c.Stdout(), c.Stderr())
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(stdout, c.stdout)
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(stderr, c.stderr)
+	}()
+
+	err := c.root.Execute(ctx, c.Stdout(), c.Stderr())
+	c.stdout.W.Close()
+	c.stderr.W.Close()
+	wg.Wait()
+	return err
 }
 
 func (c *ctree) Stdout() io.Writer {
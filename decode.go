@@ -6,7 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
+	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -14,6 +17,7 @@ import (
 
 	"github.com/midbel/maestro/internal/copyslice"
 	"github.com/midbel/maestro/internal/env"
+	"github.com/midbel/maestro/internal/ordered"
 	"github.com/midbel/maestro/schedule"
 	"github.com/midbel/shlex"
 	"github.com/midbel/tish"
@@ -24,6 +28,12 @@ const (
 	metaNamespace  = "NAMESPACE"
 	metaWorkDir    = "WORKDIR"
 	metaTrace      = "TRACE"
+	metaHistory    = "HISTORY"
+	metaAudit      = "AUDIT_LOG"
+	metaCache      = "CACHE"
+	metaExport     = "EXPORT_FILTER"
+	metaIgnore     = "IGNORE_FILES"
+	metaInclude    = "INCLUDE_PATH"
 	metaAll        = "ALL"
 	metaDefault    = "DEFAULT"
 	metaBefore     = "BEFORE"
@@ -42,6 +52,18 @@ const (
 	metaParallel   = "SSH_PARALLEL"
 	metaCertFile   = "HTTP_CERT_FILE"
 	metaKeyFile    = "HTTP_CERT_KEY"
+	metaSecret     = "HTTP_WEBHOOK_SECRET"
+	metaTokens     = "HTTP_TOKENS"
+	metaProxies    = "HTTP_TRUSTED_PROXIES"
+	metaSmtpHost   = "SMTP_HOST"
+	metaSmtpUser   = "SMTP_USER"
+	metaSmtpPass   = "SMTP_PASSWORD"
+	metaSmtpFrom   = "SMTP_FROM"
+	metaEnvFile    = "ENVFILE"
+	metaSpill      = "SPILL_THRESHOLD"
+	metaWorkers    = "SCHEDULE_WORKERS"
+	metaQueue      = "SCHEDULE_QUEUE"
+	metaGrace      = "SCHEDULE_GRACE"
 )
 
 const (
@@ -49,6 +71,9 @@ const (
 	propShort    = "short"
 	propTags     = "tag"
 	propRetry    = "retry"
+	propDelay    = "delay"
+	propBackoff  = "backoff"
+	propMaxDelay = "max_delay"
 	propWorkDir  = "workdir"
 	propTimeout  = "timeout"
 	propHosts    = "hosts"
@@ -56,16 +81,75 @@ const (
 	propArg      = "args"
 	propAlias    = "alias"
 	propSchedule = "schedule"
+	propInherit  = "inherit_env"
+	propExpect   = "expect"
+	propMaxConc  = "max_concurrent"
+	propPolicy   = "concurrent_policy"
+	propRate     = "rate_limit"
+	propDebounce = "debounce"
+	propSecret   = "webhook_secret"
+	propHttpMap  = "http_map"
+	propEnvFile  = "envfile"
+	propUpload   = "upload"
+	propSources  = "sources"
+	propTargets  = "targets"
+	propDownload = "download"
+	propStdin    = "stdin"
+	propUmask    = "umask"
+	propUser     = "user"
+	propEval     = "eval"
+	propConfirm  = "confirm"
+	propSandbox  = "sandbox"
+	propNoPrivs  = "no_new_privs"
+	propSeccomp  = "seccomp"
+	propCost     = "cost"
+	propBlackout = "blackout"
+	propNeedsEnv = "needs_env"
+)
+
+const seccompDefault = "default"
+
+const (
+	evalEager = "eager"
+	evalLazy  = "lazy"
+)
+
+const (
+	hostAddr     = "addr"
+	hostPort     = "port"
+	hostUser     = "user"
+	hostPass     = "password"
+	hostIdentity = "identity"
+)
+
+const (
+	tokenName     = "name"
+	tokenValue    = "token"
+	tokenCommands = "commands"
+	tokenTags     = "tags"
+	tokenView     = "view"
+)
+
+const (
+	expectCode   = "code"
+	expectOutput = "output"
+	expectFiles  = "files"
 )
 
 const (
 	schedTime              = "time"
 	schedOverlap           = "overlap"
 	schedNotify            = "notify"
+	schedNotifyOn          = "notify_on"
+	schedNotifyTemplate    = "notify_template"
 	schedArgs              = "args"
 	schedEnv               = "env"
 	schedOut               = "stdout"
 	schedErr               = "stderr"
+	schedJitter            = "jitter"
+	schedMaxRuns           = "maxruns"
+	schedBackoff           = "backoff"
+	schedBlackout          = "blackout"
 	schedRedirectFile      = "file"
 	schedRedirectCompress  = "compress"
 	schedRedirectDuplicate = "duplicate"
@@ -78,15 +162,20 @@ const (
 	optRequired = "required"
 	optDefault  = "default"
 	optFlag     = "flag"
+	optList     = "list"
 	optHelp     = "help"
 	optValid    = "check"
+	optExcl     = "exclusive"
 )
 
 type Decoder struct {
 	locals *env.Env
-	env    map[string]string
-	alias  map[string]string
+	env    *ordered.Map[string, string]
+	alias  *ordered.Map[string, string]
 	frames []*frame
+
+	secrets    secretSet
+	keepScript bool
 }
 
 func Decode(r io.Reader) (*Maestro, error) {
@@ -106,9 +195,10 @@ func NewDecoderWithEnv(r io.Reader, ev *env.Env) (*Decoder, error) {
 		ev = env.EmptyEnv()
 	}
 	d := Decoder{
-		locals: ev,
-		env:    make(map[string]string),
-		alias:  make(map[string]string),
+		locals:  ev,
+		env:     ordered.New[string, string](),
+		alias:   ordered.New[string, string](),
+		secrets: make(secretSet),
 	}
 	if err := d.push(r); err != nil {
 		return nil, err
@@ -124,32 +214,53 @@ func (d *Decoder) Decode() (*Maestro, error) {
 func (d *Decoder) decode(mst *Maestro) error {
 	d.skipNL()
 	for !d.done() {
-		var err error
-		switch d.curr().Type {
-		case Ident:
-			if d.peek().IsAssign() {
-				err = d.decodeVariable()
-				break
-			}
-			err = d.decodeCommand(mst)
-		case Hidden:
-			err = d.decodeCommand(mst)
-		case Meta:
-			err = d.decodeMeta(mst)
-		case Keyword:
-			err = d.decodeKeyword(mst)
-		case Comment:
-			d.next()
-		default:
-			err = d.unexpected()
-		}
-		if err != nil {
+		if err := d.decodeStatement(mst); err != nil {
 			return err
 		}
 	}
+	for _, c := range mst.Commands.Values() {
+		if len(c.Filter) == 0 {
+			c.Filter = mst.MetaExec.ExportFilter
+		}
+		if len(mst.MetaExec.EnvFiles) > 0 {
+			c.EnvFiles = append(append([]EnvFile{}, mst.MetaExec.EnvFiles...), c.EnvFiles...)
+		}
+		for i, d := range c.Deps {
+			if d.root || d.Space != "" || c.Space == "" {
+				continue
+			}
+			if _, ok := mst.Commands.Get(joinSpace(c.Space, d.Name)); ok {
+				c.Deps[i].Space = c.Space
+			}
+		}
+		mst.Commands.Put(c)
+	}
 	return nil
 }
 
+func (d *Decoder) decodeStatement(mst *Maestro) error {
+	var err error
+	switch d.curr().Type {
+	case Ident:
+		if d.peek().IsAssign() {
+			err = d.decodeVariable()
+			break
+		}
+		err = d.decodeCommand(mst)
+	case Hidden:
+		err = d.decodeCommand(mst)
+	case Meta:
+		err = d.decodeMeta(mst)
+	case Keyword:
+		err = d.decodeKeyword(mst)
+	case Comment:
+		d.next()
+	default:
+		err = d.unexpected()
+	}
+	return err
+}
+
 func (d *Decoder) decodeKeyword(mst *Maestro) error {
 	var err error
 	switch d.curr().Literal {
@@ -161,34 +272,146 @@ func (d *Decoder) decodeKeyword(mst *Maestro) error {
 		err = d.decodeDelete(mst)
 	case kwAlias:
 		err = d.decodeAlias(mst)
+	case kwGlobal:
+		err = d.decodeGlobal()
+	case kwRuns:
+		err = d.decodeRuns(mst)
+	case kwIfdef, kwIfndef:
+		err = d.decodeConditional(mst)
 	default:
 		err = d.unexpected()
 	}
 	return err
 }
 
+func (d *Decoder) decodeConditional(mst *Maestro) error {
+	kw := d.curr().Literal
+	d.next()
+	if d.curr().Type != Ident {
+		return d.unexpected()
+	}
+	ok := d.defined(d.curr().Literal)
+	if kw == kwIfndef {
+		ok = !ok
+	}
+	d.next()
+	if err := d.ensureEOL(); err != nil {
+		return err
+	}
+	if err := d.decodeBranch(mst, ok); err != nil {
+		return err
+	}
+	if d.isKeyword(kwElse) {
+		d.next()
+		if err := d.ensureEOL(); err != nil {
+			return err
+		}
+		if err := d.decodeBranch(mst, !ok); err != nil {
+			return err
+		}
+	}
+	if !d.isKeyword(kwEndif) {
+		return d.unexpected()
+	}
+	d.next()
+	if d.done() {
+		return nil
+	}
+	return d.ensureEOL()
+}
+
+func (d *Decoder) decodeBranch(mst *Maestro, ok bool) error {
+	var depth int
+	for !d.done() {
+		curr := d.curr()
+		if curr.Type == Keyword {
+			switch curr.Literal {
+			case kwElse, kwEndif:
+				if depth == 0 {
+					return nil
+				}
+				if curr.Literal == kwEndif {
+					depth--
+				}
+			case kwIfdef, kwIfndef:
+				if !ok {
+					depth++
+				}
+			}
+		}
+		if !ok {
+			d.next()
+			continue
+		}
+		if err := d.decodeStatement(mst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Decoder) defined(ident string) bool {
+	if d.locals.Defined(ident) {
+		return true
+	}
+	_, ok := os.LookupEnv(ident)
+	return ok
+}
+
+func (d *Decoder) isKeyword(kw string) bool {
+	curr := d.curr()
+	return curr.Type == Keyword && curr.Literal == kw
+}
+
 func (d *Decoder) decodeInclude(mst *Maestro) error {
 	type include struct {
 		file     string
+		space    string
 		optional bool
 	}
+	isAs := func(tok Token) bool {
+		return tok.Type == Ident && tok.Literal == kwAs
+	}
 	decode := func() (include, error) {
 		var (
 			str []string
 			inc include
 		)
 		for !d.done() && d.curr().IsValue() {
-			vs, err := d.decodeValue()
-			if err != nil {
-				return inc, err
+			if len(str) > 0 && isAs(d.curr()) {
+				break
 			}
-			str = append(str, vs...)
+			switch curr := d.curr(); {
+			case curr.IsVariable():
+				vs, err := d.locals.Resolve(curr.Literal)
+				if err != nil {
+					return inc, err
+				}
+				str = append(str, vs...)
+			case curr.Type == Quote:
+				s, err := d.decodeQuote()
+				if err != nil {
+					return inc, err
+				}
+				str = append(str, s)
+			default:
+				str = append(str, curr.Literal)
+			}
+			d.next()
 		}
 		inc.file = strings.Join(str, "")
 		if d.curr().Type == Optional {
 			inc.optional = true
 			d.next()
 		}
+		if isAs(d.curr()) {
+			d.next()
+			if d.curr().Type != Ident {
+				return inc, d.unexpected()
+			}
+			inc.space = d.curr().Literal
+			d.next()
+		}
 		return inc, d.ensureEOL()
 	}
 	d.next()
@@ -223,14 +446,14 @@ func (d *Decoder) decodeInclude(mst *Maestro) error {
 		return d.unexpected()
 	}
 	for i := range list {
-		file, ok := mst.Includes.Exists(list[i].file)
-		if !ok {
+		file, err := mst.Includes.Exists(list[i].file)
+		if err != nil {
 			if list[i].optional {
 				continue
 			}
-			return fmt.Errorf("%s: file does not exists in %s", file, mst.Includes)
+			return err
 		}
-		if err := d.decodeFile(file); err != nil {
+		if err := d.decodeFile(file, list[i].space); err != nil {
 			if list[i].optional {
 				continue
 			}
@@ -240,22 +463,36 @@ func (d *Decoder) decodeInclude(mst *Maestro) error {
 	return nil
 }
 
-func (d *Decoder) decodeFile(file string) error {
+func (d *Decoder) decodeFile(file, space string) error {
 	r, err := os.Open(file)
 	if err != nil {
 		return err
 	}
 	defer r.Close()
-	return d.push(r)
+	var rs io.Reader = r
+	if isYAML(file) {
+		if rs, err = readYAML(r); err != nil {
+			return err
+		}
+	}
+	parent := d.currentSpace()
+	if err := d.push(rs); err != nil {
+		return err
+	}
+	d.setFile(file)
+	d.setSpace(joinSpace(parent, space))
+	d.skipNL()
+	return nil
 }
 
 func (d *Decoder) decodeExport(msg *Maestro) error {
 	decode := func() error {
 		ident := d.curr()
 		d.next()
-		if d.curr().Type != Assign {
+		if !d.curr().IsAssign() {
 			return d.unexpected()
 		}
+		op := d.curr().Type
 		d.next()
 		if !d.curr().IsValue() {
 			return d.unexpected()
@@ -266,10 +503,24 @@ func (d *Decoder) decodeExport(msg *Maestro) error {
 				return err
 			}
 			if len(vs) > 0 {
-				d.env[ident.Literal] = vs[0]
+				exportValue(d.env, op, ident.Literal, vs[0])
+			}
+			if d.secrets.Has(d.curr().Literal) {
+				d.secrets[ident.Literal] = append(d.secrets[ident.Literal], vs...)
 			}
 		} else {
-			d.env[ident.Literal] = d.curr().Literal
+			str := d.curr().Literal
+			if d.curr().Type == Quote {
+				var err error
+				if str, err = d.decodeQuote(); err != nil {
+					return err
+				}
+			}
+			vs, err := d.secrets.decrypt(ident.Literal, []string{str})
+			if err != nil {
+				return err
+			}
+			exportValue(d.env, op, ident.Literal, vs[0])
 		}
 		d.next()
 		d.skipBlank()
@@ -278,9 +529,7 @@ func (d *Decoder) decodeExport(msg *Maestro) error {
 	d.next()
 	switch d.curr().Type {
 	case Ident:
-		if err := decode(); err != nil {
-			return err
-		}
+		return decode()
 	case BegList:
 		d.next()
 		if err := d.ensureEOL(); err != nil {
@@ -328,6 +577,7 @@ func (d *Decoder) decodeAlias(mst *Maestro) error {
 		if !d.curr().IsAssign() {
 			return d.unexpected()
 		}
+		op := d.curr().Type
 		d.next()
 		for !d.done() {
 			vs, err := d.decodeValue()
@@ -340,7 +590,70 @@ func (d *Decoder) decodeAlias(mst *Maestro) error {
 			}
 			d.skipBlank()
 		}
-		d.alias[ident.Literal] = strings.Join(str, " ")
+		assignValue(d.alias, op, ident.Literal, strings.Join(str, " "))
+		return d.ensureEOL()
+	}
+	d.next()
+	switch d.curr().Type {
+	case Ident:
+		return decode()
+	case BegList:
+		d.next()
+		if err := d.ensureEOL(); err != nil {
+			return err
+		}
+		for !d.done() && d.curr().Type != EndList {
+			if err := decode(); err != nil {
+				return err
+			}
+		}
+		if d.curr().Type != EndList {
+			return d.unexpected()
+		}
+		d.next()
+		return d.ensureEOL()
+	default:
+		return d.unexpected()
+	}
+}
+
+func (d *Decoder) decodeRuns(mst *Maestro) error {
+	decode := func() error {
+		var (
+			ident = d.curr()
+			str   []string
+		)
+		d.next()
+		if d.curr().Type != Assign {
+			return d.unexpected()
+		}
+		d.next()
+		if d.curr().Type != Ident {
+			return fmt.Errorf("%s: no command given to run", ident.Literal)
+		}
+		name := d.curr().Literal
+		d.next()
+		if d.curr().Type == Resolution {
+			d.next()
+			if d.curr().Type != Ident {
+				return d.unexpected()
+			}
+			name = joinSpace(name, d.curr().Literal)
+			d.next()
+		}
+		str = append(str, name)
+		for !d.done() && d.curr().IsBlank() {
+			d.skipBlank()
+			vs, err := d.decodeValue()
+			if err != nil {
+				return err
+			}
+			str = append(str, vs...)
+		}
+		if _, ok := mst.Runs[ident.Literal]; ok {
+			return fmt.Errorf("%s: run already defined", ident.Literal)
+		}
+		mst.Runs[ident.Literal] = str
 		return d.ensureEOL()
 	}
 	d.next()
@@ -411,19 +724,20 @@ func (d *Decoder) decodeObject(decode func() error) error {
 }
 
 func (d *Decoder) decodeAssignment() error {
-	var (
-		ident  = d.curr()
-		assign bool
-	)
+	return d.decodeAssignmentTo(d.locals)
+}
+
+func (d *Decoder) decodeAssignmentTo(target *env.Env) error {
+	ident := d.curr()
 	d.next()
 	if !d.curr().IsAssign() {
 		return d.unexpected()
 	}
-	assign = d.curr().Type == Assign
+	op := d.curr().Type
 	d.next()
 
 	if d.curr().Type == BegList {
-		if !assign {
+		if op != Assign {
 			return d.unexpected()
 		}
 		return d.decodeObjectVariable(ident.Literal)
@@ -441,22 +755,69 @@ func (d *Decoder) decodeAssignment() error {
 		}
 		d.skipBlank()
 	}
-	if assign {
-		d.locals.Define(ident.Literal, str)
-	} else {
-		xs, _ := d.locals.Resolve(ident.Literal)
-		d.locals.Define(ident.Literal, append(xs, str...))
+	str, err := d.secrets.decrypt(ident.Literal, str)
+	if err != nil {
+		return err
+	}
+	xs, _ := target.Resolve(ident.Literal)
+	switch op {
+	case Assign:
+		target.Define(ident.Literal, str)
+	case Append:
+		target.Define(ident.Literal, append(xs, str...))
+	case Default:
+		if !target.Defined(ident.Literal) {
+			target.Define(ident.Literal, str)
+		}
 	}
 	return nil
 }
 
 func (d *Decoder) decodeVariable() error {
+	if d.peek().Type == Assign {
+		if err := d.shadowed(d.curr().Literal); err != nil {
+			return err
+		}
+	}
 	if err := d.decodeAssignment(); err != nil {
 		return err
 	}
 	return d.ensureEOL()
 }
 
+func (d *Decoder) decodeGlobal() error {
+	d.next()
+	if d.curr().Type != Ident || !d.peek().IsAssign() {
+		return d.unexpected()
+	}
+	target := d.locals
+	if z := len(d.frames); z > 1 {
+		target = d.frames[z-2].locals
+	}
+	if err := d.decodeAssignmentTo(target); err != nil {
+		return err
+	}
+	return d.ensureEOL()
+}
+
+func (d *Decoder) shadowed(ident string) error {
+	z := len(d.frames)
+	if z <= 1 || d.locals.Has(ident) {
+		return nil
+	}
+	for i := z - 2; i >= 0; i-- {
+		if !d.frames[i].locals.Has(ident) {
+			continue
+		}
+		file := d.frames[i].file
+		if file == "" {
+			file = "parent file"
+		}
+		return fmt.Errorf("%s: variable already defined in %s (use global to redefine it)", ident, file)
+	}
+	return nil
+}
+
 func (d *Decoder) decodeScript(line string) ([]string, error) {
 	var (
 		buf  bytes.Buffer
@@ -477,13 +838,17 @@ func (d *Decoder) decodeCommand(mst *Maestro) error {
 	if hidden = d.curr().Type == Hidden; hidden {
 		d.next()
 	}
-	cmd, err := NewCommandSettingsWithLocals(d.curr().Literal, d.locals)
+	cmd, err := NewCommandSettingsWithLocals(d.curr().Literal, d.locals.Copy())
 	if err != nil {
 		return err
 	}
-	cmd.Ev = copyslice.CopyMap[string, string](d.env)
-	cmd.As = copyslice.CopyMap[string, string](d.alias)
+	cmd.Ev = d.env.Copy()
+	cmd.As = d.alias.Copy()
+	cmd.secrets = d.secrets.Copy()
 	cmd.Visible = !hidden
+	cmd.File = d.currentFile()
+	cmd.Space = d.currentSpace()
+	cmd.Pos = d.curr().Position
 	d.next()
 	if d.curr().Type == BegList {
 		if err := d.decodeCommandProperties(&cmd); err != nil {
@@ -499,23 +864,400 @@ func (d *Decoder) decodeCommand(mst *Maestro) error {
 		if err := d.decodeCommandScripts(&cmd, mst); err != nil {
 			return err
 		}
-	}
-	if err := mst.Register(cmd); err != nil {
+	}
+	if err := mst.Register(cmd); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *Decoder) decodeCommandProperties(cmd *CommandSettings) error {
+	return d.decodeObject(func() error {
+		var (
+			curr = d.curr()
+			err  error
+		)
+		switch {
+		case curr.Type == Ident:
+		case curr.Type == Keyword && curr.Literal == kwAlias && d.peek().IsAssign():
+		case curr.Type == Keyword:
+			return d.decodeCommandScope(cmd)
+		default:
+			return d.unexpected()
+		}
+		d.next()
+		if !d.curr().IsAssign() {
+			return d.unexpected()
+		}
+		op := d.curr().Type
+		if op != Assign && curr.Literal != propTags && curr.Literal != propHosts && curr.Literal != propAlias {
+			return fmt.Errorf("%s: only = can be used with this property", curr.Literal)
+		}
+		d.next()
+		var list []string
+		switch curr.Literal {
+		default:
+			err = fmt.Errorf("%s: unknown command property", curr.Literal)
+		case propShort:
+			cmd.Short, err = d.parseString()
+		case propHelp:
+			cmd.Desc, err = d.parseString()
+		case propTags:
+			list, err = d.parseComputedList()
+			cmd.Categories = mergeValues(op, cmd.Categories, list)
+		case propRetry:
+			cmd.Retry, err = d.parseInt()
+		case propDelay:
+			cmd.Delay, err = d.parseDuration()
+		case propBackoff:
+			cmd.Backoff, err = d.parseFloat()
+			if err == nil && cmd.Backoff < 1 {
+				err = fmt.Errorf("%s: multiplier should be greater or equal to 1", propBackoff)
+			}
+		case propMaxDelay:
+			cmd.MaxDelay, err = d.parseDuration()
+		case propTimeout:
+			cmd.Timeout, err = d.parseDuration()
+		case propWorkDir:
+			d.keepScript = true
+			cmd.WorkDir, err = d.parseString()
+			d.keepScript = false
+		case propUmask:
+			if cmd.Umask, err = d.parseString(); err != nil {
+				break
+			}
+			if _, err = parseUmask(cmd.Umask); err != nil {
+				err = fmt.Errorf("%s: %w", propUmask, err)
+			}
+		case propUser:
+			cmd.User, err = d.parseString()
+		case propEval:
+			var str string
+			if str, err = d.parseString(); err != nil {
+				break
+			}
+			switch str {
+			case evalEager, evalLazy:
+				cmd.Lazy = str == evalLazy
+			default:
+				err = fmt.Errorf("%s: unknown evaluation mode (use %s or %s)", str, evalEager, evalLazy)
+			}
+		case propHosts:
+			if d.curr().Type == BegList {
+				list, err = d.decodeCommandHosts(cmd)
+			} else {
+				list, err = d.parseComputedList()
+			}
+			cmd.Hosts = mergeValues(op, cmd.Hosts, list)
+			sort.Strings(cmd.Hosts)
+		case propAlias:
+			if d.curr().Type == BegList {
+				list, err = d.decodeCommandPresets(cmd)
+			} else {
+				list, err = d.parseStringList()
+			}
+			cmd.Alias = mergeValues(op, cmd.Alias, list)
+			sort.Strings(cmd.Alias)
+		case propArg:
+			cmd.Args, err = d.decodeCommandArguments()
+		case propOpts:
+			err = d.decodeCommandOptions(cmd)
+		case propSchedule:
+			err = d.decodeCommandSchedule(cmd)
+		case propConfirm:
+			cmd.Confirm, err = d.parseBool()
+		case propNeedsEnv:
+			cmd.NeedsEnv, err = d.parseStringList()
+		case propBlackout:
+			cmd.Blackout, err = d.parseBlackout()
+		case propCost:
+			cmd.Cost, err = d.parseFloat()
+			if err == nil && cmd.Cost < 0 {
+				err = fmt.Errorf("%s: cost should not be negative", propCost)
+			}
+		case propSandbox:
+			cmd.Sandbox, err = d.parseBool()
+		case propNoPrivs:
+			cmd.NoNewPrivs, err = d.parseBool()
+		case propSeccomp:
+			if cmd.Seccomp, err = d.parseStringList(); err != nil {
+				break
+			}
+			if err = checkSeccomp(cmd.Seccomp); err != nil {
+				err = fmt.Errorf("%s: %w", propSeccomp, err)
+			}
+		case propInherit:
+			cmd.Inherit, err = d.parseBool()
+		case propExpect:
+			cmd.Expect, err = d.decodeCommandExpect()
+		case propStdin:
+			cmd.Stdin, err = d.decodeCommandStdin()
+		case propMaxConc:
+			cmd.MaxConcurrent, err = d.parseInt()
+		case propRate:
+			cmd.RateLimit, err = d.parseInt()
+		case propDebounce:
+			cmd.Debounce, err = d.parseDuration()
+		case propSecret:
+			cmd.Secret, err = d.parseString()
+		case propHttpMap:
+			cmd.HttpMap, err = d.decodeHttpMap()
+		case propEnvFile:
+			var list []EnvFile
+			list, err = d.parseEnvFiles()
+			cmd.EnvFiles = append(cmd.EnvFiles, list...)
+		case propSources:
+			cmd.Sources, err = d.parseStringList()
+		case propTargets:
+			cmd.Targets, err = d.parseStringList()
+		case propUpload, propDownload:
+			if list, err = d.parseStringList(); err != nil {
+				break
+			}
+			for _, str := range list {
+				t, err := parseTransfer(str, curr.Literal == propUpload)
+				if err != nil {
+					return err
+				}
+				if curr.Literal == propUpload {
+					cmd.Uploads = append(cmd.Uploads, t)
+				} else {
+					cmd.Downloads = append(cmd.Downloads, t)
+				}
+			}
+		case propPolicy:
+			cmd.Policy, err = d.parseString()
+			if err == nil && cmd.Policy != PolicyQueue && cmd.Policy != PolicyReject {
+				err = fmt.Errorf("%s: unknown policy (use %s or %s)", cmd.Policy, PolicyQueue, PolicyReject)
+			}
+		}
+		return err
+	})
+}
+
+func (d *Decoder) decodeCommandScope(cmd *CommandSettings) error {
+	var (
+		kw  = d.curr()
+		set func(rune, string, string)
+	)
+	d.next()
+	switch kw.Literal {
+	case kwExport:
+		set = func(op rune, key, value string) {
+			exportValue(cmd.Ev, op, key, value)
+		}
+	case kwAlias:
+		set = func(op rune, key, value string) {
+			assignValue(cmd.As, op, key, value)
+		}
+	case kwDelete:
+		for d.curr().Type == Ident {
+			cmd.Ev.Delete(d.curr().Literal)
+			cmd.As.Delete(d.curr().Literal)
+			d.next()
+		}
+		return nil
+	default:
+		return d.unexpected()
+	}
+	decode := func() error {
+		ident := d.curr()
+		if ident.Type != Ident {
+			return d.unexpected()
+		}
+		d.next()
+		if !d.curr().IsAssign() {
+			return d.unexpected()
+		}
+		op := d.curr().Type
+		d.next()
+		str, err := d.parseString()
+		if err == nil && kw.Literal == kwExport {
+			var vs []string
+			if vs, err = cmd.secrets.decrypt(ident.Literal, []string{str}); err == nil {
+				str = vs[0]
+			}
+		}
+		if err == nil {
+			set(op, ident.Literal, str)
+		}
+		return err
+	}
+	if d.curr().Type == BegList {
+		return d.decodeObject(decode)
+	}
+	return decode()
+}
+
+func (d *Decoder) decodeCommandExpect() (CommandExpect, error) {
+	var expect CommandExpect
+	if d.curr().Type != BegList {
+		return expect, d.unexpected()
+	}
+	err := d.decodeObject(func() error {
+		var (
+			curr = d.curr()
+			err  error
+		)
+		if curr.Type != Ident {
+			return d.unexpected()
+		}
+		d.next()
+		if d.curr().Type != Assign {
+			return d.unexpected()
+		}
+		d.next()
+		switch curr.Literal {
+		default:
+			return fmt.Errorf("%s: unknown expect property", curr.Literal)
+		case expectCode:
+			expect.Code, err = d.parseInt()
+		case expectOutput:
+			var str string
+			if str, err = d.parseString(); err == nil && str != "" {
+				expect.Output, err = regexp.Compile(str)
+			}
+		case expectFiles:
+			expect.Files, err = d.parseStringList()
+		}
+		return err
+	})
+	return expect, err
+}
+
+func (d *Decoder) decodeCommandStdin() (CommandStdin, error) {
+	var in CommandStdin
+	if d.curr().Type == Heredoc {
+		in.Content = d.curr().Literal
+		d.next()
+		return in, nil
+	}
+	file, err := d.parseString()
+	if err != nil {
+		return in, err
+	}
+	if !filepath.IsAbs(file) && d.currentFile() != "" {
+		file = filepath.Join(filepath.Dir(d.currentFile()), file)
+	}
+	in.File = file
+	return in, nil
+}
+
+func (d *Decoder) decodeCommandHosts(cmd *CommandSettings) ([]string, error) {
+	var (
+		list []string
+		done bool
+	)
+	if cmd.HostSettings == nil {
+		cmd.HostSettings = make(map[string]HostSSH)
+	}
+	for !d.done() && !done {
+		if t := d.curr().Type; t != BegList {
+			if t == Ident || t == String {
+				return list, nil
+			}
+			return nil, d.unexpected()
+		}
+		host, err := d.decodeHostObject()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, host.Addr)
+		cmd.HostSettings[host.Addr] = host
+		switch d.curr().Type {
+		case Comma:
+			d.next()
+			d.skipComment()
+			d.skipNL()
+		case Eol:
+			d.skipNL()
+		case EndList:
+		default:
+			return nil, d.unexpected()
+		}
+		done = d.curr().Type == EndList
+	}
+	if d.curr().Type != EndList {
+		return nil, d.unexpected()
+	}
+	return list, nil
+}
+
+func (d *Decoder) decodeHttpTokens() ([]HttpToken, error) {
+	var list []HttpToken
+	for !d.done() {
+		if d.curr().Type != BegList {
+			return nil, d.unexpected()
+		}
+		tok, err := d.decodeTokenObject()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, tok)
+		if d.curr().Type != Comma {
+			break
+		}
+		d.next()
+		d.skipComment()
+		d.skipNL()
+	}
+	return list, nil
+}
+
+func (d *Decoder) decodeTokenObject() (HttpToken, error) {
+	var tok HttpToken
+	err := d.decodeObject(func() error {
+		var (
+			curr = d.curr()
+			err  error
+		)
+		if curr.Type != Ident {
+			return d.unexpected()
+		}
+		d.next()
+		if d.curr().Type != Assign {
+			return d.unexpected()
+		}
+		d.next()
+		switch curr.Literal {
+		default:
+			return fmt.Errorf("%s: unknown token property", curr.Literal)
+		case tokenName:
+			tok.Name, err = d.parseString()
+		case tokenValue:
+			tok.Token, err = d.parseString()
+		case tokenCommands:
+			tok.Commands, err = d.parseStringList()
+		case tokenTags:
+			tok.Tags, err = d.parseStringList()
+		case tokenView:
+			tok.View, err = d.parseBool()
+		}
 		return err
+	})
+	if err != nil {
+		return tok, err
 	}
-	return nil
+	if tok.Token == "" {
+		return tok, fmt.Errorf("%s: missing value of token", tokenValue)
+	}
+	if tok.Name == "" {
+		tok.Name = "anonymous"
+	}
+	return tok, nil
 }
 
-func (d *Decoder) decodeCommandProperties(cmd *CommandSettings) error {
-	return d.decodeObject(func() error {
+func (d *Decoder) decodeHostObject() (HostSSH, error) {
+	var (
+		host HostSSH
+		port string
+	)
+	err := d.decodeObject(func() error {
 		var (
 			curr = d.curr()
 			err  error
 		)
-		switch {
-		case curr.Type == Ident:
-		case curr.Type == Keyword && curr.Literal == kwAlias:
-		default:
+		if curr.Type != Ident {
 			return d.unexpected()
 		}
 		d.next()
@@ -525,32 +1267,111 @@ func (d *Decoder) decodeCommandProperties(cmd *CommandSettings) error {
 		d.next()
 		switch curr.Literal {
 		default:
-			err = fmt.Errorf("%s: unknown command property", curr.Literal)
-		case propShort:
-			cmd.Short, err = d.parseString()
-		case propHelp:
-			cmd.Desc, err = d.parseString()
-		case propTags:
-			cmd.Categories, err = d.parseStringList()
-		case propRetry:
-			cmd.Retry, err = d.parseInt()
-		case propTimeout:
-			cmd.Timeout, err = d.parseDuration()
-		case propHosts:
-			cmd.Hosts, err = d.parseStringList()
-			sort.Strings(cmd.Hosts)
-		case propAlias:
-			cmd.Alias, err = d.parseStringList()
-			sort.Strings(cmd.Alias)
-		case propArg:
-			cmd.Args, err = d.decodeCommandArguments()
-		case propOpts:
-			err = d.decodeCommandOptions(cmd)
-		case propSchedule:
-			err = d.decodeCommandSchedule(cmd)
+			return fmt.Errorf("%s: unknown host property", curr.Literal)
+		case hostAddr:
+			host.Addr, err = d.parseString()
+		case hostPort:
+			port, err = d.parseString()
+		case hostUser:
+			host.User, err = d.parseString()
+		case hostPass:
+			host.Pass, err = d.parseString()
+		case hostIdentity:
+			host.Key, err = d.parseSignerSSH()
+		}
+		return err
+	})
+	if err != nil {
+		return host, err
+	}
+	if host.Addr == "" {
+		return host, fmt.Errorf("%s: missing address of host", hostAddr)
+	}
+	if _, _, err := net.SplitHostPort(host.Addr); err != nil {
+		if port == "" {
+			port = "22"
+		}
+		host.Addr = net.JoinHostPort(host.Addr, port)
+	}
+	return host, nil
+}
+
+func (d *Decoder) decodeCommandPresets(cmd *CommandSettings) ([]string, error) {
+	var list []string
+	if cmd.Presets == nil {
+		cmd.Presets = make(map[string][]string)
+	}
+	err := d.decodeObject(func() error {
+		curr := d.curr()
+		if curr.Type != Ident {
+			return d.unexpected()
+		}
+		d.next()
+		if d.curr().Type != Assign {
+			return d.unexpected()
+		}
+		d.next()
+		str, err := d.parseString()
+		if err != nil {
+			return err
+		}
+		args, err := shlex.Split(strings.NewReader(str))
+		if err != nil {
+			return fmt.Errorf("%s: %w", curr.Literal, err)
+		}
+		cmd.Presets[curr.Literal] = args
+		list = append(list, curr.Literal)
+		return nil
+	})
+	return list, err
+}
+
+func (d *Decoder) decodeHttpMap() ([]HttpField, error) {
+	var list []HttpField
+	if d.curr().Type != BegList {
+		return nil, d.unexpected()
+	}
+	err := d.decodeObject(func() error {
+		curr := d.curr()
+		if curr.Type != Ident {
+			return d.unexpected()
+		}
+		d.next()
+		if d.curr().Type != Assign {
+			return d.unexpected()
+		}
+		d.next()
+		path, err := d.decodeJSONPath()
+		if err == nil {
+			list = append(list, HttpField{
+				Name: curr.Literal,
+				Path: path,
+			})
 		}
 		return err
 	})
+	return list, err
+}
+
+func (d *Decoder) decodeJSONPath() (string, error) {
+	if curr := d.curr(); curr.Type != Variable || curr.Literal != "" {
+		path, err := d.parseString()
+		if err == nil && !strings.HasPrefix(path, "$") {
+			err = fmt.Errorf("%s: path should start with $", path)
+		}
+		return path, err
+	}
+	d.next()
+	path := "$"
+	if d.curr().Type == Meta {
+		d.next()
+		if d.curr().Type != Ident && d.curr().Type != String {
+			return "", d.unexpected()
+		}
+		path += "." + d.curr().Literal
+		d.next()
+	}
+	return path, nil
 }
 
 func (d *Decoder) decodeCommandSchedule(cmd *CommandSettings) error {
@@ -608,11 +1429,27 @@ func (d *Decoder) decodeScheduleObject() (Schedule, error) {
 		default:
 			return fmt.Errorf("%s: unknown schedule property", curr.Literal)
 		case schedTime:
-			sched.Sched, err = d.parseCrontab()
+			sched.Time, sched.Sched, err = d.parseCrontab()
 		case schedOverlap:
 			sched.Overlap, err = d.parseBool()
 		case schedNotify:
-			sched.Notify, err = d.parseStringList()
+			if sched.Notify, err = d.parseStringList(); err != nil {
+				break
+			}
+			for _, n := range sched.Notify {
+				if err = checkNotifyTarget(n); err != nil {
+					break
+				}
+			}
+		case schedNotifyOn:
+			sched.NotifyOn, err = d.parseString()
+			switch sched.NotifyOn {
+			case NotifyFailure, NotifySuccess, NotifyAlways:
+			default:
+				err = fmt.Errorf("%s: invalid value for %s (%s, %s or %s expected)", sched.NotifyOn, schedNotifyOn, NotifyFailure, NotifySuccess, NotifyAlways)
+			}
+		case schedNotifyTemplate:
+			sched.NotifyTemplate, err = d.parseString()
 		case schedArgs:
 			sched.Args, err = d.parseStringList()
 		case schedEnv:
@@ -621,6 +1458,16 @@ func (d *Decoder) decodeScheduleObject() (Schedule, error) {
 			sched.Stdout, err = d.decodeScheduleRedirect()
 		case schedErr:
 			sched.Stderr, err = d.decodeScheduleRedirect()
+		case schedJitter:
+			sched.Jitter, err = d.parseDuration()
+		case schedMaxRuns:
+			var n int64
+			n, err = d.parseInt()
+			sched.MaxRuns = int(n)
+		case schedBackoff:
+			sched.Backoff, err = d.parseDuration()
+		case schedBlackout:
+			sched.Blackout, err = d.parseBlackout()
 		}
 		return err
 	})
@@ -673,17 +1520,42 @@ func (d *Decoder) decodeScheduleRedirect() (ScheduleRedirect, error) {
 func (d *Decoder) decodeCommandArguments() ([]CommandArg, error) {
 	var args []CommandArg
 	for !d.done() && d.curr().Type != Comma {
-		if d.curr().Type != Ident {
+		if t := d.curr().Type; t != Ident && t != String {
 			return nil, d.unexpected()
 		}
 		arg := CommandArg{
 			Name: d.curr().Literal,
 		}
+		switch {
+		case strings.HasSuffix(arg.Name, argVariadic):
+			arg.Name, arg.Variadic = strings.TrimSuffix(arg.Name, argVariadic), true
+		case strings.HasSuffix(arg.Name, argOptional):
+			arg.Name, arg.Optional = strings.TrimSuffix(arg.Name, argOptional), true
+		}
+		if !isIdentString(arg.Name) {
+			return nil, d.unexpected()
+		}
+		switch d.peek().Type {
+		case Optional:
+			d.next()
+			arg.Optional = true
+		case Variadic:
+			d.next()
+			arg.Variadic = true
+		}
+		if n := len(args); n > 0 {
+			switch last := args[n-1]; {
+			case last.Variadic:
+				return nil, fmt.Errorf("%s: no argument allowed after variadic argument %s", arg.Name, last.Name)
+			case last.Optional && arg.Required():
+				return nil, fmt.Errorf("%s: required argument can not follow optional argument %s", arg.Name, last.Name)
+			}
+		}
 		d.next()
 		d.skipBlank()
 		if d.curr().Type == BegList {
 			d.next()
-			list, err := d.decodeValidationRules(EndList)
+			list, _, err := d.decodeValidationRules(EndList)
 			if err != nil {
 				return nil, err
 			}
@@ -731,10 +1603,20 @@ func (d *Decoder) decodeOptionObject() (CommandOption, error) {
 			opt.Required, err = d.parseBool()
 		case optFlag:
 			opt.Flag, err = d.parseBool()
+		case optList:
+			opt.List, err = d.parseBool()
 		case optHelp:
 			opt.Help, err = d.parseString()
 		case optValid:
-			opt.Valid, err = d.decodeBasicValidateOption()
+			opt.Valid, opt.Choices, err = d.decodeBasicValidateOption()
+		case optExcl:
+			opt.Exclusive, err = d.parseString()
+		}
+		if err == nil && opt.Flag && opt.List {
+			err = fmt.Errorf("%s: option can not be both a flag and a list", curr.Literal)
+		}
+		if err == nil && opt.Required && opt.Exclusive != "" {
+			err = fmt.Errorf("%s: option can not be both required and exclusive", curr.Literal)
 		}
 		return err
 	})
@@ -778,7 +1660,7 @@ func (d *Decoder) decodeSpecialValidateOption(rule string) (ValidateFunc, error)
 		return nil, d.unexpected()
 	}
 	d.next()
-	list, err := d.decodeValidationRules(EndList)
+	list, _, err := d.decodeValidationRules(EndList)
 	if err != nil {
 		return nil, err
 	}
@@ -797,26 +1679,29 @@ func (d *Decoder) decodeSpecialValidateOption(rule string) (ValidateFunc, error)
 	return fn, nil
 }
 
-func (d *Decoder) decodeBasicValidateOption() (ValidateFunc, error) {
-	list, err := d.decodeValidationRules(Comma)
+func (d *Decoder) decodeBasicValidateOption() (ValidateFunc, []string, error) {
+	list, choices, err := d.decodeValidationRules(Comma)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	switch len(list) {
 	case 0:
-		return nil, fmt.Errorf("%s is given but rules are supplied", optValid)
+		return nil, nil, fmt.Errorf("%s is given but rules are supplied", optValid)
 	case 1:
-		return list[0], nil
+		return list[0], choices, nil
 	default:
-		return validateAll(list...), nil
+		return validateAll(list...), choices, nil
 	}
 }
 
-func (d *Decoder) decodeValidationRules(until rune) ([]ValidateFunc, error) {
-	var list []ValidateFunc
+func (d *Decoder) decodeValidationRules(until rune) ([]ValidateFunc, []string, error) {
+	var (
+		list    []ValidateFunc
+		choices []string
+	)
 	for !d.done() && d.curr().Type != until {
 		if d.curr().Type != Ident {
-			return nil, d.unexpected()
+			return nil, nil, d.unexpected()
 		}
 		var (
 			rule = d.curr().Literal
@@ -827,7 +1712,7 @@ func (d *Decoder) decodeValidationRules(until rune) ([]ValidateFunc, error) {
 		if rule == validNot || rule == validSome || rule == validAll {
 			fn, err := d.decodeSpecialValidateOption(rule)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			list = append(list, fn)
 			continue
@@ -841,32 +1726,39 @@ func (d *Decoder) decodeValidationRules(until rune) ([]ValidateFunc, error) {
 				case curr.IsVariable():
 					vs, err := d.locals.Resolve(curr.Literal)
 					if err != nil {
-						return nil, err
+						return nil, nil, err
 					}
 					args = append(args, vs...)
 				default:
-					return nil, d.unexpected()
+					return nil, nil, d.unexpected()
 				}
 				d.next()
 				d.skipBlank()
 			}
 			if d.curr().Type != EndList {
-				return nil, d.unexpected()
+				return nil, nil, d.unexpected()
 			}
 			d.next()
 			d.skipBlank()
 		}
+		if rule == validOneOf {
+			for _, a := range args {
+				if strings.TrimSpace(a) != "" {
+					choices = append(choices, a)
+				}
+			}
+		}
 		fn, err := getValidateFunc(rule, args)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		list = append(list, fn)
 	}
 	if d.curr().Type != until {
-		return nil, d.unexpected()
+		return nil, nil, d.unexpected()
 	}
 	d.next()
-	return list, nil
+	return list, choices, nil
 }
 
 func (d *Decoder) decodeCommandDependencies(cmd *CommandSettings) error {
@@ -876,7 +1768,7 @@ func (d *Decoder) decodeCommandDependencies(cmd *CommandSettings) error {
 			break
 		}
 		var optional, mandatory, space bool
-		for d.curr().Type != Ident {
+		for d.curr().Type != Ident && d.curr().Type != Resolution {
 			switch d.curr().Type {
 			case Mandatory:
 				mandatory = true
@@ -891,6 +1783,9 @@ func (d *Decoder) decodeCommandDependencies(cmd *CommandSettings) error {
 		case Resolution:
 			space = true
 			d.next()
+			if d.curr().Type != Ident {
+				return d.unexpected()
+			}
 		case Ident:
 		default:
 			return d.unexpected()
@@ -899,6 +1794,7 @@ func (d *Decoder) decodeCommandDependencies(cmd *CommandSettings) error {
 			Name:      d.curr().Literal,
 			Optional:  optional,
 			Mandatory: mandatory,
+			root:      space,
 		}
 		d.next()
 		if d.curr().Type == Resolution {
@@ -1031,6 +1927,42 @@ func (d *Decoder) decodeMeta(mst *Maestro) error {
 		mst.MetaExec.WorkDir, err = d.parseString()
 	case metaTrace:
 		mst.MetaExec.Trace, err = d.parseBool()
+	case metaHistory:
+		mst.MetaExec.History, err = d.parseString()
+	case metaAudit:
+		mst.MetaExec.Audit, err = d.parseString()
+	case metaCache:
+		mst.MetaExec.Cache, err = d.parseString()
+	case metaSpill:
+		var str string
+		if str, err = d.parseString(); err == nil {
+			mst.MetaExec.SpillThreshold, err = parseSize(str)
+		}
+	case metaWorkers:
+		mst.MetaExec.ScheduleWorkers, err = d.parseInt()
+	case metaQueue:
+		mst.MetaExec.ScheduleQueue, err = d.parseInt()
+	case metaGrace:
+		mst.MetaExec.ScheduleGrace, err = d.parseDuration()
+	case metaExport:
+		mst.MetaExec.ExportFilter, err = d.parseStringList()
+	case metaIgnore:
+		mst.MetaExec.IgnoreFiles, err = d.parseStringList()
+	case metaInclude:
+		var list []string
+		if list, err = d.parseStringList(); err != nil {
+			break
+		}
+		for i := range list {
+			if !filepath.IsAbs(list[i]) && d.currentFile() != "" {
+				list[i] = filepath.Join(filepath.Dir(d.currentFile()), list[i])
+			}
+		}
+		mst.Includes.Add(list...)
+	case metaEnvFile:
+		var list []EnvFile
+		list, err = d.parseEnvFiles()
+		mst.MetaExec.EnvFiles = append(mst.MetaExec.EnvFiles, list...)
 	case metaAll:
 		mst.MetaExec.All, err = d.parseStringList()
 	case metaDefault:
@@ -1067,6 +1999,22 @@ func (d *Decoder) decodeMeta(mst *Maestro) error {
 		mst.MetaHttp.CertFile, err = d.parseString()
 	case metaKeyFile:
 		mst.MetaHttp.KeyFile, err = d.parseString()
+	case metaSecret:
+		mst.MetaHttp.Secret, err = d.parseString()
+	case metaTokens:
+		mst.MetaHttp.Tokens, err = d.decodeHttpTokens()
+	case metaProxies:
+		if mst.MetaHttp.Proxies, err = d.parseStringList(); err == nil {
+			err = checkProxies(mst.MetaHttp.Proxies)
+		}
+	case metaSmtpHost:
+		mst.MetaSMTP.Host, err = d.parseString()
+	case metaSmtpUser:
+		mst.MetaSMTP.Username, err = d.parseString()
+	case metaSmtpPass:
+		mst.MetaSMTP.Password, err = d.parseString()
+	case metaSmtpFrom:
+		mst.MetaSMTP.From, err = d.parseString()
 	default:
 		return fmt.Errorf("%s: unknown/unsupported meta", meta)
 	}
@@ -1076,6 +2024,25 @@ func (d *Decoder) decodeMeta(mst *Maestro) error {
 	return err
 }
 
+func (d *Decoder) decodeMetas(mst *Maestro) error {
+	d.skipNL()
+	for !d.done() {
+		var err error
+		switch d.curr().Type {
+		case Meta:
+			err = d.decodeMeta(mst)
+		case Comment:
+			d.next()
+		default:
+			err = d.unexpected()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (d *Decoder) ensureNext(list ...rune) error {
 	for i := range list {
 		d.next()
@@ -1109,6 +2076,12 @@ func (d *Decoder) decodeQuote() (string, error) {
 				return "", fmt.Errorf("quote: too many values")
 			}
 			str = append(str, vs[0])
+		} else if d.curr().IsArithmetic() {
+			n, err := evalArithmetic(d.curr().Literal, d.locals)
+			if err != nil {
+				return "", err
+			}
+			str = append(str, strconv.FormatInt(n, 10))
 		} else {
 			str = append(str, d.curr().Literal)
 		}
@@ -1137,6 +2110,20 @@ func (d *Decoder) decodeValue() ([]string, error) {
 				return nil, err
 			}
 			tmp = append(tmp, s)
+		case curr.IsScript() && d.keepScript:
+			tmp = append(tmp, "$("+curr.Literal+")")
+		case curr.IsArithmetic():
+			n, err := evalArithmetic(curr.Literal, d.locals)
+			if err != nil {
+				return nil, err
+			}
+			tmp = append(tmp, strconv.FormatInt(n, 10))
+		case curr.Type == Ident && d.peek().Type == BegList:
+			vs, err := d.decodeCall()
+			if err != nil {
+				return nil, err
+			}
+			tmp = vs
 		default:
 			tmp = append(tmp, d.curr().Literal)
 		}
@@ -1150,6 +2137,47 @@ func (d *Decoder) decodeValue() ([]string, error) {
 	return ret, nil
 }
 
+func (d *Decoder) decodeCall() ([]string, error) {
+	name := d.curr().Literal
+	call, ok := builtins[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown function", name)
+	}
+	d.next()
+	d.next()
+	var args []string
+	for !d.done() && d.curr().Type != EndList {
+		vs, err := d.decodeValue()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, vs...)
+		switch d.curr().Type {
+		case Comma:
+			d.next()
+		case EndList:
+		default:
+			return nil, d.unexpected()
+		}
+	}
+	if d.curr().Type != EndList {
+		return nil, d.unexpected()
+	}
+	vs, err := call(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return vs, nil
+}
+
+func (d *Decoder) parseComputedList() ([]string, error) {
+	d.keepScript = true
+	defer func() {
+		d.keepScript = false
+	}()
+	return d.parseStringList()
+}
+
 func (d *Decoder) parseStringList() ([]string, error) {
 	if d.curr().Type == Eol || d.curr().Type == Comment {
 		return nil, nil
@@ -1169,6 +2197,69 @@ func (d *Decoder) parseStringList() ([]string, error) {
 	return str, nil
 }
 
+func (d *Decoder) parseBlackout() (Blackout, error) {
+	list, err := d.parseValueList()
+	if err != nil {
+		return nil, err
+	}
+	return parseBlackout(list)
+}
+
+func (d *Decoder) parseValueList() ([]string, error) {
+	if d.curr().Type != BegList {
+		return d.parseStringList()
+	}
+	d.next()
+	var list []string
+	for !d.done() && d.curr().Type != EndList {
+		switch curr := d.curr(); {
+		case curr.Type == Comma, curr.IsBlank(), curr.Type == Eol, curr.Type == Comment:
+			d.next()
+		case curr.IsValue():
+			xs, err := d.decodeValue()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, xs...)
+		default:
+			return nil, d.unexpected()
+		}
+	}
+	if d.curr().Type != EndList {
+		return nil, d.unexpected()
+	}
+	d.next()
+	return list, nil
+}
+
+func (d *Decoder) parseEnvFiles() ([]EnvFile, error) {
+	var list []EnvFile
+	for !d.done() && d.curr().IsValue() {
+		xs, err := d.decodeValue()
+		if err != nil {
+			return nil, err
+		}
+		for _, x := range xs {
+			var f EnvFile
+			f.File = strings.TrimSuffix(x, "?")
+			f.Optional = f.File != x
+			if !filepath.IsAbs(f.File) && d.currentFile() != "" {
+				f.File = filepath.Join(filepath.Dir(d.currentFile()), f.File)
+			}
+			list = append(list, f)
+		}
+		if d.curr().Type == Optional && len(list) > 0 {
+			list[len(list)-1].Optional = true
+			d.next()
+		}
+		if !d.curr().IsBlank() {
+			break
+		}
+		d.skipBlank()
+	}
+	return list, nil
+}
+
 func (d *Decoder) parseString() (string, error) {
 	if d.curr().Type == Eol || d.curr().Type == Comment {
 		return "", nil
@@ -1186,12 +2277,13 @@ func (d *Decoder) parseString() (string, error) {
 	return str[0], nil
 }
 
-func (d *Decoder) parseCrontab() (*schedule.Scheduler, error) {
+func (d *Decoder) parseCrontab() (string, *schedule.Scheduler, error) {
 	list, err := d.parseStringList()
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
-	return schedule.ScheduleFromList(list)
+	sched, err := schedule.ScheduleFromList(list)
+	return strings.Join(list, " "), sched, err
 }
 
 func (d *Decoder) parseKnownHosts() ([]hostEntry, error) {
@@ -1251,6 +2343,14 @@ func (d *Decoder) parseInt() (int64, error) {
 	return strconv.ParseInt(str, 0, 64)
 }
 
+func (d *Decoder) parseFloat() (float64, error) {
+	str, err := d.parseString()
+	if err != nil || str == "" {
+		return 0, err
+	}
+	return strconv.ParseFloat(str, 64)
+}
+
 func (d *Decoder) parseDuration() (time.Duration, error) {
 	str, err := d.parseString()
 	if err != nil || str == "" {
@@ -1314,8 +2414,9 @@ func (d *Decoder) push(r io.Reader) error {
 	if err != nil {
 		return err
 	}
-	d.frames = append(d.frames, f)
 	d.locals = env.EnclosedEnv(d.locals)
+	f.locals = d.locals
+	d.frames = append(d.frames, f)
 	return nil
 }
 
@@ -1346,6 +2447,34 @@ func (d *Decoder) peek() Token {
 	return t
 }
 
+func (d *Decoder) setFile(file string) {
+	if z := len(d.frames); z > 0 {
+		d.frames[z-1].file = file
+	}
+}
+
+func (d *Decoder) currentFile() string {
+	var file string
+	if z := len(d.frames); z > 0 {
+		file = d.frames[z-1].file
+	}
+	return file
+}
+
+func (d *Decoder) setSpace(space string) {
+	if z := len(d.frames); z > 0 {
+		d.frames[z-1].space = space
+	}
+}
+
+func (d *Decoder) currentSpace() string {
+	var space string
+	if z := len(d.frames); z > 0 {
+		space = d.frames[z-1].space
+	}
+	return space
+}
+
 func (d *Decoder) CurrentLine() string {
 	z := len(d.frames)
 	if z == 0 {
@@ -1360,9 +2489,12 @@ var (
 )
 
 type frame struct {
-	curr Token
-	peek Token
-	scan *Scanner
+	file   string
+	space  string
+	locals *env.Env
+	curr   Token
+	peek   Token
+	scan   *Scanner
 }
 
 func makeFrame(r io.Reader) (*frame, error) {
@@ -1420,3 +2552,53 @@ func (e UnexpectedError) Error() string {
 	}
 	return fmt.Sprintf("%s %q at %d:%d", errUnexpected, str, e.Invalid.Line, e.Invalid.Column)
 }
+
+func assignValue(set *ordered.Map[string, string], op rune, key, value string) {
+	switch op {
+	case Append:
+		if prev, ok := set.Get(key); ok && prev != "" {
+			value = prev + " " + value
+		}
+	case Default:
+		if set.Has(key) {
+			return
+		}
+	}
+	set.Set(key, value)
+}
+
+func exportValue(set *ordered.Map[string, string], op rune, key, value string) {
+	if _, ok := os.LookupEnv(key); ok && op == Default {
+		return
+	}
+	assignValue(set, op, key, value)
+}
+
+func mergeValues(op rune, list, values []string) []string {
+	switch op {
+	case Append:
+		return append(list, values...)
+	case Default:
+		if len(list) > 0 {
+			return list
+		}
+	}
+	return values
+}
+
+func quoteValue(str string, expand bool) (string, error) {
+	var (
+		single = strings.Contains(str, "'")
+		double = strings.Contains(str, "\"") || (!expand && strings.Contains(str, "$"))
+	)
+	switch {
+	case expand && strings.Contains(str, "$") && !double:
+		return fmt.Sprintf("\"%s\"", str), nil
+	case !single:
+		return fmt.Sprintf("'%s'", str), nil
+	case !double:
+		return fmt.Sprintf("\"%s\"", str), nil
+	default:
+		return "", fmt.Errorf("%s: value can not be quoted", str)
+	}
+}
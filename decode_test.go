@@ -1,11 +1,22 @@
 package maestro_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/midbel/maestro"
+	"github.com/midbel/tish"
 )
 
 func TestDecode(t *testing.T) {
@@ -65,3 +76,1817 @@ func testDecodeEndOfLine(t *testing.T) {
 		t.Fatalf("fail to decode multiline object: %s", err)
 	}
 }
+
+func TestExportFilter(t *testing.T) {
+	data := []struct {
+		Filter maestro.ExportFilter
+		Name   string
+		Want   bool
+	}{
+		{Filter: nil, Name: "HOME", Want: true},
+		{Filter: maestro.ExportFilter{"PATH", "GO*"}, Name: "GOPATH", Want: true},
+		{Filter: maestro.ExportFilter{"PATH", "GO*"}, Name: "HOME", Want: false},
+		{Filter: maestro.ExportFilter{"!AWS_*"}, Name: "AWS_SECRET", Want: false},
+		{Filter: maestro.ExportFilter{"!AWS_*"}, Name: "HOME", Want: true},
+		{Filter: maestro.ExportFilter{"*", "!GO*"}, Name: "GOPATH", Want: false},
+	}
+	for _, d := range data {
+		got := d.Filter.Keep(d.Name)
+		if got != d.Want {
+			t.Errorf("%s: filter %v: want %t, got %t", d.Name, d.Filter, d.Want, got)
+		}
+	}
+}
+
+func TestWorkDir(t *testing.T) {
+	dir := t.TempDir()
+	mst, err := maestro.Decode(strings.NewReader(fmt.Sprintf(workdir, dir)))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("create")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	ex, err := cmd.Prepare()
+	if err != nil {
+		t.Fatalf("fail to prepare command: %s", err)
+	}
+	var buf bytes.Buffer
+	ex.SetOut(&buf)
+	if err := ex.Execute(context.TODO(), nil); err != nil {
+		t.Fatalf("fail to execute: %s", err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != dir {
+		t.Errorf("working directory mismatched! want %q, got %q", dir, got)
+	}
+	i, err := os.Stat(filepath.Join(dir, "out.txt"))
+	if err != nil {
+		t.Fatalf("file not created in working directory: %s", err)
+	}
+	if perm := i.Mode().Perm(); perm != 0600 {
+		t.Errorf("permission mismatched! want %o, got %o", 0600, perm)
+	}
+	if _, err := maestro.Decode(strings.NewReader("cmd(umask = 999): {\n\techo\n}\n")); err == nil {
+		t.Errorf("invalid umask should be rejected")
+	}
+}
+
+const workdir = `
+create(
+	workdir = "%s",
+	umask   = 077,
+): {
+	pwd
+	touch out.txt
+}
+`
+
+func TestShellErrors(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(shellErrors))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	run := func(name string) error {
+		cmd, err := mst.Commands.Lookup(name)
+		if err != nil {
+			t.Fatalf("command not found: %s", err)
+		}
+		ex, err := cmd.Prepare()
+		if err != nil {
+			t.Fatalf("fail to prepare command: %s", err)
+		}
+		ex.SetOut(io.Discard)
+		ex.SetErr(io.Discard)
+		return ex.Execute(context.TODO(), nil)
+	}
+	var (
+		code tish.ExitCode
+		exit *exec.ExitError
+	)
+	if err := run("fail"); !errors.As(err, &code) || code != 1 {
+		t.Errorf("fail: exit code expected as is, got %v", err)
+	}
+	if err := run("isolated"); !(errors.As(err, &code) || errors.As(err, &exit)) || strings.HasPrefix(err.Error(), "line") {
+		t.Errorf("isolated: exit error expected as is, got %v", err)
+	}
+	if err := run("quit"); !errors.Is(err, tish.ErrExit) || err.Error() != "exit: 3" {
+		t.Errorf("quit: exit error expected as is, got %v", err)
+	}
+	if err := run("unknown"); err == nil || !strings.HasPrefix(err.Error(), "line 14: unknown: ") {
+		t.Errorf("unknown: position of the command expected, got %v", err)
+	}
+}
+
+const shellErrors = `
+fail: {
+	false
+}
+
+isolated(inherit_env = false): {
+	false
+}
+
+quit: {
+	exit 3
+}
+
+unknown: {
+	maestro-unknown-command
+}
+`
+
+func TestInheritEnv(t *testing.T) {
+	t.Setenv("MAESTRO_TEST_INHERIT", "parent")
+	mst, err := maestro.Decode(strings.NewReader(inheritEnv))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	run := func(name string) string {
+		cmd, err := mst.Commands.Lookup(name)
+		if err != nil {
+			t.Fatalf("command not found: %s", err)
+		}
+		ex, err := cmd.Prepare()
+		if err != nil {
+			t.Fatalf("fail to prepare command: %s", err)
+		}
+		var buf bytes.Buffer
+		ex.SetOut(&buf)
+		if err := ex.Execute(context.TODO(), nil); err != nil {
+			t.Fatalf("%s: fail to execute: %s", name, err)
+		}
+		return buf.String()
+	}
+	if got := run("isolated"); strings.TrimSpace(got) != "" {
+		t.Errorf("isolated: no variable expected, got %q", got)
+	}
+	got := run("inherited")
+	for _, str := range []string{"MAESTRO_TEST_INHERIT=parent", "TRACE=1"} {
+		if !strings.Contains(got, str) {
+			t.Errorf("inherited: %s not found in environment", str)
+		}
+	}
+}
+
+const inheritEnv = `
+isolated(
+	inherit_env = false,
+): {
+	printenv
+}
+
+inherited(
+	export TRACE = 1,
+): {
+	printenv
+}
+`
+
+const expect = `
+check(
+	tag    = "test:smoke",
+	expect = (
+		code   = 2,
+		output = "^done",
+		files  = out.txt,
+	),
+): {
+	echo done
+}
+`
+
+func TestDecodeExpect(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(expect))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("check")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	if !cmd.IsTest() {
+		t.Errorf("check should be recognized as a test command")
+	}
+	if cmd.Expect.Code != 2 {
+		t.Errorf("code mismatched! want 2, got %d", cmd.Expect.Code)
+	}
+	if cmd.Expect.Output == nil || !cmd.Expect.Output.MatchString("done") {
+		t.Errorf("output pattern not decoded properly")
+	}
+	if len(cmd.Expect.Files) != 1 || cmd.Expect.Files[0] != "out.txt" {
+		t.Errorf("files mismatched! got %v", cmd.Expect.Files)
+	}
+}
+
+func TestExpectExitCode(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(fmt.Sprintf(expectCode, t.TempDir())))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	file := filepath.Join(t.TempDir(), "report.tap")
+	if err := mst.Test([]string{"-o", file}); err != nil {
+		t.Fatalf("exit codes should match expectations: %s", err)
+	}
+}
+
+const expectCode = `
+quit(
+	tag    = test,
+	expect = (code = 3),
+): {
+	exit 3
+}
+
+fail(
+	tag    = test,
+	expect = (code = 1),
+): {
+	false
+}
+
+create(
+	tag     = test,
+	workdir = %s,
+	expect  = (files = out.txt),
+): {
+	touch out.txt
+}
+`
+
+func TestDecodePosition(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(expect))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("check")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	if cmd.Pos.Line != 2 {
+		t.Errorf("line mismatched! want 2, got %d", cmd.Pos.Line)
+	}
+}
+
+func TestDecodeIncludePath(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "inc.mf"), []byte(included), 0644); err != nil {
+		t.Fatalf("fail to write include file: %s", err)
+	}
+	mst, err := maestro.Decode(strings.NewReader(fmt.Sprintf(includePath, dir, "inc.mf")))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	if _, err := mst.Commands.Lookup("remote"); err != nil {
+		t.Errorf("command from included file not found: %s", err)
+	}
+	_, err = maestro.Decode(strings.NewReader(fmt.Sprintf(includePath, dir, "missing.mf")))
+	if err == nil {
+		t.Fatalf("expected error when including missing file")
+	}
+	if !errors.Is(err, fs.ErrNotExist) || !strings.Contains(err.Error(), dir) {
+		t.Errorf("error should report searched directories: %s", err)
+	}
+}
+
+const includePath = `
+.INCLUDE_PATH = "%s"
+
+include "%s"
+`
+
+const included = `
+remote: {
+	echo remote
+}
+`
+
+func TestDecodeNamespace(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "db.mf"), []byte(namespaced), 0644); err != nil {
+		t.Fatalf("fail to write include file: %s", err)
+	}
+	mst, err := maestro.Decode(strings.NewReader(fmt.Sprintf(namespace, dir)))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	for _, n := range []string{"migrate", "db::migrate", "db::reset"} {
+		if _, err := mst.Commands.Lookup(n); err != nil {
+			t.Errorf("%s: command not found: %s", n, err)
+		}
+	}
+	reset, _ := mst.Commands.Lookup("db::reset")
+	want := []string{"db::migrate", "migrate"}
+	if len(reset.Deps) != len(want) {
+		t.Fatalf("dependencies mismatched! want %d, got %d", len(want), len(reset.Deps))
+	}
+	for i := range want {
+		if got := reset.Deps[i].Key(); got != want[i] {
+			t.Errorf("dependency mismatched! want %s, got %s", want[i], got)
+		}
+	}
+	deploy, _ := mst.Commands.Lookup("deploy")
+	if len(deploy.Deps) != 1 || deploy.Deps[0].Key() != "db::migrate" {
+		t.Errorf("deploy should depend on db::migrate")
+	}
+}
+
+const namespace = `
+.INCLUDE_PATH = "%s"
+
+include "db.mf" as db
+
+migrate: {
+	echo migrate
+}
+
+deploy: db::migrate {
+	echo deploy
+}
+`
+
+const namespaced = `
+migrate: {
+	echo migrate
+}
+
+reset: migrate, ::migrate {
+	echo reset
+}
+`
+
+func TestDecodeListOption(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(listOption))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("deploy")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	if len(cmd.Options) != 1 || !cmd.Options[0].List {
+		t.Fatalf("option should be a list")
+	}
+	opt := cmd.Options[0]
+	opt.TargetList = []string{"a", "b"}
+	if err := opt.Validate(); err != nil {
+		t.Errorf("valid values should pass validation: %s", err)
+	}
+	opt.TargetList = []string{"a", "z"}
+	if err := opt.Validate(); err == nil {
+		t.Errorf("invalid value should fail validation")
+	}
+	opt.TargetList = nil
+	if err := opt.Validate(); err == nil {
+		t.Errorf("required list should fail validation when empty")
+	}
+	if _, err := maestro.Decode(strings.NewReader(listFlagOption)); err == nil {
+		t.Errorf("option can not be a flag and a list")
+	}
+}
+
+const listOption = `
+deploy(
+	options = (
+		short    = e,
+		long     = env,
+		list     = true,
+		required = true,
+		check    = oneof("a" "b" "c"),
+	),
+): {
+	echo $env
+}
+`
+
+const listFlagOption = `
+deploy(
+	options = (
+		short = e,
+		flag  = true,
+		list  = true,
+	),
+): {
+	echo $e
+}
+`
+
+func TestDecodeExclusiveOption(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(exclusiveOption))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("show")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	want := "show [-j/--json | -y/--yaml] [-v]"
+	if got := cmd.Usage(); got != want {
+		t.Errorf("usage mismatched! want %q, got %q", want, got)
+	}
+	ex, err := cmd.Prepare()
+	if err != nil {
+		t.Fatalf("fail to prepare command: %s", err)
+	}
+	for _, args := range [][]string{{"-j", "-v"}, {"-j", "--json"}, {"-y"}} {
+		if _, err := ex.Script(args); err != nil {
+			t.Errorf("%v: unexpected error: %s", args, err)
+		}
+	}
+	var usage maestro.UsageError
+	if _, err := ex.Script([]string{"--json", "-y"}); !errors.As(err, &usage) {
+		t.Errorf("exclusive options given together should be rejected, got %v", err)
+	}
+	if _, err := maestro.Decode(strings.NewReader(exclusiveRequired)); err == nil {
+		t.Errorf("option can not be required and exclusive")
+	}
+}
+
+func TestSuggestOption(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(exclusiveOption))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("show")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	ex, err := cmd.Prepare()
+	if err != nil {
+		t.Fatalf("fail to prepare command: %s", err)
+	}
+	var suggest maestro.SuggestionError
+	if _, err := ex.Script([]string{"--josn"}); !errors.As(err, &suggest) {
+		t.Fatalf("suggestion expected for unknown option, got %v", err)
+	}
+	if len(suggest.Others) != 1 || suggest.Others[0] != "--json" {
+		t.Errorf("suggestion mismatched! want --json, got %v", suggest.Others)
+	}
+	if _, err := ex.Script([]string{"--unknown"}); errors.As(err, &suggest) {
+		t.Errorf("no suggestion expected, got %v", suggest.Others)
+	}
+}
+
+const exclusiveOption = `
+show(
+	options = (
+		short     = j,
+		long      = json,
+		flag      = true,
+		exclusive = format,
+	), (
+		short = v,
+		flag  = true,
+	), (
+		short     = y,
+		long      = yaml,
+		flag      = true,
+		exclusive = format,
+	),
+): {
+	echo $json $yaml $v
+}
+`
+
+const exclusiveRequired = `
+show(
+	options = (
+		short     = j,
+		required  = true,
+		exclusive = format,
+	),
+): {
+	echo $j
+}
+`
+
+func TestDecodeArguments(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(argumentsFile))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("deploy")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	want := "deploy <target> [region] [files...]"
+	if got := cmd.Usage(); got != want {
+		t.Errorf("usage mismatched! want %q, got %q", want, got)
+	}
+	ex, err := cmd.Prepare()
+	if err != nil {
+		t.Fatalf("fail to prepare command: %s", err)
+	}
+	tests := []struct {
+		Args []string
+		Want string
+	}{
+		{Args: []string{"dev"}, Want: "dev 0"},
+		{Args: []string{"dev", "eu"}, Want: "dev eu 0"},
+		{Args: []string{"prod", "eu", "a", "b"}, Want: "prod eu a b 2"},
+	}
+	for _, tt := range tests {
+		got, err := ex.Script(tt.Args)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %s", tt.Args, err)
+			continue
+		}
+		if len(got) != 1 || strings.TrimSpace(got[0]) != "echo "+tt.Want {
+			t.Errorf("%v: script mismatched! want %q, got %q", tt.Args, "echo "+tt.Want, got)
+		}
+	}
+	invalid := [][]string{
+		{},
+		{"test"},
+		{"dev", "eu", "a", ""},
+	}
+	for _, args := range invalid {
+		if _, err := ex.Script(args); err == nil {
+			t.Errorf("%v: expected error", args)
+		}
+	}
+	for _, str := range []string{"bad(args = a? b,): {\n\techo\n}", "bad(args = a... b,): {\n\techo\n}"} {
+		if _, err := maestro.Decode(strings.NewReader(str)); err == nil {
+			t.Errorf("%q: expected error", str)
+		}
+	}
+}
+
+const argumentsFile = `
+deploy(
+	args = target(oneof(dev prod)) region? files...(notempty),
+): {
+	echo $target $region $files ${#files}
+}
+`
+
+func TestDecodeRuns(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(runs))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	tests := map[string][]string{
+		"nightly": {"deploy", "--env", "staging", "--no-cache"},
+		"quick":   {"deploy", "-e", "dev"},
+		"release": {"deploy", "--env", "prod"},
+	}
+	if len(mst.Runs) != len(tests) {
+		t.Fatalf("runs mismatched! want %d, got %d", len(tests), len(mst.Runs))
+	}
+	for n, want := range tests {
+		got := mst.Runs[n]
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("%s: run mismatched! want %q, got %q", n, want, got)
+		}
+	}
+}
+
+const runs = `
+runs nightly = deploy --env staging --no-cache
+runs (
+	quick   = deploy -e dev
+	release = deploy --env prod
+)
+
+deploy: {
+	echo deploy
+}
+`
+
+func TestDecodeConcurrency(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(concurrent))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("backup")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	if cmd.MaxConcurrent != 1 {
+		t.Errorf("max_concurrent mismatched! want 1, got %d", cmd.MaxConcurrent)
+	}
+	if cmd.Policy != maestro.PolicyReject {
+		t.Errorf("policy mismatched! want %s, got %s", maestro.PolicyReject, cmd.Policy)
+	}
+	str := strings.Replace(concurrent, maestro.PolicyReject, "drop", 1)
+	if _, err := maestro.Decode(strings.NewReader(str)); err == nil {
+		t.Errorf("unknown policy should be rejected")
+	}
+}
+
+const concurrent = `
+backup(
+	max_concurrent    = 1,
+	concurrent_policy = reject,
+): {
+	echo backup
+}
+`
+
+func TestCheckCycles(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(cyclic))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	err = mst.Validate()
+	var cycle maestro.CycleError
+	if !errors.As(err, &cycle) {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+	want := "a -> b -> c -> a"
+	if got := strings.Join(cycle.Path, " -> "); got != want {
+		t.Errorf("cycle mismatched! want %s, got %s", want, got)
+	}
+	mst, err = maestro.Decode(strings.NewReader(acyclic))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	if err := mst.Validate(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+const cyclic = `
+a: b {
+	echo a
+}
+
+b: c {
+	echo b
+}
+
+c: a {
+	echo c
+}
+`
+
+const acyclic = `
+a: b, c {
+	echo a
+}
+
+b: c, ?missing {
+	echo b
+}
+
+c: {
+	echo c
+}
+`
+
+func TestDecodeHttpMap(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(httpMap))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("deploy")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	want := []maestro.HttpField{
+		{Name: "branch", Path: "$.ref"},
+		{Name: "sha", Path: "$.commits[0].id"},
+		{Name: "target", Path: "$.repository.name"},
+	}
+	if len(cmd.HttpMap) != len(want) {
+		t.Fatalf("mapping mismatched! want %d, got %d", len(want), len(cmd.HttpMap))
+	}
+	for i := range want {
+		if cmd.HttpMap[i] != want[i] {
+			t.Errorf("mapping mismatched! want %+v, got %+v", want[i], cmd.HttpMap[i])
+		}
+	}
+}
+
+const httpMap = `
+deploy(
+	args     = target,
+	http_map = (
+		branch = $.ref,
+		sha    = $.commits[0].id,
+		target = $.repository.name,
+	),
+): {
+	echo $branch $sha $target
+}
+`
+
+func TestDecodeEnvFile(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		file = filepath.Join(dir, "app.env")
+	)
+	if err := os.WriteFile(file, []byte(dotenv), 0644); err != nil {
+		t.Fatalf("fail to write env file: %s", err)
+	}
+	mst, err := maestro.Decode(strings.NewReader(fmt.Sprintf(envfile, dir, file)))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("run")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	want := []maestro.EnvFile{
+		{File: filepath.Join(dir, "missing.env"), Optional: true},
+		{File: file},
+	}
+	if len(cmd.EnvFiles) != len(want) {
+		t.Fatalf("env files mismatched! want %d, got %d", len(want), len(cmd.EnvFiles))
+	}
+	for i := range want {
+		if cmd.EnvFiles[i] != want[i] {
+			t.Errorf("env file mismatched! want %+v, got %+v", want[i], cmd.EnvFiles[i])
+		}
+	}
+	if list, err := cmd.EnvFiles[0].Load(); err != nil || len(list) != 0 {
+		t.Errorf("optional missing file should be ignored: %v", err)
+	}
+	list, err := cmd.EnvFiles[1].Load()
+	if err != nil {
+		t.Fatalf("fail to load env file: %s", err)
+	}
+	values := map[string]string{
+		"DB_HOST": "localhost",
+		"DB_USER": "admin",
+		"DB_PASS": "s3cr3t #1",
+		"GREET":   "hello\nworld",
+	}
+	for k, v := range values {
+		if got := list[k]; got != v {
+			t.Errorf("%s: value mismatched! want %q, got %q", k, v, got)
+		}
+	}
+}
+
+const envfile = `
+.ENVFILE = "%s/missing.env"?
+
+run(envfile = "%s"): {
+	echo $DB_HOST
+}
+`
+
+const dotenv = `
+# database
+DB_HOST=localhost # inline comment
+export DB_USER = admin
+DB_PASS='s3cr3t #1'
+GREET="hello\nworld"
+`
+
+func TestDecodeHosts(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(hosts))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("deploy")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	want := []string{"10.0.0.1:22", "10.0.0.2:2222"}
+	if strings.Join(cmd.Hosts, " ") != strings.Join(want, " ") {
+		t.Fatalf("hosts mismatched! want %s, got %s", want, cmd.Hosts)
+	}
+	users := map[string]string{
+		"10.0.0.1:22":   "deploy",
+		"10.0.0.2:2222": "root",
+	}
+	for addr, user := range users {
+		config := cmd.HostSettings[addr].ClientConfig(mst.MetaSSH)
+		if config.User != user {
+			t.Errorf("%s: user mismatched! want %s, got %s", addr, user, config.User)
+		}
+	}
+}
+
+const hosts = `
+.SSH_USER     = root
+.SSH_PASSWORD = secret
+
+deploy(
+	hosts = (
+		addr     = 10.0.0.1,
+		user     = deploy,
+		password = deploy,
+	), (
+		addr = 10.0.0.2,
+		port = 2222,
+	),
+): {
+	echo deploy
+}
+`
+
+func TestDecodeCommandScope(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(scoped))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	first, err := mst.Commands.Lookup("first")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	if v, _ := first.Ev.Get("GOOS"); v != "windows" {
+		t.Errorf("GOOS mismatched! want windows, got %s", v)
+	}
+	if v, _ := first.Ev.Get("CGO_ENABLED"); v != "0" {
+		t.Errorf("CGO_ENABLED mismatched! want 0, got %s", v)
+	}
+	if first.Ev.Has("TRACE") {
+		t.Errorf("TRACE should have been deleted")
+	}
+	if v, _ := first.As.Get("ll"); v != "ls -l" {
+		t.Errorf("alias mismatched! want 'ls -l', got %s", v)
+	}
+	second, err := mst.Commands.Lookup("second")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	if v, _ := second.Ev.Get("GOOS"); v != "linux" {
+		t.Errorf("scoped export leaked! want linux, got %s", v)
+	}
+	if second.Ev.Has("CGO_ENABLED") || second.As.Has("ll") || !second.Ev.Has("TRACE") {
+		t.Errorf("scoped declarations leaked into other commands")
+	}
+}
+
+const scoped = `
+export GOOS = linux
+export TRACE = 1
+
+first(
+	export GOOS = windows,
+	export (
+		CGO_ENABLED = 0,
+	),
+	alias ll = "ls -l",
+	delete TRACE,
+): {
+	go build
+}
+
+second: {
+	go build
+}
+`
+
+func TestDecodeShadowing(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"shadow.mf": "VERSION = 2\n",
+		"global.mf": "global VERSION = 2\nLOCAL = 1\n",
+	}
+	for f, c := range files {
+		if err := os.WriteFile(filepath.Join(dir, f), []byte(c), 0644); err != nil {
+			t.Fatalf("fail to write include file: %s", err)
+		}
+	}
+	_, err := maestro.Decode(strings.NewReader(fmt.Sprintf(shadowing, dir, "shadow.mf")))
+	if err == nil || !strings.Contains(err.Error(), "VERSION") {
+		t.Errorf("expected shadowing error, got %v", err)
+	}
+	if _, err := maestro.Decode(strings.NewReader(fmt.Sprintf(shadowing, dir, "global.mf"))); err != nil {
+		t.Errorf("global redefinition should be allowed: %s", err)
+	}
+}
+
+const shadowing = `
+VERSION = 1
+.INCLUDE_PATH = "%s"
+
+include "%s"
+`
+
+func TestDecodeAppendAssign(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(appendAssign))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("build")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	exports := []struct {
+		Name string
+		Want string
+	}{
+		{Name: "FLAGS", Want: "-v -race"},
+		{Name: "MODE", Want: "dev"},
+		{Name: "LEVEL", Want: "debug"},
+	}
+	for _, e := range exports {
+		if got, _ := cmd.Ev.Get(e.Name); got != e.Want {
+			t.Errorf("%s mismatched! want %q, got %q", e.Name, e.Want, got)
+		}
+	}
+	if got, _ := cmd.As.Get("ll"); got != "ls -l -h" {
+		t.Errorf("alias mismatched! want %q, got %q", "ls -l -h", got)
+	}
+	if strings.Join(cmd.Categories, ",") != "build,ci" {
+		t.Errorf("tags mismatched! got %v", cmd.Categories)
+	}
+	if strings.Join(cmd.Hosts, ",") != "localhost" {
+		t.Errorf("hosts mismatched! got %v", cmd.Hosts)
+	}
+}
+
+const appendAssign = `
+export FLAGS = -v
+export FLAGS += -race
+export MODE = dev
+export MODE ?= prod
+export LEVEL ?= debug
+
+alias ll = "ls -l"
+alias ll += -h
+
+build(
+	tag   = build,
+	tag   += ci,
+	hosts ?= localhost,
+): {
+	go build
+}
+`
+
+func TestDecodeTransfers(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(transfers))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("deploy")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	if len(cmd.Uploads) != 2 || len(cmd.Downloads) != 1 {
+		t.Fatalf("transfers mismatched! got %d uploads, %d downloads", len(cmd.Uploads), len(cmd.Downloads))
+	}
+	if up := cmd.Uploads[0]; up.Local != "bin/app" || up.Remote != "/usr/local/bin/app" {
+		t.Errorf("upload mismatched! got %s -> %s", up.Local, up.Remote)
+	}
+	if down := cmd.Downloads[0]; down.Remote != "/var/log/app.log" || down.Local != "app.log" {
+		t.Errorf("download mismatched! got %s -> %s", down.Remote, down.Local)
+	}
+}
+
+const transfers = `
+deploy(
+	hosts    = "10.0.0.1:22",
+	upload   = "bin/app:/usr/local/bin/app" "app.conf:/etc/app.conf",
+	download = "/var/log/app.log:app.log",
+): {
+	systemctl restart app
+}
+`
+
+func TestDecodeEncrypted(t *testing.T) {
+	t.Setenv("MAESTRO_AGE_KEY", "AGE-SECRET-KEY-1VU6QY6MKD3C0JUT9GG6ML85U35ASP5J5TN9MVX0MSLJRK0E73Z5Q25MYAD")
+	mst, err := maestro.Decode(strings.NewReader(encrypted))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("echo")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	if cmd.Short != "s3cr3t" {
+		t.Errorf("value mismatched! want s3cr3t, got %s", cmd.Short)
+	}
+
+	t.Setenv("MAESTRO_AGE_KEY", "AGE-SECRET-KEY-1268TR88HL0E4RQZD8WK728R3THTE5FCUP60AN0V0JG95W0WQVT5QJX3ZF6")
+	if _, err := maestro.Decode(strings.NewReader(encrypted)); err == nil {
+		t.Errorf("decoding with wrong key should fail")
+	}
+
+	t.Setenv("MAESTRO_AGE_KEY", "")
+	file := filepath.Join(t.TempDir(), "keys.txt")
+	if err := os.WriteFile(file, []byte(encryptedKeys), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MAESTRO_AGE_KEY_FILE", file)
+	if _, err := maestro.Decode(strings.NewReader(encrypted)); err != nil {
+		t.Errorf("decoding with identity file should succeed: %s", err)
+	}
+
+	var buf bytes.Buffer
+	mst.Stdout = &buf
+	if err := mst.Encrypt([]string{"-r", "age1ar2ylfle9u6h7pl046v6gusnm4st9flsau7gtwatgq4r38a2x92qqcd95c", "other"}); err != nil {
+		t.Fatalf("fail to encrypt: %s", err)
+	}
+	src := fmt.Sprintf("TOKEN = %s\necho(short = $TOKEN): {\n\techo\n}\n", strings.TrimSpace(buf.String()))
+	if mst, err = maestro.Decode(strings.NewReader(src)); err != nil {
+		t.Fatalf("fail to decode encrypted value: %s", err)
+	}
+	if cmd, _ := mst.Commands.Lookup("echo"); cmd.Short != "other" {
+		t.Errorf("value mismatched! want other, got %s", cmd.Short)
+	}
+}
+
+func TestDecodeEncryptedExport(t *testing.T) {
+	t.Setenv("MAESTRO_AGE_KEY", "AGE-SECRET-KEY-1VU6QY6MKD3C0JUT9GG6ML85U35ASP5J5TN9MVX0MSLJRK0E73Z5Q25MYAD")
+	mst, err := maestro.Decode(strings.NewReader(encryptedExport))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	for _, name := range []string{"global", "scoped"} {
+		cmd, err := mst.Commands.Lookup(name)
+		if err != nil {
+			t.Fatalf("%s: command not found: %s", name, err)
+		}
+		if got := cmd.Environ()["TOKEN"]; got != "s3cr3t" {
+			t.Errorf("%s: exported value should be decrypted! got %s", name, got)
+		}
+	}
+	t.Setenv("MAESTRO_AGE_KEY", "")
+	if _, err := maestro.Decode(strings.NewReader(encryptedExport)); err == nil {
+		t.Errorf("decoding encrypted export without key should fail")
+	}
+}
+
+const encryptedExport = `
+export TOKEN = "enc[AGE-YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IFgyNTUxOSBWem5nbGxQQUFwYWROTjJjNkM1NlR5WVNzRmp1dFZ4R0tKdkErcGx1NmpBCm9RalpEUGVDK0pNZVpvcnZtOTdhT1c2a3NPWDNHSkNFUDVYY0lOVHRZYk0KLS0tIFUrektkbzRCdnNqOWJmTVROOFlRcUVSU2xNMjFhQlFKbWpuOWNRWXdTaHMKTh7Stz1B37oE6ToIY7Fnnxbpe1AAfOH7xlZqfmXtYQzmdFWTlh4=]"
+
+global(): {
+	echo $TOKEN
+}
+
+scoped(
+	export TOKEN = "enc[AGE-YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IFgyNTUxOSBWem5nbGxQQUFwYWROTjJjNkM1NlR5WVNzRmp1dFZ4R0tKdkErcGx1NmpBCm9RalpEUGVDK0pNZVpvcnZtOTdhT1c2a3NPWDNHSkNFUDVYY0lOVHRZYk0KLS0tIFUrektkbzRCdnNqOWJmTVROOFlRcUVSU2xNMjFhQlFKbWpuOWNRWXdTaHMKTh7Stz1B37oE6ToIY7Fnnxbpe1AAfOH7xlZqfmXtYQzmdFWTlh4=]",
+): {
+	echo $TOKEN
+}
+`
+
+const encryptedKeys = `
+# created: 2026-10-16T10:00:00Z
+# public key: age1ar2ylfle9u6h7pl046v6gusnm4st9flsau7gtwatgq4r38a2x92qqcd95c
+AGE-SECRET-KEY-1VU6QY6MKD3C0JUT9GG6ML85U35ASP5J5TN9MVX0MSLJRK0E73Z5Q25MYAD
+`
+
+const encrypted = `
+TOKEN = "enc[AGE-YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IFgyNTUxOSBWem5nbGxQQUFwYWROTjJjNkM1NlR5WVNzRmp1dFZ4R0tKdkErcGx1NmpBCm9RalpEUGVDK0pNZVpvcnZtOTdhT1c2a3NPWDNHSkNFUDVYY0lOVHRZYk0KLS0tIFUrektkbzRCdnNqOWJmTVROOFlRcUVSU2xNMjFhQlFKbWpuOWNRWXdTaHMKTh7Stz1B37oE6ToIY7Fnnxbpe1AAfOH7xlZqfmXtYQzmdFWTlh4=]"
+
+echo(short = $TOKEN): {
+	echo $TOKEN
+}
+`
+
+func TestDecodeNotify(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(notified))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	if mst.MetaSMTP.Host != "smtp.example.org:587" || mst.MetaSMTP.From != "cron@example.org" {
+		t.Errorf("smtp settings mismatched! got %+v", mst.MetaSMTP)
+	}
+	cmd, err := mst.Commands.Lookup("backup")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	if len(cmd.Schedules) != 1 {
+		t.Fatalf("expected 1 schedule, got %d", len(cmd.Schedules))
+	}
+	s := cmd.Schedules[0]
+	if len(s.Notify) != 3 || s.NotifyOn != maestro.NotifyAlways || s.NotifyTemplate == "" {
+		t.Errorf("notify settings mismatched! got %s (%s)", s.Notify, s.NotifyOn)
+	}
+
+	for _, str := range []string{"notify = ftp://example.org", "notify_on = never"} {
+		src := fmt.Sprintf("backup(schedule = (time = @daily, %s)): {\n\techo\n}\n", str)
+		if _, err := maestro.Decode(strings.NewReader(src)); err == nil {
+			t.Errorf("%s: expected error", str)
+		}
+	}
+}
+
+const notified = `
+.SMTP_HOST = "smtp.example.org:587"
+.SMTP_FROM = cron@example.org
+
+backup(
+	schedule = (
+		time            = @daily,
+		notify          = ops@example.org "https://hooks.example.org/backup" "exec:logger -t maestro",
+		notify_on       = always,
+		notify_template = "{{.Command}}: {{.Status}}",
+	),
+): {
+	echo backup
+}
+`
+
+func TestDecodeExpressions(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(expressions))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("build")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	exports := []struct {
+		Name string
+		Want string
+	}{
+		{Name: "COUNT", Want: "6"},
+		{Name: "TOTAL", Want: "-4"},
+		{Name: "QUOTED", Want: "count=6"},
+		{Name: "UPPER", Want: "MAESTRO"},
+		{Name: "LOWER", Want: "maestro"},
+		{Name: "TRIMMED", Want: "maestro"},
+		{Name: "JOINED", Want: "a,b,c"},
+		{Name: "NESTED", Want: "A-B-C"},
+	}
+	for _, e := range exports {
+		if got, _ := cmd.Ev.Get(e.Name); got != e.Want {
+			t.Errorf("%s mismatched! want %q, got %q", e.Name, e.Want, got)
+		}
+	}
+	invalid := []struct {
+		Input string
+		Err   string
+	}{
+		{Input: "n = $(( 1 / 0 ))\n", Err: "division by zero"},
+		{Input: "n = $(( $unknown + 1 ))\n", Err: "unknown: variable not defined"},
+		{Input: "n = $(( 1 + ))\n", Err: "unexpected end of expression"},
+		{Input: "n = unknown(foo)\n", Err: "unknown: unknown function"},
+	}
+	for _, i := range invalid {
+		_, err := maestro.Decode(strings.NewReader(i.Input))
+		if err == nil {
+			t.Errorf("%q: decoding should fail", i.Input)
+			continue
+		}
+		if !strings.Contains(err.Error(), i.Err) {
+			t.Errorf("%q: error mismatched! want %q, got %q", i.Input, i.Err, err)
+		}
+	}
+	if _, err := maestro.Decode(strings.NewReader("n = $(( 1 + 1 ))\n")); err != nil {
+		t.Errorf("valid expression should be decoded: %s", err)
+	}
+}
+
+const expressions = `
+name    = Maestro
+n       = 5
+list    = a b c
+count   = $(( $n + 1 ))
+total   = $(( -(n - 1) * (4 % 3) ))
+quoted  = "count=$(( n + 1 ))"
+upper   = upper($name)
+lower   = lower($name)
+trimmed = trim("  maestro ")
+joined  = join(",", $list)
+nested  = join("-", upper($list))
+
+export COUNT   = $count
+export TOTAL   = $total
+export QUOTED  = $quoted
+export UPPER   = $upper
+export LOWER   = $lower
+export TRIMMED = $trimmed
+export JOINED  = $joined
+export NESTED  = $nested
+
+build: {
+	go build
+}
+`
+
+func TestLintScripts(t *testing.T) {
+	t.Setenv("MAESTRO_LINT_HOST", "set")
+	tests := []struct {
+		Input string
+		Valid bool
+	}{
+		{Input: lintValid, Valid: true},
+		{Input: lintUndefined},
+		{Input: lintUnused},
+		{Input: lintHost},
+	}
+	for _, tt := range tests {
+		mst, err := maestro.Decode(strings.NewReader(tt.Input))
+		if err != nil {
+			t.Fatalf("fail to decode: %s", err)
+		}
+		err = mst.Lint(nil)
+		if tt.Valid && err != nil {
+			t.Errorf("lint should succeed: %s", err)
+		} else if !tt.Valid && err == nil {
+			t.Errorf("lint should fail")
+		}
+	}
+}
+
+const lintValid = `
+target = linux
+
+build(
+	options = (
+		short = v,
+		long  = verbose,
+		flag  = true,
+	),
+	args = pkg,
+): {
+	echo $target ${verbose} $HOME $1 '$quoted'
+	for f in $(ls); do echo $f; done
+	out=bin/app; echo $out ${unset:-default}
+	total = $(ls | wc -l)
+	echo ${total}
+}
+`
+
+const lintUndefined = `
+build: {
+	echo $taget
+}
+`
+
+const lintHost = `
+build: {
+	echo $MAESTRO_LINT_HOST
+}
+`
+
+const lintUnused = `
+build(
+	options = (
+		short = v,
+		long  = verbose,
+		flag  = true,
+	),
+): {
+	echo build
+}
+`
+
+func TestDecodeComputed(t *testing.T) {
+	var (
+		dir   = t.TempDir()
+		where = filepath.Join(dir, "where")
+		first = filepath.Join(dir, "first")
+		other = filepath.Join(dir, "other")
+	)
+	for _, d := range []string{first, other} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(where, []byte(first), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mst, err := maestro.Decode(strings.NewReader(fmt.Sprintf(computed, dir)))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stamp")); err == nil {
+		t.Errorf("command substitutions should not be evaluated when the file is decoded")
+	}
+	cmd, err := mst.Commands.Lookup("eager")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	if want := "$(cat " + where + ")"; cmd.WorkDir != want {
+		t.Errorf("workdir should not be evaluated! want %s, got %s", want, cmd.WorkDir)
+	}
+	if cmd.IsTest() {
+		t.Errorf("tags should not be evaluated! got %v", cmd.Categories)
+	}
+	for _, name := range []string{"eager", "lazy"} {
+		if err := mst.Execute(name, nil); err != nil {
+			t.Fatalf("%s: fail to execute: %s", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(first, name)); err != nil {
+			t.Errorf("%s: workdir should be evaluated when the command is executed: %s", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stamp")); err != nil {
+		t.Errorf("tags should be evaluated once the commands are needed")
+	}
+	for _, name := range []string{"eager", "lazy"} {
+		cmd, _ := mst.Commands.Lookup(name)
+		if !cmd.IsTest() {
+			t.Errorf("%s: tags should be evaluated! got %v", name, cmd.Categories)
+		}
+	}
+	if err := os.WriteFile(where, []byte(other), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"eager", "lazy"} {
+		if err := mst.Execute(name, nil); err != nil {
+			t.Fatalf("%s: fail to execute: %s", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(other, "eager")); err == nil {
+		t.Errorf("eager: workdir should only be evaluated once")
+	}
+	if _, err := os.Stat(filepath.Join(other, "lazy")); err != nil {
+		t.Errorf("lazy: workdir should be evaluated each time the command is executed: %s", err)
+	}
+
+	stamp := filepath.Join(t.TempDir(), "stamp")
+	src := fmt.Sprintf("stamp = $(touch %[1]s)\nexport STAMP = $(touch %[1]s)\ncmd(short = $(touch %[1]s)): {\n\techo\n}\n", stamp)
+	if _, err := maestro.Decode(strings.NewReader(src)); err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	if _, err := os.Stat(stamp); err == nil {
+		t.Errorf("command substitutions should only be evaluated in tag, workdir and hosts")
+	}
+	if _, err := maestro.Decode(strings.NewReader("cmd(eval = later): {\n\techo\n}\n")); err == nil {
+		t.Errorf("unknown evaluation mode should be rejected")
+	}
+}
+
+const computed = `
+stamped(tag = $(touch %[1]s/stamp)): {
+	echo
+}
+
+eager(
+	tag     = $(echo test),
+	workdir = $(cat %[1]s/where),
+): {
+	touch eager
+}
+
+lazy(
+	eval    = lazy,
+	tag     = $(echo test),
+	workdir = $(cat %[1]s/where),
+): {
+	touch lazy
+}
+`
+
+func TestDecodeConditional(t *testing.T) {
+	t.Setenv("MAESTRO_CI", "true")
+	mst, err := maestro.Decode(strings.NewReader(conditional))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("build")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	exports := []struct {
+		Name string
+		Want string
+	}{
+		{Name: "MODE", Want: "ci"},
+		{Name: "LEVEL", Want: "info"},
+		{Name: "FLAGS", Want: "-v -race"},
+		{Name: "EMPTY", Want: ""},
+	}
+	for _, e := range exports {
+		if got, _ := cmd.Ev.Get(e.Name); got != e.Want {
+			t.Errorf("%s mismatched! want %q, got %q", e.Name, e.Want, got)
+		}
+	}
+	if _, err := mst.Commands.Lookup("local"); err == nil {
+		t.Errorf("command local should not be defined")
+	}
+	invalid := []string{
+		"ifdef CI\nmode = ci\n",
+		"ifdef\nendif\n",
+		"else\n",
+	}
+	for _, str := range invalid {
+		if _, err := maestro.Decode(strings.NewReader(str)); err == nil {
+			t.Errorf("%q: decoding should fail", str)
+		}
+	}
+}
+
+const conditional = `
+level := info
+level ?= debug
+flags := -v
+flags += -race
+flags = join(" ", $flags)
+empty =
+empty ?= value
+
+ifdef MAESTRO_CI
+mode = ci
+ifndef level
+level = trace
+endif
+else
+mode = dev
+
+local: {
+	echo local
+}
+endif
+
+ifndef MAESTRO_CI
+mode = local
+endif
+
+export MODE  = $mode
+export LEVEL = $level
+export FLAGS = $flags
+export EMPTY = $empty
+
+build: {
+	go build
+}
+`
+
+func TestDecodeHardening(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader("cmd(no_new_privs = true, seccomp = default ptrace): {\n\techo\n}\n"))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("cmd")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	if !cmd.NoNewPrivs || strings.Join(cmd.Seccomp, ",") != "default,ptrace" {
+		t.Errorf("hardening properties mismatched! got %t, %v", cmd.NoNewPrivs, cmd.Seccomp)
+	}
+	if runtime.GOOS != "linux" {
+		return
+	}
+	if _, err := maestro.Decode(strings.NewReader("cmd(seccomp = foobar): {\n\techo\n}\n")); err == nil {
+		t.Errorf("unknown syscall should be rejected")
+	}
+}
+
+func TestDecodeBlackout(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(blackout))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("deploy")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	if len(cmd.Schedules) != 1 {
+		t.Fatalf("expected 1 schedule, got %d", len(cmd.Schedules))
+	}
+	tests := []struct {
+		Blackout maestro.Blackout
+		When     string
+		Want     string
+	}{
+		{Blackout: cmd.Blackout, When: "2024-12-21 10:00", Want: "sat,sun"},
+		{Blackout: cmd.Blackout, When: "2024-12-23 10:00"},
+		{Blackout: cmd.Blackout, When: "2024-12-24 00:00", Want: "2024-12-24..2024-12-26"},
+		{Blackout: cmd.Blackout, When: "2024-12-26 23:59", Want: "2024-12-24..2024-12-26"},
+		{Blackout: cmd.Blackout, When: "2024-12-27 10:00"},
+		{Blackout: cmd.Schedules[0].Blackout, When: "2024-12-27 10:00", Want: "fri-mon"},
+		{Blackout: cmd.Schedules[0].Blackout, When: "2024-12-30 10:00", Want: "fri-mon"},
+		{Blackout: cmd.Schedules[0].Blackout, When: "2024-12-31 10:00", Want: "2024-12-31"},
+		{Blackout: cmd.Schedules[0].Blackout, When: "2025-01-01 10:00"},
+	}
+	for _, tt := range tests {
+		when, _ := time.ParseInLocation("2006-01-02 15:04", tt.When, time.Local)
+		got, ok := tt.Blackout.Active(when)
+		if ok != (tt.Want != "") || got != tt.Want {
+			t.Errorf("%s: blackout window mismatched! want %q, got %q", tt.When, tt.Want, got)
+		}
+	}
+	for _, str := range []string{"someday", "2024-12-26..2024-12-24", "2024-13-01"} {
+		in := fmt.Sprintf("cmd(blackout = %q): {\n\techo\n}\n", str)
+		if _, err := maestro.Decode(strings.NewReader(in)); err == nil {
+			t.Errorf("%s: invalid blackout window should be rejected", str)
+		}
+	}
+
+	if mst, err = maestro.Decode(strings.NewReader(blackoutRemote)); err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	mst.Remote = maestro.RemoteOn
+	err = mst.Execute("deploy", nil)
+	if err == nil || !strings.Contains(err.Error(), "blackout window mon-sun is active") {
+		t.Errorf("remote execution should be refused during a blackout, got %v", err)
+	}
+}
+
+const blackoutRemote = `
+deploy(
+	blackout = "mon-sun",
+	hosts    = "10.0.0.1:22",
+): {
+	echo deploy
+}
+`
+
+const blackout = `
+deploy(
+	blackout = ( "sat,sun", "2024-12-24..2024-12-26" ),
+	schedule = (
+		time = 0 8 "*" "*" "*",
+		blackout = "fri-mon" 2024-12-31,
+	),
+): {
+	echo deploy
+}
+`
+
+func TestNeedsEnv(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(needsEnv))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	var buf bytes.Buffer
+	mst.Stdout = &buf
+	explain := func() string {
+		buf.Reset()
+		if err := mst.Explain([]string{"deploy"}); err != nil {
+			t.Fatalf("fail to explain: %s", err)
+		}
+		return buf.String()
+	}
+	t.Setenv("MAESTRO_NEED_INHERITED", "")
+	got := explain()
+	if !strings.Contains(got, "missing environment variable(s): MAESTRO_NEED_INHERITED (") {
+		t.Errorf("only unset variable should be reported, got:\n%s", got)
+	}
+	t.Setenv("MAESTRO_NEED_INHERITED", "set")
+	if got := explain(); strings.Contains(got, "missing environment") {
+		t.Errorf("no missing variable should be reported, got:\n%s", got)
+	}
+
+	buf.Reset()
+	err = mst.Execute("release", nil)
+	if err == nil || !strings.Contains(err.Error(), "missing environment variable(s): MAESTRO_NEED_DEP") {
+		t.Errorf("missing variable of dependency should be reported, got %v", err)
+	}
+	if strings.Contains(buf.String(), "deploy") || strings.Contains(buf.String(), "release") {
+		t.Errorf("no command should be executed, got:\n%s", buf.String())
+	}
+
+	cmd, err := mst.Commands.Lookup("check")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	var (
+		stdout      bytes.Buffer
+		stderr      bytes.Buffer
+		ctx, cancel = context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	)
+	defer cancel()
+	cmd.Schedules[0].Run(ctx, mst.Commands, maestro.ScheduleContext{CommandSettings: cmd}, &stdout, &stderr)
+	if !strings.Contains(stderr.String(), "missing environment variable(s): MAESTRO_NEED_DEP") {
+		t.Errorf("missing variable should be reported by scheduled runs, got %q", stderr.String())
+	}
+	if stdout.Len() > 0 {
+		t.Errorf("scheduled command should not be executed, got %q", stdout.String())
+	}
+}
+
+const needsEnv = `
+deploy(
+	needs_env = MAESTRO_NEED_EXPORTED MAESTRO_NEED_INHERITED,
+	export MAESTRO_NEED_EXPORTED = exported,
+): {
+	echo deploy
+}
+
+build(needs_env = MAESTRO_NEED_DEP): {
+	echo build
+}
+
+release: deploy, build {
+	echo release
+}
+
+check(
+	needs_env = MAESTRO_NEED_DEP,
+	schedule  = (time = "@every 10ms"),
+): {
+	echo check
+}
+`
+
+func TestExportShell(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(standaloneFile))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	dir := t.TempDir()
+	if err := mst.Export([]string{"--format", "shell", "-o", dir, "build"}); err != nil {
+		t.Fatalf("fail to export: %s", err)
+	}
+	file := filepath.Join(dir, "build")
+	i, err := os.Stat(file)
+	if err != nil {
+		t.Fatalf("script not written: %s", err)
+	}
+	if i.Mode().Perm()&0111 == 0 {
+		t.Errorf("script should be executable")
+	}
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := []string{
+		"#!/bin/sh",
+		"export GOOS='linux'",
+		"VERSION='1.0'",
+		"maestro_prepare() (",
+		"while getopts ':e:v-:' opt; do",
+		"e) e=\"$OPTARG\"; env=\"$OPTARG\" ;;",
+		"''|'prod'|'dev') ;;",
+		"[ $# -ge 1 ] || {",
+		"maestro_prepare 'x'",
+		"echo $VERSION $env $target",
+	}
+	for _, p := range parts {
+		if !strings.Contains(string(buf), p) {
+			t.Errorf("%q not found in script", p)
+		}
+	}
+}
+
+const standaloneFile = `
+VERSION = 1.0
+export GOOS = linux
+
+prepare: {
+	echo prepare $1
+}
+
+build(
+	short   = "build the project",
+	options = (
+		short   = e,
+		long    = env,
+		default = dev,
+		check   = oneof("prod" "dev"),
+	), (
+		short = v,
+		flag  = true,
+	),
+	args = target,
+): prepare(x) {
+	echo $VERSION $env $target
+}
+`
+
+func TestLoadConfig(t *testing.T) {
+	var (
+		dir    = t.TempDir()
+		file   = filepath.Join(dir, "maestro.mf")
+		config = filepath.Join(dir, "maestro.toml")
+	)
+	if err := os.WriteFile(file, []byte(configFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config, []byte(configToml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mst := maestro.New()
+	if err := mst.Load(file); err != nil {
+		t.Fatalf("fail to load file: %s", err)
+	}
+	if mst.MetaExec.Default != "deploy" {
+		t.Errorf("default mismatched! want deploy, got %s", mst.MetaExec.Default)
+	}
+	if mst.MetaAbout.Author != "midbel" {
+		t.Errorf("author mismatched! want midbel, got %s", mst.MetaAbout.Author)
+	}
+	if mst.MetaSSH.User != "deploy" || mst.MetaSSH.Parallel != 4 {
+		t.Errorf("ssh settings mismatched! got %s/%d", mst.MetaSSH.User, mst.MetaSSH.Parallel)
+	}
+	if mst.MetaHttp.Secret != "s3cr$t" {
+		t.Errorf("secret mismatched! got %s", mst.MetaHttp.Secret)
+	}
+
+	if len(mst.MetaExec.Before) != 2 || mst.MetaExec.Before[1] != "deploy" {
+		t.Errorf("before mismatched! got %q", mst.MetaExec.Before)
+	}
+
+	for _, str := range []string{"envfile = \".env\"", "[[servers]]\nname = \"a\"", "default = "} {
+		if err := os.WriteFile(config, []byte(str), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := maestro.New().Load(file); err == nil {
+			t.Errorf("%q should not be accepted in config file", str)
+		}
+	}
+}
+
+const configFile = `
+.DEFAULT = build
+.AUTHOR  = midbel
+
+build: {
+	echo build
+}
+
+deploy: {
+	echo deploy
+}
+`
+
+const configToml = `
+default = "deploy"
+before  = ["build", 'deploy']
+
+[ssh]
+user     = "deploy"
+parallel = 4
+
+[http]
+webhook_secret = "s3cr$t"
+`
+
+func TestStdin(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "dump.sql"), []byte("from file"), 0644); err != nil {
+		t.Fatalf("fail to write dump: %s", err)
+	}
+	mst, err := maestro.Decode(strings.NewReader(fmt.Sprintf(stdin, filepath.Join(dir, "dump.sql"))))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	tests := []struct {
+		Name string
+		Want string
+	}{
+		{Name: "restore", Want: "from file"},
+		{Name: "greet", Want: "hello\nworld"},
+	}
+	for _, tt := range tests {
+		cmd, err := mst.Commands.Lookup(tt.Name)
+		if err != nil {
+			t.Fatalf("%s: command not found: %s", tt.Name, err)
+		}
+		ex, err := cmd.Prepare()
+		if err != nil {
+			t.Fatalf("%s: fail to prepare command: %s", tt.Name, err)
+		}
+		var buf bytes.Buffer
+		ex.SetOut(&buf)
+		if err := ex.Execute(context.TODO(), nil); err != nil {
+			t.Fatalf("%s: fail to execute: %s", tt.Name, err)
+		}
+		if got := strings.TrimSpace(buf.String()); got != tt.Want {
+			t.Errorf("%s: output mismatched! want %q, got %q", tt.Name, tt.Want, got)
+		}
+	}
+}
+
+const stdin = `
+restore(stdin = "%s"): {
+	cat
+}
+
+greet(
+	stdin = <<EOF
+hello
+world
+EOF
+): {
+	cat
+}
+`
+
+func TestTerminator(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(terminated))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("echo")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	tests := []struct {
+		Args []string
+		Want string
+	}{
+		{Args: []string{"--", "-x", "--y"}, Want: "false -x --y"},
+		{Args: []string{"-v", "--", "-v", "--"}, Want: "true -v --"},
+		{Args: []string{"file", "--", "-v"}, Want: "false file -v"},
+	}
+	for _, tt := range tests {
+		ex, err := cmd.Prepare()
+		if err != nil {
+			t.Fatalf("fail to prepare command: %s", err)
+		}
+		var buf bytes.Buffer
+		ex.SetOut(&buf)
+		if err := ex.Execute(context.TODO(), tt.Args); err != nil {
+			t.Fatalf("%q: fail to execute: %s", tt.Args, err)
+		}
+		if got := strings.TrimSpace(buf.String()); got != tt.Want {
+			t.Errorf("%q: output mismatched! want %q, got %q", tt.Args, tt.Want, got)
+		}
+	}
+}
+
+const terminated = `
+echo(
+	options = (
+		short = v,
+		flag  = true,
+	),
+): {
+	echo $v $@
+}
+`
@@ -0,0 +1,251 @@
+package maestro
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	diffAdded   = "+"
+	diffRemoved = "-"
+	diffChanged = "~"
+)
+
+type commandDiff struct {
+	Name    string
+	Status  string
+	Changes []string
+}
+
+func (m *Maestro) Diff(args []string) error {
+	set := flag.NewFlagSet(CmdDiff, flag.ContinueOnError)
+	if err := parseFlags(set, args); err != nil {
+		return err
+	}
+	var (
+		prev *Maestro
+		next = m
+		err  error
+	)
+	switch set.NArg() {
+	case 1:
+		prev, err = m.loadFile(set.Arg(0))
+	case 2:
+		if prev, err = m.loadFile(set.Arg(0)); err == nil {
+			next, err = m.loadFile(set.Arg(1))
+		}
+	default:
+		return fmt.Errorf("%s: one or two maestro files expected", CmdDiff)
+	}
+	if err != nil {
+		return err
+	}
+	for _, d := range diffCommands(prev.Commands, next.Commands) {
+		fmt.Fprintf(m.Stdout, "%s %s", d.Status, d.Name)
+		fmt.Fprintln(m.Stdout)
+		for _, c := range d.Changes {
+			fmt.Fprintf(m.Stdout, "    %s", c)
+			fmt.Fprintln(m.Stdout)
+		}
+	}
+	return nil
+}
+
+func (m *Maestro) loadFile(file string) (*Maestro, error) {
+	other := New()
+	other.Includes = m.Includes
+	other.Locals = m.Locals.Copy()
+	return other, other.Load(file)
+}
+
+func diffCommands(prev, next Registry) []commandDiff {
+	var (
+		list  []commandDiff
+		names = make(map[string]struct{})
+	)
+	for _, c := range prev.Values() {
+		names[c.Command()] = struct{}{}
+	}
+	for _, c := range next.Values() {
+		names[c.Command()] = struct{}{}
+	}
+	for n := range names {
+		var (
+			old, ok1 = prev.Get(n)
+			cmd, ok2 = next.Get(n)
+		)
+		switch {
+		case !ok1:
+			list = append(list, commandDiff{Name: n, Status: diffAdded})
+		case !ok2:
+			list = append(list, commandDiff{Name: n, Status: diffRemoved})
+		default:
+			changes := diffCommand(old, cmd)
+			if len(changes) == 0 {
+				break
+			}
+			list = append(list, commandDiff{Name: n, Status: diffChanged, Changes: changes})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+func diffCommand(old, cmd CommandSettings) []string {
+	var changes []string
+	changes = append(changes, diffSets("dep", depSignatures(old.Deps), depSignatures(cmd.Deps))...)
+	changes = append(changes, diffOptions(old.Options, cmd.Options)...)
+	if a, b := argSignature(old.Args), argSignature(cmd.Args); a != b {
+		changes = append(changes, fmt.Sprintf("%s args: %s -> %s", diffChanged, a, b))
+	}
+	changes = append(changes, diffSets("schedule", scheduleSignatures(old.Schedules), scheduleSignatures(cmd.Schedules))...)
+	if strings.Join(old.Lines, "\n") != strings.Join(cmd.Lines, "\n") {
+		changes = append(changes, fmt.Sprintf("%s script", diffChanged))
+	}
+	return changes
+}
+
+func diffOptions(old, opts []CommandOption) []string {
+	var (
+		prev    = make(map[string]string)
+		next    = make(map[string]string)
+		changes []string
+	)
+	for _, o := range old {
+		prev[diffOptionName(o)] = optionSignature(o)
+	}
+	for _, o := range opts {
+		next[diffOptionName(o)] = optionSignature(o)
+	}
+	for _, o := range old {
+		name := diffOptionName(o)
+		sig, ok := next[name]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("%s option %s", diffRemoved, prev[name]))
+		case sig != prev[name]:
+			changes = append(changes, fmt.Sprintf("%s option %s -> %s", diffChanged, prev[name], sig))
+		}
+	}
+	for _, o := range opts {
+		if _, ok := prev[diffOptionName(o)]; !ok {
+			changes = append(changes, fmt.Sprintf("%s option %s", diffAdded, optionSignature(o)))
+		}
+	}
+	return changes
+}
+
+func diffSets(kind string, old, list []string) []string {
+	var (
+		count   = make(map[string]int)
+		changes []string
+	)
+	for _, s := range list {
+		count[s]++
+	}
+	for _, s := range old {
+		if count[s] > 0 {
+			count[s]--
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("%s %s %s", diffRemoved, kind, s))
+	}
+	for _, s := range list {
+		if count[s] == 0 {
+			continue
+		}
+		count[s]--
+		changes = append(changes, fmt.Sprintf("%s %s %s", diffAdded, kind, s))
+	}
+	return changes
+}
+
+func depSignatures(deps []CommandDep) []string {
+	var list []string
+	for _, d := range deps {
+		str := d.Key()
+		switch {
+		case d.Mandatory:
+			str += "!"
+		case d.Optional:
+			str += "?"
+		}
+		if d.Bg {
+			str += "&"
+		}
+		if len(d.Args) > 0 {
+			str = fmt.Sprintf("%s(%s)", str, strings.Join(d.Args, " "))
+		}
+		list = append(list, str)
+	}
+	return list
+}
+
+func scheduleSignatures(list []Schedule) []string {
+	var sigs []string
+	for _, s := range list {
+		str := s.Time
+		if len(s.Args) > 0 {
+			str = fmt.Sprintf("%s (args: %s)", str, strings.Join(s.Args, " "))
+		}
+		sigs = append(sigs, str)
+	}
+	return sigs
+}
+
+func argSignature(args []CommandArg) string {
+	if len(args) == 0 {
+		return "<none>"
+	}
+	var list []string
+	for _, a := range args {
+		list = append(list, a.spec())
+	}
+	return strings.Join(list, " ")
+}
+
+func diffOptionName(o CommandOption) string {
+	if o.Long != "" {
+		return o.Long
+	}
+	return o.Short
+}
+
+func optionSignature(o CommandOption) string {
+	var names []string
+	if o.Short != "" {
+		names = append(names, "-"+o.Short)
+	}
+	if o.Long != "" {
+		names = append(names, "--"+o.Long)
+	}
+	str := strings.Join(names, ", ")
+
+	var attrs []string
+	if o.Flag {
+		attrs = append(attrs, "flag")
+	}
+	if o.List {
+		attrs = append(attrs, "list")
+	}
+	if o.Required {
+		attrs = append(attrs, "required")
+	}
+	if o.Exclusive != "" {
+		attrs = append(attrs, fmt.Sprintf("exclusive=%s", o.Exclusive))
+	}
+	if o.Default != "" {
+		attrs = append(attrs, fmt.Sprintf("default=%s", o.Default))
+	}
+	if o.DefaultFlag {
+		attrs = append(attrs, "default=true")
+	}
+	if len(attrs) > 0 {
+		str = fmt.Sprintf("%s [%s]", str, strings.Join(attrs, ", "))
+	}
+	return str
+}
@@ -0,0 +1,82 @@
+package maestro_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/midbel/maestro"
+)
+
+func TestDiff(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		prev = filepath.Join(dir, "old.mf")
+		next = filepath.Join(dir, "new.mf")
+	)
+	if err := os.WriteFile(prev, []byte(diffOld), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(next, []byte(diffNew), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		buf bytes.Buffer
+		mst = maestro.New()
+	)
+	mst.Stdout = &buf
+	if err := mst.Diff([]string{prev, next}); err != nil {
+		t.Fatalf("fail to diff: %s", err)
+	}
+	want := []string{
+		"~ build",
+		"    - dep gen",
+		"    + dep lint",
+		"    ~ option -v, --verbose [flag] -> -v, --verbose [flag, default=true]",
+		"    + option --race [flag]",
+		"~ deploy",
+		"    - schedule @daily",
+		"    + schedule 0 2 * * * (args: prod)",
+		"    ~ script",
+		"- gen",
+		"+ lint",
+	}
+	if got := strings.TrimSpace(buf.String()); got != strings.Join(want, "\n") {
+		t.Errorf("diff mismatched!\nwant:\n%s\ngot:\n%s", strings.Join(want, "\n"), got)
+	}
+}
+
+const diffOld = `
+gen: {
+	echo gen
+}
+
+build(
+	options = (short = v, long = verbose, flag = true),
+): gen {
+	echo build
+}
+
+deploy(schedule = (time = @daily)): build {
+	echo deploy
+}
+`
+
+const diffNew = `
+lint: {
+	echo lint
+}
+
+build(
+	options = (short = v, long = verbose, flag = true, default = true),
+	options = (long = race, flag = true),
+): lint {
+	echo build
+}
+
+deploy(schedule = (time = 0 2 "*" "*" "*", args = prod)): build {
+	echo deploy to $1
+}
+`
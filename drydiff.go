@@ -0,0 +1,231 @@
+package maestro
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+const (
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorReset  = "\033[0m"
+)
+
+const diffSame = " "
+
+type lineDiff struct {
+	Status string
+	Line   string
+}
+
+func (m *Maestro) snapshot(name string, args []string) ([]string, map[string]string, error) {
+	cmd, err := m.Commands.Lookup(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	environ, err := cmd.environ()
+	if err != nil {
+		return nil, nil, err
+	}
+	ex, err := cmd.Prepare()
+	if err != nil {
+		return nil, nil, err
+	}
+	c, ok := ex.(*command)
+	if !ok {
+		return cmd.Lines, nil, nil
+	}
+	vars, err := c.variables(args, environ)
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, v := range vars {
+		vars[k] = hashVariable(k, v)
+	}
+	return cmd.Lines, vars, nil
+}
+
+func hashVariable(name, value string) string {
+	sum := sha256.New()
+	io.WriteString(sum, name)
+	sum.Write([]byte{0})
+	io.WriteString(sum, value)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+func (m *Maestro) dryDiff(name string, args []string) error {
+	if m.MetaExec.History == "" {
+		return nil
+	}
+	cmd, err := m.Commands.Lookup(name)
+	if err != nil {
+		return err
+	}
+	list, err := readHistory(m.MetaExec.History)
+	if err != nil {
+		return err
+	}
+	last, ok := lastRun(list, commandNames(cmd))
+	if !ok {
+		fmt.Fprintf(m.Stdout, "no previous run of %s recorded", cmd.Command())
+		fmt.Fprintln(m.Stdout)
+		fmt.Fprintln(m.Stdout)
+		return nil
+	}
+	script, vars, err := m.snapshot(name, args)
+	if err != nil {
+		return err
+	}
+	writeDryDiff(m.Stdout, cmd.Command(), last, script, vars, useColor())
+	return nil
+}
+
+func writeDryDiff(w io.Writer, name string, last HistoryEntry, script []string, vars map[string]string, color bool) {
+	status := "ok"
+	if last.Failed() {
+		status = fmt.Sprintf("failed (%d)", last.Code)
+	}
+	var (
+		when    = last.Start.Format("2006-01-02 15:04:05")
+		changes = diffVars(last.Vars, vars)
+		lines   = diffLines(last.Script, script)
+	)
+	if len(changes) == 0 && !hasChanges(lines) {
+		fmt.Fprintf(w, "no change since the last run of %s (%s, %s)", name, when, status)
+		fmt.Fprintln(w)
+		fmt.Fprintln(w)
+		return
+	}
+	fmt.Fprintf(w, "changes since the last run of %s (%s, %s):", name, when, status)
+	fmt.Fprintln(w)
+	if len(changes) > 0 {
+		fmt.Fprintln(w, "variables:")
+		for _, c := range changes {
+			writeDiffLine(w, c.Status, c.Line, color)
+		}
+	}
+	if hasChanges(lines) {
+		fmt.Fprintln(w, "script:")
+		for _, c := range lines {
+			writeDiffLine(w, c.Status, c.Line, color)
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+func writeDiffLine(w io.Writer, status, line string, color bool) {
+	str := fmt.Sprintf("  %s %s", status, line)
+	if color {
+		switch status {
+		case diffAdded:
+			str = colorGreen + str + colorReset
+		case diffRemoved:
+			str = colorRed + str + colorReset
+		case diffChanged:
+			str = colorYellow + str + colorReset
+		}
+	}
+	fmt.Fprintln(w, str)
+}
+
+func lastRun(list []HistoryEntry, names []string) (HistoryEntry, bool) {
+	for i := len(list) - 1; i >= 0; i-- {
+		e := list[i]
+		if e.Script == nil || (e.Host != "" && e.Host != HostLocal) {
+			continue
+		}
+		for _, n := range names {
+			if e.Command == n {
+				return e, true
+			}
+		}
+	}
+	return HistoryEntry{}, false
+}
+
+func diffVars(prev, next map[string]string) []lineDiff {
+	var list []lineDiff
+	for k, v := range next {
+		old, ok := prev[k]
+		switch {
+		case !ok:
+			list = append(list, lineDiff{Status: diffAdded, Line: k})
+		case old != v:
+			list = append(list, lineDiff{Status: diffChanged, Line: k})
+		}
+	}
+	for k := range prev {
+		if _, ok := next[k]; !ok {
+			list = append(list, lineDiff{Status: diffRemoved, Line: k})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Line < list[j].Line
+	})
+	return list
+}
+
+func diffLines(prev, next []string) []lineDiff {
+	lcs := make([][]int, len(prev)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(next)+1)
+	}
+	for i := len(prev) - 1; i >= 0; i-- {
+		for j := len(next) - 1; j >= 0; j-- {
+			if prev[i] == next[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var (
+		list []lineDiff
+		i, j int
+	)
+	for i < len(prev) && j < len(next) {
+		switch {
+		case prev[i] == next[j]:
+			list = append(list, lineDiff{Status: diffSame, Line: prev[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			list = append(list, lineDiff{Status: diffRemoved, Line: prev[i]})
+			i++
+		default:
+			list = append(list, lineDiff{Status: diffAdded, Line: next[j]})
+			j++
+		}
+	}
+	for ; i < len(prev); i++ {
+		list = append(list, lineDiff{Status: diffRemoved, Line: prev[i]})
+	}
+	for ; j < len(next); j++ {
+		list = append(list, lineDiff{Status: diffAdded, Line: next[j]})
+	}
+	return list
+}
+
+func hasChanges(list []lineDiff) bool {
+	for _, d := range list {
+		if d.Status != diffSame {
+			return true
+		}
+	}
+	return false
+}
+
+func useColor() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	i, err := os.Stdout.Stat()
+	return err == nil && i.Mode()&os.ModeCharDevice != 0
+}
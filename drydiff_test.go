@@ -0,0 +1,61 @@
+package maestro_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/midbel/maestro"
+)
+
+func TestDryDiff(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(dryDiffFile))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	mst.MetaExec.History = filepath.Join(t.TempDir(), "history.json")
+	last := `{"command":"build","start":"2024-05-02T10:12:45Z","end":"2024-05-02T10:12:46Z","host":"local","script":["echo $VERSION $env","echo old"],"vars":{"VERSION":"1.0","env":"dev","tag":"x"}}`
+	if err := os.WriteFile(mst.MetaExec.History, []byte(last+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	mst.Stdout = &buf
+	if err := mst.Dry("build", []string{"-e", "prod"}); err != nil {
+		t.Fatalf("fail to run dry: %s", err)
+	}
+	parts := []string{
+		"changes since the last run of build (2024-05-02 10:12:45, ok):",
+		"  ~ VERSION",
+		"  ~ env",
+		"  - tag",
+		"    echo $VERSION $env",
+		"  - echo old",
+		"  + echo new",
+		"echo 1.1 prod",
+	}
+	got := buf.String()
+	for _, p := range parts {
+		if !strings.Contains(got, p) {
+			t.Errorf("%q not found in output", p)
+		}
+	}
+	if strings.Contains(got, "1.0") || strings.Contains(got, "dev") {
+		t.Errorf("values of the variables found in output")
+	}
+}
+
+const dryDiffFile = `
+VERSION = 1.1
+
+build(
+	options = (
+		short = e,
+		long  = env,
+	),
+): {
+	echo $VERSION $env
+	echo new
+}
+`
@@ -0,0 +1,86 @@
+package maestro
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/midbel/shlex"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	EntrypointEnv = "MAESTRO_CMD"
+	reaperEnv     = "MAESTRO_REAPED"
+)
+
+func (m *Maestro) Entrypoint(args []string) error {
+	var (
+		set       = flag.NewFlagSet(CmdEntrypoint, flag.ContinueOnError)
+		schedules = set.Bool("s", false, "run scheduled commands as sidecars")
+	)
+	if err := parseFlags(set, args); err != nil {
+		return err
+	}
+	name, args, err := m.entrypointCommand(set.Args())
+	if err != nil {
+		return err
+	}
+	if name == "" && !*schedules {
+		return fmt.Errorf("entrypoint: no command to execute")
+	}
+	if ok, err := reapZombies(); ok {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go forwardSignals(ctx, cancel)
+
+	grp, sub := errgroup.WithContext(ctx)
+	if *schedules {
+		cmds, err := m.scheduledCommands(nil, "")
+		if err != nil {
+			return err
+		}
+		grp.Go(func() error {
+			err := m.schedule(sub, cmds, m.Stdout, m.Stderr)
+			if sub.Err() != nil {
+				err = nil
+			}
+			return err
+		})
+	}
+	if name != "" {
+		grp.Go(func() error {
+			defer cancel()
+			return m.executeContext(sub, name, args, m.Stdout, m.Stderr)
+		})
+	}
+	return grp.Wait()
+}
+
+func (m *Maestro) entrypointCommand(args []string) (string, []string, error) {
+	if str := strings.TrimSpace(os.Getenv(EntrypointEnv)); str != "" {
+		list, err := shlex.Split(strings.NewReader(str))
+		if err != nil {
+			return "", nil, err
+		}
+		if len(list) > 0 {
+			return list[0], append(list[1:], args...), nil
+		}
+	}
+	if len(args) > 0 {
+		if _, ok := m.Commands.Get(args[0]); ok {
+			return args[0], args[1:], nil
+		}
+	}
+	return m.MetaExec.Default, args, nil
+}
+
+func isTermination(sig os.Signal) bool {
+	return sig == syscall.SIGINT || sig == syscall.SIGTERM || sig == syscall.SIGQUIT
+}
@@ -0,0 +1,144 @@
+//go:build linux
+
+package maestro
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+var forwarded = []os.Signal{
+	syscall.SIGINT,
+	syscall.SIGTERM,
+	syscall.SIGHUP,
+	syscall.SIGQUIT,
+	syscall.SIGUSR1,
+	syscall.SIGUSR2,
+}
+
+func forwardSignals(ctx context.Context, cancel context.CancelFunc) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, forwarded...)
+	defer signal.Stop(sig)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case s := <-sig:
+			for _, p := range children() {
+				syscall.Kill(p.pid, s.(syscall.Signal))
+			}
+			if isTermination(s) {
+				cancel()
+			}
+		}
+	}
+}
+
+// reapZombies executes maestro again as the only child of PID 1 and collects
+// every process ending under PID 1: this child and the orphans re-parented to
+// it. Since PID 1 starts nothing else, Wait4 never steals the exit status of a
+// command waited for by exec.Cmd.Wait in the child.
+func reapZombies() (bool, error) {
+	if os.Getpid() != 1 || os.Getenv(reaperEnv) != "" {
+		return false, nil
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return true, err
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, forwarded...)
+	defer signal.Stop(sig)
+
+	proc, err := os.StartProcess(self, os.Args, &os.ProcAttr{
+		Env:   append(os.Environ(), reaperEnv+"=1"),
+		Files: []*os.File{os.Stdin, os.Stdout, os.Stderr},
+	})
+	if err != nil {
+		return true, err
+	}
+	go func() {
+		for s := range sig {
+			syscall.Kill(proc.Pid, s.(syscall.Signal))
+		}
+	}()
+	for {
+		var status syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &status, 0, nil)
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err != nil {
+			return true, err
+		}
+		if pid != proc.Pid {
+			continue
+		}
+		switch {
+		case status.Signaled():
+			return true, fmt.Errorf("%s: %s", CmdEntrypoint, status.Signal())
+		case status.ExitStatus() != 0:
+			return true, fmt.Errorf("%s: exit status %d", CmdEntrypoint, status.ExitStatus())
+		default:
+			return true, nil
+		}
+	}
+}
+
+type process struct {
+	pid   int
+	ppid  int
+	state byte
+}
+
+func children() []process {
+	files, _ := filepath.Glob("/proc/[0-9]*/stat")
+	var (
+		list []process
+		self = os.Getpid()
+	)
+	for _, f := range files {
+		buf, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		p, ok := parseStat(buf)
+		if ok && p.ppid == self {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+func parseStat(buf []byte) (process, bool) {
+	var p process
+	var (
+		x = bytes.IndexByte(buf, '(')
+		y = bytes.LastIndexByte(buf, ')')
+	)
+	if x < 0 || y < x {
+		return p, false
+	}
+	pid, err := strconv.Atoi(string(bytes.TrimSpace(buf[:x])))
+	if err != nil {
+		return p, false
+	}
+	fields := bytes.Fields(buf[y+1:])
+	if len(fields) < 2 || len(fields[0]) == 0 {
+		return p, false
+	}
+	ppid, err := strconv.Atoi(string(fields[1]))
+	if err != nil {
+		return p, false
+	}
+	p.pid, p.ppid, p.state = pid, ppid, fields[0][0]
+	return p, true
+}
@@ -0,0 +1,33 @@
+//go:build !linux
+
+package maestro
+
+import (
+	"context"
+	"os"
+	"os/signal"
+)
+
+var forwarded = []os.Signal{
+	os.Interrupt,
+}
+
+func forwardSignals(ctx context.Context, cancel context.CancelFunc) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, forwarded...)
+	defer signal.Stop(sig)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case s := <-sig:
+			if isTermination(s) {
+				cancel()
+			}
+		}
+	}
+}
+
+func reapZombies() (bool, error) {
+	return false, nil
+}
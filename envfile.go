@@ -0,0 +1,131 @@
+package maestro
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var errMissingEnv = errors.New("missing environment variable(s)")
+
+type EnvFile struct {
+	File     string
+	Optional bool
+}
+
+func (e EnvFile) Load() (map[string]string, error) {
+	r, err := os.Open(e.File)
+	if err != nil {
+		if e.Optional && errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer r.Close()
+
+	var (
+		scan = bufio.NewScanner(r)
+		list = make(map[string]string)
+		line int
+	)
+	for scan.Scan() {
+		line++
+		str := strings.TrimSpace(scan.Text())
+		if str == "" || strings.HasPrefix(str, "#") {
+			continue
+		}
+		str = strings.TrimPrefix(str, "export ")
+		key, value, ok := strings.Cut(str, "=")
+		if key = strings.TrimSpace(key); !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: invalid line (KEY=VALUE expected)", e.File, line)
+		}
+		value, err = envValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", e.File, line, err)
+		}
+		list[key] = value
+	}
+	return list, scan.Err()
+}
+
+func envValue(str string) (string, error) {
+	if len(str) < 2 {
+		return str, nil
+	}
+	switch str[0] {
+	case '\'':
+		if str[len(str)-1] != '\'' {
+			return "", fmt.Errorf("unterminated quoted value")
+		}
+		return str[1 : len(str)-1], nil
+	case '"':
+		return strconv.Unquote(str)
+	default:
+		if i := strings.Index(str, " #"); i >= 0 {
+			str = strings.TrimSpace(str[:i])
+		}
+		return str, nil
+	}
+}
+
+func (s CommandSettings) envRequired() error {
+	if len(s.NeedsEnv) == 0 {
+		return nil
+	}
+	missing, err := s.missingEnv()
+	if err != nil || len(missing) == 0 {
+		return err
+	}
+	return fmt.Errorf("%s: %w: %s (set them in the environment of maestro, in an envfile or with export)", s.Command(), errMissingEnv, strings.Join(missing, ", "))
+}
+
+func (s CommandSettings) missingEnv() ([]string, error) {
+	env, err := s.environ()
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, n := range s.NeedsEnv {
+		v, ok := env[n]
+		if !ok && s.Inherit && s.Filter.Keep(n) {
+			v = os.Getenv(n)
+		}
+		if v == "" {
+			missing = append(missing, n)
+		}
+	}
+	return missing, nil
+}
+
+func (s CommandSettings) environ() (map[string]string, error) {
+	if len(s.EnvFiles) == 0 {
+		return s.Environ(), nil
+	}
+	ev := s.Ev.Copy()
+	for _, f := range s.EnvFiles {
+		list, err := f.Load()
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range list {
+			if s.Ev.Has(k) {
+				continue
+			}
+			ev.Set(k, v)
+		}
+	}
+	s.Ev = ev
+	return s.Environ(), nil
+}
+
+// exported gives the variables exported to the script (export and envfile)
+// without the environment of maestro, which is only inherited when the
+// command is executed.
+func (s CommandSettings) exported() (map[string]string, error) {
+	s.Inherit = false
+	return s.environ()
+}
@@ -0,0 +1,305 @@
+package maestro
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/midbel/maestro/internal/copyslice"
+)
+
+type explainStep struct {
+	Name   string
+	Args   []string
+	Bg     bool
+	Reason string
+}
+
+func (m *Maestro) Explain(args []string) error {
+	set := flag.NewFlagSet(CmdExplain, flag.ContinueOnError)
+	if err := parseFlags(set, args); err != nil {
+		return err
+	}
+	name, rest := m.MetaExec.Default, set.Args()
+	if len(rest) > 0 {
+		name, rest = rest[0], rest[1:]
+	}
+	cmd, err := m.Commands.Lookup(name)
+	if err != nil {
+		return m.suggest(err, name)
+	}
+	option := m.executeOption()
+	steps, err := m.explainSteps(cmd, option)
+	if err != nil {
+		return err
+	}
+	steps = append(steps, explainStep{Name: cmd.Command(), Args: rest})
+
+	var runs []explainStep
+	for _, n := range m.Before {
+		runs = append(runs, explainStep{Name: n})
+	}
+	for _, s := range steps {
+		if s.Reason == "" {
+			runs = append(runs, s)
+		}
+	}
+	for _, n := range m.After {
+		runs = append(runs, explainStep{Name: n})
+	}
+
+	fmt.Fprintf(m.Stdout, "command: %s", cmd.Command())
+	if cmd.File != "" {
+		fmt.Fprintf(m.Stdout, " (%s:%d)", cmd.File, cmd.Pos.Line)
+	}
+	fmt.Fprintln(m.Stdout)
+	if err := m.canExecute(cmd); err != nil {
+		fmt.Fprintf(m.Stdout, "warning: %s", err)
+		fmt.Fprintln(m.Stdout)
+	}
+
+	explainSection(m.Stdout, "variables", explainVariables(cmd))
+	explainSection(m.Stdout, "hooks", m.explainHooks())
+	explainSection(m.Stdout, "dependencies", explainDeps(steps, option))
+	explainSection(m.Stdout, "hosts", explainHosts(cmd))
+	explainSection(m.Stdout, "schedules", explainSchedules(cmd))
+	explainSection(m.Stdout, "settings", m.explainSettings(runs))
+
+	fmt.Fprintln(m.Stdout, "scripts:")
+	for _, s := range runs {
+		fmt.Fprintf(m.Stdout, "  %s:", s.Name)
+		fmt.Fprintln(m.Stdout)
+		for _, line := range m.explainScript(s.Name, s.Args) {
+			fmt.Fprintf(m.Stdout, "    %s", line)
+			fmt.Fprintln(m.Stdout)
+		}
+	}
+	return nil
+}
+
+func explainSection(w io.Writer, title string, lines []string) {
+	fmt.Fprintf(w, "%s:", title)
+	if len(lines) == 0 {
+		fmt.Fprint(w, " none")
+	}
+	fmt.Fprintln(w)
+	for _, line := range lines {
+		fmt.Fprintf(w, "  %s", line)
+		fmt.Fprintln(w)
+	}
+}
+
+func (m *Maestro) explainSteps(cmd CommandSettings, option ctreeOption) ([]explainStep, error) {
+	if option.NoDeps {
+		return nil, nil
+	}
+	deps, err := m.walkDependencies(cmd.Deps, option)
+	if err != nil {
+		return nil, err
+	}
+	var steps []explainStep
+	deps.walk(func(n depNode) {
+		step := explainStep{
+			Name:   n.Key(),
+			Reason: n.Reason,
+		}
+		if n.Reason == "" {
+			step.Args = append(copyslice.Copy(n.Args), option.Args[n.Key()]...)
+			step.Bg = n.Bg
+			if !n.Selected {
+				step.Reason = "not selected by --only-dep"
+			}
+		}
+		steps = append(steps, step)
+	})
+	return steps, nil
+}
+
+func explainDeps(steps []explainStep, option ctreeOption) []string {
+	if option.NoDeps {
+		return []string{"not executed (-k/--skip)"}
+	}
+	var (
+		lines []string
+		index int
+	)
+	for _, s := range steps {
+		if s.Reason != "" {
+			lines = append(lines, fmt.Sprintf("-  %s: %s", s.Name, s.Reason))
+			continue
+		}
+		index++
+		str := fmt.Sprintf("%d. %s", index, s.Name)
+		if len(s.Args) > 0 {
+			str = fmt.Sprintf("%s %s", str, strings.Join(s.Args, " "))
+		}
+		if s.Bg {
+			str += " (background)"
+		}
+		lines = append(lines, str)
+	}
+	return lines
+}
+
+func (m *Maestro) explainHooks() []string {
+	var lines []string
+	hooks := []struct {
+		Kind  string
+		Names []string
+	}{
+		{Kind: "before", Names: m.Before},
+		{Kind: "after", Names: m.After},
+		{Kind: "on error", Names: m.Error},
+		{Kind: "on success", Names: m.Success},
+	}
+	for _, h := range hooks {
+		if len(h.Names) == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", h.Kind, strings.Join(h.Names, ", ")))
+	}
+	return lines
+}
+
+func explainVariables(cmd CommandSettings) []string {
+	var (
+		refs    = make(map[string]bool)
+		defined = make(map[string]struct{})
+		lines   []string
+	)
+	for _, line := range cmd.Lines {
+		scanReferences(line, refs, defined)
+	}
+	environ, err := cmd.environ()
+	if err != nil {
+		environ = cmd.Environ()
+	}
+	options := make(map[string]CommandOption)
+	for _, o := range cmd.Options {
+		for _, n := range []string{o.Short, o.Long} {
+			if n != "" {
+				options[n] = o
+			}
+		}
+	}
+	arguments := make(map[string]struct{})
+	for _, a := range cmd.Args {
+		arguments[a.Name] = struct{}{}
+	}
+	var idents []string
+	for ident := range refs {
+		idents = append(idents, ident)
+	}
+	sort.Strings(idents)
+	for _, ident := range idents {
+		var source string
+		if o, ok := options[ident]; ok {
+			source = "option " + optionSignature(o)
+		} else if _, ok := arguments[ident]; ok {
+			source = "argument"
+		} else if cmd.locals.Defined(ident) {
+			vs, _ := cmd.locals.Resolve(ident)
+			source = fmt.Sprintf("local = %s", strings.Join(vs, " "))
+		} else if v, ok := environ[ident]; ok {
+			source = fmt.Sprintf("env = %s", v)
+		} else if _, ok := shellSpecials[ident]; ok {
+			source = "shell"
+		} else if _, ok := defined[ident]; ok {
+			source = "defined in script"
+		} else if refs[ident] {
+			source = "undefined"
+		} else {
+			source = "undefined (default used)"
+		}
+		lines = append(lines, fmt.Sprintf("$%s: %s", ident, source))
+	}
+	return lines
+}
+
+func explainHosts(cmd CommandSettings) []string {
+	if len(cmd.Hosts) == 0 {
+		return []string{HostLocal}
+	}
+	return copyslice.Copy(cmd.Hosts)
+}
+
+func explainSchedules(cmd CommandSettings) []string {
+	var lines []string
+	for _, s := range cmd.Schedules {
+		str := s.Time
+		if len(s.Args) > 0 {
+			str = fmt.Sprintf("%s (args: %s)", str, strings.Join(s.Args, " "))
+		}
+		if s.Sched != nil && !s.reboot() {
+			str = fmt.Sprintf("%s, next at %s", str, s.Sched.Now().Format("2006-01-02 15:04:05"))
+		}
+		lines = append(lines, str)
+	}
+	return lines
+}
+
+func (m *Maestro) explainSettings(runs []explainStep) []string {
+	var (
+		lines []string
+		seen  = make(map[string]struct{})
+	)
+	for _, r := range runs {
+		if _, ok := seen[r.Name]; ok {
+			continue
+		}
+		seen[r.Name] = struct{}{}
+		cmd, err := m.Commands.Lookup(r.Name)
+		if err != nil {
+			continue
+		}
+		for _, str := range commandSettings(cmd) {
+			lines = append(lines, fmt.Sprintf("%s: %s", r.Name, str))
+		}
+	}
+	return lines
+}
+
+func commandSettings(cmd CommandSettings) []string {
+	var lines []string
+	add := func(key string, value interface{}, set bool) {
+		if set {
+			lines = append(lines, fmt.Sprintf("%s = %v", key, value))
+		}
+	}
+	add("retry", cmd.Retry, cmd.Retry > 1)
+	add("delay", cmd.Delay, cmd.Delay > 0)
+	add("backoff", cmd.Backoff, cmd.Backoff > 0)
+	add("max_delay", cmd.MaxDelay, cmd.MaxDelay > 0)
+	add("timeout", cmd.Timeout, cmd.Timeout > 0)
+	add("workdir", cmd.WorkDir, cmd.WorkDir != "")
+	add("user", cmd.User, cmd.User != "")
+	add("umask", cmd.Umask, cmd.Umask != "")
+	add("confirm", cmd.Confirm, cmd.Confirm)
+	add("needs_env", strings.Join(cmd.NeedsEnv, " "), len(cmd.NeedsEnv) > 0)
+	add("sandbox", cmd.Sandbox, cmd.Sandbox)
+	add("cost", formatCost(cmd.Cost), cmd.Cost > 0)
+	for _, w := range cmd.Blackout {
+		state := "inactive"
+		if w.contains(time.Now()) {
+			state = "active"
+		}
+		add("blackout", fmt.Sprintf("%s (%s)", w.spec, state), true)
+	}
+	return lines
+}
+
+func (m *Maestro) explainScript(name string, args []string) []string {
+	cmd, err := m.setup(context.Background(), name, false)
+	if err != nil {
+		return []string{fmt.Sprintf("<error: %s>", err)}
+	}
+	lines, err := cmd.Script(args)
+	if err != nil {
+		return []string{fmt.Sprintf("<error: %s>", err)}
+	}
+	return lines
+}
@@ -0,0 +1,80 @@
+package maestro_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/midbel/maestro"
+)
+
+func TestExplain(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "maestro.mf")
+	if err := os.WriteFile(file, []byte(explainFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mst := maestro.New()
+	if err := mst.Load(file); err != nil {
+		t.Fatalf("fail to load file: %s", err)
+	}
+	mst.SkipDeps.Set("lint")
+
+	var buf bytes.Buffer
+	mst.Stdout = &buf
+	if err := mst.Explain([]string{"build", "-v"}); err != nil {
+		t.Fatalf("fail to explain: %s", err)
+	}
+	want := []string{
+		"variables:",
+		"  $VERSION: local = 1.0",
+		"  $verbose: option -v, --verbose [flag]",
+		"hooks:",
+		"  before: prep",
+		"dependencies:",
+		"  1. gen",
+		"  -  lint: skipped by --skip-dep",
+		"  -  missing: optional and not found",
+		"  2. build -v",
+		"hosts:",
+		"  local",
+		"schedules: none",
+		"settings:",
+		"  gen: retry = 3",
+		"scripts:",
+		"  prep:",
+		"    echo prep",
+		"  gen:",
+		"    echo gen 1.0",
+		"  build:",
+		"    echo build 1.0 true",
+	}
+	got := strings.TrimSpace(buf.String())
+	if _, rest, _ := strings.Cut(got, "\n"); rest != strings.Join(want, "\n") {
+		t.Errorf("explain mismatched!\nwant:\n%s\ngot:\n%s", strings.Join(want, "\n"), rest)
+	}
+}
+
+const explainFile = `
+.BEFORE = prep
+VERSION = 1.0
+
+prep: {
+	echo prep
+}
+
+gen(retry = 3): {
+	echo gen $VERSION
+}
+
+lint: {
+	echo lint
+}
+
+build(
+	options = (short = v, long = verbose, flag = true),
+): gen, lint, ?missing {
+	echo build $VERSION $verbose
+}
+`
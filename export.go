@@ -0,0 +1,320 @@
+package maestro
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	formatGithub   = "github"
+	formatGitlab   = "gitlab"
+	formatSchtasks = "schtasks"
+	formatLaunchd  = "launchd"
+	formatShell    = "shell"
+)
+
+const installMaestro = "go install github.com/midbel/maestro/cmd/maestro@latest"
+
+type pipelineJob struct {
+	Id    string
+	Name  string
+	Stage int
+	Needs []string
+	order int
+}
+
+func (m *Maestro) Export(args []string) error {
+	var (
+		set    = flag.NewFlagSet(CmdExport, flag.ContinueOnError)
+		format string
+		file   = set.String("o", "", "write pipeline to file (directory for launchd and shell)")
+		runner = set.String("r", "", "runner (github), image (gitlab) or maestro program (schtasks, launchd)")
+		dir    = set.String("dir", "", "write standalone shell scripts to directory (implies -f shell)")
+	)
+	set.StringVar(&format, "f", formatGithub, "export format (github, gitlab, schtasks, launchd, shell)")
+	set.StringVar(&format, "format", formatGithub, "export format (github, gitlab, schtasks, launchd, shell)")
+	if err := parseFlags(set, args); err != nil {
+		return err
+	}
+	if *dir != "" {
+		var explicit bool
+		set.Visit(func(f *flag.Flag) {
+			explicit = explicit || f.Name == "f" || f.Name == "format"
+		})
+		if explicit && format != formatShell {
+			return fmt.Errorf("%s: -dir can only be used with the shell format", format)
+		}
+		if *file != "" && *file != *dir {
+			return fmt.Errorf("-dir and -o can not be used together")
+		}
+		format, *file = formatShell, *dir
+	}
+	var write func(io.Writer)
+	switch format {
+	case formatGithub, formatGitlab:
+		jobs, err := m.pipeline(set.Args())
+		if err != nil {
+			return err
+		}
+		write = func(w io.Writer) {
+			if format == formatGithub {
+				if *runner == "" {
+					*runner = "ubuntu-latest"
+				}
+				writeGithub(w, jobs, m.MetaAbout.File, *runner)
+			} else {
+				if *runner == "" {
+					*runner = "golang:latest"
+				}
+				writeGitlab(w, jobs, m.MetaAbout.File, *runner)
+			}
+		}
+	case formatSchtasks:
+		if *runner == "" {
+			*runner = schtasksProgram
+		}
+		tasks, err := m.schtasks(set.Args(), *runner)
+		if err != nil {
+			return err
+		}
+		write = func(w io.Writer) {
+			writeSchtasks(w, tasks, m.MetaAbout.File)
+		}
+	case formatLaunchd:
+		if *runner == "" {
+			*runner = launchdProgram()
+		}
+		agents, err := m.launchd(set.Args(), *runner)
+		if err != nil {
+			return err
+		}
+		if *file != "" {
+			return writeLaunchdDir(*file, agents)
+		}
+		write = func(w io.Writer) {
+			for _, a := range agents {
+				writePlist(w, a)
+			}
+		}
+	case formatShell:
+		scripts, err := m.standalone(set.Args())
+		if err != nil {
+			return err
+		}
+		if *file == "" {
+			*file = DefaultScriptDir
+		}
+		return writeStandaloneDir(*file, scripts, m.MetaAbout.File)
+	default:
+		return fmt.Errorf("%s: unsupported export format", format)
+	}
+	var w io.Writer = m.Stdout
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	write(w)
+	return nil
+}
+
+type scheduledJob struct {
+	Name     string
+	Dir      string
+	Args     []string
+	Command  CommandSettings
+	Schedule Schedule
+}
+
+func (m *Maestro) scheduledJobs(names []string) ([]scheduledJob, error) {
+	cmds, err := m.scheduledCommands(names, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(cmds) == 0 {
+		return nil, fmt.Errorf("no scheduled command found")
+	}
+	dir, err := filepath.Abs(filepath.Dir(m.MetaAbout.File))
+	if err != nil {
+		return nil, err
+	}
+	var jobs []scheduledJob
+	for _, c := range cmds {
+		if err := m.confirmRequired(c); err != nil {
+			return nil, err
+		}
+		for i, s := range c.Schedules {
+			j := scheduledJob{
+				Name:     jobIdent(c.Command()),
+				Dir:      dir,
+				Command:  c,
+				Schedule: s,
+			}
+			if len(c.Schedules) > 1 {
+				j.Name = fmt.Sprintf("%s-%d", j.Name, i+1)
+			}
+			j.Args = append(j.Args, "-f", filepath.Base(m.MetaAbout.File))
+			if c.Confirm {
+				j.Args = append(j.Args, "--yes")
+			}
+			j.Args = append(j.Args, c.Command())
+			j.Args = append(j.Args, s.Args...)
+			jobs = append(jobs, j)
+		}
+	}
+	return jobs, nil
+}
+
+func (m *Maestro) pipeline(names []string) ([]pipelineJob, error) {
+	if len(names) == 0 {
+		for _, c := range m.Commands.Values() {
+			if c.Visible {
+				names = append(names, c.Command())
+			}
+		}
+	}
+	var (
+		jobs     = make(map[string]*pipelineJob)
+		visiting = make(map[string]bool)
+		traverse func(string) (*pipelineJob, error)
+	)
+	traverse = func(name string) (*pipelineJob, error) {
+		if j, ok := jobs[name]; ok {
+			return j, nil
+		}
+		if visiting[name] {
+			return nil, fmt.Errorf("%s: dependency cycle detected", name)
+		}
+		visiting[name] = true
+		defer delete(visiting, name)
+
+		cmd, err := m.Commands.Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		job := pipelineJob{
+			Id:   jobIdent(name),
+			Name: name,
+		}
+		for _, d := range cmd.Deps {
+			if _, err := m.Commands.Lookup(d.Key()); err != nil && d.Optional {
+				continue
+			}
+			other, err := traverse(d.Key())
+			if err != nil {
+				return nil, err
+			}
+			if other.Stage >= job.Stage {
+				job.Stage = other.Stage + 1
+			}
+			job.Needs = append(job.Needs, other.Id)
+		}
+		job.order = len(jobs)
+		jobs[name] = &job
+		return &job, nil
+	}
+	for _, n := range names {
+		if _, err := traverse(n); err != nil {
+			return nil, err
+		}
+	}
+	list := make([]pipelineJob, 0, len(jobs))
+	for _, j := range jobs {
+		list = append(list, *j)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Stage == list[j].Stage {
+			return list[i].order < list[j].order
+		}
+		return list[i].Stage < list[j].Stage
+	})
+	return list, nil
+}
+
+func writeGithub(w io.Writer, jobs []pipelineJob, file, runner string) {
+	fmt.Fprintln(w, "name: maestro")
+	fmt.Fprintln(w, "on:")
+	fmt.Fprintln(w, "  push:")
+	fmt.Fprintln(w, "  workflow_dispatch:")
+	fmt.Fprintln(w, "jobs:")
+	for _, j := range jobs {
+		fmt.Fprintf(w, "  %s:", j.Id)
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "    name: %s", strconv.Quote(j.Name))
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "    runs-on: %s", strconv.Quote(runner))
+		fmt.Fprintln(w)
+		if len(j.Needs) > 0 {
+			fmt.Fprintf(w, "    needs: [%s]", strings.Join(j.Needs, ", "))
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, "    steps:")
+		fmt.Fprintln(w, "      - uses: actions/checkout@v4")
+		fmt.Fprintln(w, "      - uses: actions/setup-go@v5")
+		fmt.Fprintf(w, "      - run: %s", strconv.Quote(installMaestro))
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "      - run: %s", strconv.Quote(jobCommand(file, j.Name)))
+		fmt.Fprintln(w)
+	}
+}
+
+func writeGitlab(w io.Writer, jobs []pipelineJob, file, image string) {
+	var stages []string
+	for _, j := range jobs {
+		if s := jobStage(j); len(stages) == 0 || stages[len(stages)-1] != s {
+			stages = append(stages, s)
+		}
+	}
+	fmt.Fprintln(w, "stages:")
+	for _, s := range stages {
+		fmt.Fprintf(w, "  - %s", s)
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "default:")
+	fmt.Fprintf(w, "  image: %s", strconv.Quote(image))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "  before_script:")
+	fmt.Fprintf(w, "    - %s", strconv.Quote(installMaestro))
+	fmt.Fprintln(w)
+	for _, j := range jobs {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "%s:", j.Id)
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "  stage: %s", jobStage(j))
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "  needs: [%s]", strings.Join(j.Needs, ", "))
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "  script:")
+		fmt.Fprintf(w, "    - %s", strconv.Quote(jobCommand(file, j.Name)))
+		fmt.Fprintln(w)
+	}
+}
+
+func jobCommand(file, name string) string {
+	return fmt.Sprintf("maestro -f %s -k %s", file, name)
+}
+
+func jobStage(j pipelineJob) string {
+	return fmt.Sprintf("stage-%d", j.Stage)
+}
+
+func jobIdent(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return '-'
+		}
+	}, name)
+}
@@ -0,0 +1,233 @@
+package maestro
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/midbel/maestro/internal/env"
+)
+
+var errDivZero = errors.New("division by zero")
+
+type builtinFunc func(args []string) ([]string, error)
+
+var builtins = map[string]builtinFunc{
+	"upper":   callUpper,
+	"lower":   callLower,
+	"trim":    callTrim,
+	"join":    callJoin,
+	"split":   callSplit,
+	"replace": callReplace,
+}
+
+func callUpper(args []string) ([]string, error) {
+	return mapValues(args, strings.ToUpper), nil
+}
+
+func callLower(args []string) ([]string, error) {
+	return mapValues(args, strings.ToLower), nil
+}
+
+func callTrim(args []string) ([]string, error) {
+	return mapValues(args, strings.TrimSpace), nil
+}
+
+func callJoin(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("join: separator expected")
+	}
+	return []string{strings.Join(args[1:], args[0])}, nil
+}
+
+func callSplit(args []string) ([]string, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("split: separator and value expected")
+	}
+	return strings.Split(args[1], args[0]), nil
+}
+
+func callReplace(args []string) ([]string, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("replace: old and new values expected")
+	}
+	rpl := strings.NewReplacer(args[0], args[1])
+	return mapValues(args[2:], rpl.Replace), nil
+}
+
+func mapValues(args []string, fn func(string) string) []string {
+	vs := make([]string, len(args))
+	for i := range args {
+		vs[i] = fn(args[i])
+	}
+	return vs
+}
+
+func evalArithmetic(str string, ev *env.Env) (int64, error) {
+	a := arith{
+		input: str,
+		env:   ev,
+	}
+	n, err := a.expr()
+	if err != nil {
+		return 0, err
+	}
+	if a.skipBlank(); a.pos < len(a.input) {
+		return 0, fmt.Errorf("%s: unexpected character %q", str, a.input[a.pos])
+	}
+	return n, nil
+}
+
+type arith struct {
+	input string
+	pos   int
+	env   *env.Env
+}
+
+func (a *arith) expr() (int64, error) {
+	left, err := a.term()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := a.operator("+-")
+		if op == 0 {
+			return left, nil
+		}
+		right, err := a.term()
+		if err != nil {
+			return 0, err
+		}
+		if op == '+' {
+			left += right
+		} else {
+			left -= right
+		}
+	}
+}
+
+func (a *arith) term() (int64, error) {
+	left, err := a.unary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := a.operator("*/%")
+		if op == 0 {
+			return left, nil
+		}
+		right, err := a.unary()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case '*':
+			left *= right
+		case '/', '%':
+			if right == 0 {
+				return 0, errDivZero
+			}
+			if op == '/' {
+				left /= right
+			} else {
+				left %= right
+			}
+		}
+	}
+}
+
+func (a *arith) unary() (int64, error) {
+	switch a.operator("+-") {
+	case '-':
+		n, err := a.unary()
+		return -n, err
+	case '+':
+		return a.unary()
+	default:
+		return a.primary()
+	}
+}
+
+func (a *arith) primary() (int64, error) {
+	a.skipBlank()
+	if a.pos >= len(a.input) {
+		return 0, fmt.Errorf("%s: unexpected end of expression", a.input)
+	}
+	switch c := a.input[a.pos]; {
+	case c == lparen:
+		a.pos++
+		n, err := a.expr()
+		if err != nil {
+			return 0, err
+		}
+		if a.operator(")") == 0 {
+			return 0, fmt.Errorf("%s: missing closing parenthesis", a.input)
+		}
+		return n, nil
+	case isDigit(rune(c)):
+		return strconv.ParseInt(a.read(isDigit), 10, 64)
+	case c == dollar || isLetter(rune(c)) || c == underscore:
+		return a.variable()
+	default:
+		return 0, fmt.Errorf("%s: unexpected character %q", a.input, c)
+	}
+}
+
+func (a *arith) variable() (int64, error) {
+	var enclosed bool
+	if a.input[a.pos] == dollar {
+		a.pos++
+		if enclosed = a.pos < len(a.input) && a.input[a.pos] == lcurly; enclosed {
+			a.pos++
+		}
+	}
+	ident := a.read(isIdent)
+	if enclosed {
+		if a.pos >= len(a.input) || a.input[a.pos] != rcurly {
+			return 0, fmt.Errorf("%s: missing closing brace", a.input)
+		}
+		a.pos++
+	}
+	if ident == "" {
+		return 0, fmt.Errorf("%s: variable name expected", a.input)
+	}
+	if !a.env.Defined(ident) {
+		return 0, fmt.Errorf("%s: variable not defined", ident)
+	}
+	vs, err := a.env.Resolve(ident)
+	if err != nil {
+		return 0, err
+	}
+	if len(vs) != 1 {
+		return 0, fmt.Errorf("%s: single value expected", ident)
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(vs[0]), 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: not a number", ident)
+	}
+	return n, nil
+}
+
+func (a *arith) operator(accept string) byte {
+	a.skipBlank()
+	if a.pos >= len(a.input) || strings.IndexByte(accept, a.input[a.pos]) < 0 {
+		return 0
+	}
+	a.pos++
+	return a.input[a.pos-1]
+}
+
+func (a *arith) read(accept func(rune) bool) string {
+	pos := a.pos
+	for a.pos < len(a.input) && accept(rune(a.input[a.pos])) {
+		a.pos++
+	}
+	return a.input[pos:a.pos]
+}
+
+func (a *arith) skipBlank() {
+	for a.pos < len(a.input) && isBlank(rune(a.input[a.pos])) {
+		a.pos++
+	}
+}
@@ -0,0 +1,114 @@
+package maestro
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	graphTree = "tree"
+	graphDot  = "dot"
+	graphJSON = "json"
+)
+
+type graphNode struct {
+	Name  string `json:"name"`
+	Short string `json:"short,omitempty"`
+}
+
+type graphEdge struct {
+	From       string   `json:"from"`
+	To         string   `json:"to"`
+	Args       []string `json:"args,omitempty"`
+	Optional   bool     `json:"optional"`
+	Mandatory  bool     `json:"mandatory"`
+	Background bool     `json:"background"`
+}
+
+type dependencyGraph struct {
+	Root  string      `json:"root"`
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+func (m *Maestro) graph(name string) (dependencyGraph, error) {
+	var (
+		g        = dependencyGraph{Root: name, Edges: []graphEdge{}}
+		seen     = make(map[string]struct{})
+		traverse func(string) error
+	)
+	traverse = func(name string) error {
+		if _, ok := seen[name]; ok {
+			return nil
+		}
+		seen[name] = struct{}{}
+		cmd, err := m.Commands.Lookup(name)
+		if err != nil {
+			return err
+		}
+		g.Nodes = append(g.Nodes, graphNode{
+			Name:  name,
+			Short: cmd.Short,
+		})
+		for _, d := range cmd.Deps {
+			if _, err := m.Commands.Lookup(d.Key()); err != nil && d.Optional {
+				continue
+			}
+			g.Edges = append(g.Edges, graphEdge{
+				From:       name,
+				To:         d.Key(),
+				Args:       d.Args,
+				Optional:   d.Optional,
+				Mandatory:  d.Mandatory,
+				Background: d.Bg,
+			})
+			if err := traverse(d.Key()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return g, traverse(name)
+}
+
+func writeDot(w io.Writer, g dependencyGraph) {
+	fmt.Fprintf(w, "digraph %s {", strconv.Quote(g.Root))
+	fmt.Fprintln(w)
+	for _, n := range g.Nodes {
+		fmt.Fprintf(w, "  %s", strconv.Quote(n.Name))
+		if n.Short != "" {
+			fmt.Fprintf(w, " [tooltip=%s]", strconv.Quote(n.Short))
+		}
+		fmt.Fprintln(w, ";")
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(w, "  %s -> %s", strconv.Quote(e.From), strconv.Quote(e.To))
+		var attrs []string
+		if e.Optional {
+			attrs = append(attrs, "style=dashed")
+		}
+		if e.Mandatory {
+			attrs = append(attrs, "style=bold")
+		}
+		if e.Background {
+			attrs = append(attrs, "arrowhead=empty")
+		}
+		if len(e.Args) > 0 {
+			attrs = append(attrs, fmt.Sprintf("label=%s", strconv.Quote(strings.Join(e.Args, " "))))
+		}
+		if len(attrs) > 0 {
+			fmt.Fprintf(w, " [%s]", strings.Join(attrs, ", "))
+		}
+		fmt.Fprintln(w, ";")
+	}
+	fmt.Fprintln(w, "}")
+}
+
+func writeGraphJSON(w io.Writer, g dependencyGraph) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(g)
+}
@@ -0,0 +1,200 @@
+//go:build linux
+
+package maestro
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const hardenEnv = "MAESTRO_HARDEN"
+
+const (
+	prSetNoNewPrivs   = 38
+	prSetSeccomp      = 22
+	seccompModeFilter = 2
+	seccompRetAllow   = 0x7fff0000
+	seccompRetErrno   = 0x00050000
+	seccompRetKill    = 0x80000000
+
+	// x32 syscalls are the numbers of amd64 with this bit set: they are
+	// refused since they would pass every rule of the filter
+	syscallX32 = 0x40000000
+	// clone3 has the same number on every architecture but it is not
+	// defined by the syscall package
+	sysClone3 = 435
+	// namespace flags of clone (CLONE_NEWNS to CLONE_NEWNET)
+	cloneNamespaces = 0x7e020000
+)
+
+var auditArch = map[string]uint32{
+	"386":     0x40000003,
+	"amd64":   0xc000003e,
+	"arm":     0x40000028,
+	"arm64":   0xc00000b7,
+	"riscv64": 0xc00000f3,
+	"ppc64le": 0xc0000015,
+	"s390x":   0x80000016,
+}
+
+// cloneFlags gives the offset of the lower half of the flags argument of clone
+// in the seccomp data when it is not the first argument of a little endian
+// architecture
+var cloneFlags = map[string]uint32{
+	"s390x": 28,
+}
+
+var seccompSyscalls = map[string]uintptr{
+	"acct":            syscall.SYS_ACCT,
+	"add_key":         syscall.SYS_ADD_KEY,
+	"chroot":          syscall.SYS_CHROOT,
+	"clock_settime":   syscall.SYS_CLOCK_SETTIME,
+	"delete_module":   syscall.SYS_DELETE_MODULE,
+	"init_module":     syscall.SYS_INIT_MODULE,
+	"kexec_load":      syscall.SYS_KEXEC_LOAD,
+	"keyctl":          syscall.SYS_KEYCTL,
+	"mount":           syscall.SYS_MOUNT,
+	"perf_event_open": syscall.SYS_PERF_EVENT_OPEN,
+	"pivot_root":      syscall.SYS_PIVOT_ROOT,
+	"ptrace":          syscall.SYS_PTRACE,
+	"reboot":          syscall.SYS_REBOOT,
+	"request_key":     syscall.SYS_REQUEST_KEY,
+	"setdomainname":   syscall.SYS_SETDOMAINNAME,
+	"sethostname":     syscall.SYS_SETHOSTNAME,
+	"settimeofday":    syscall.SYS_SETTIMEOFDAY,
+	"swapoff":         syscall.SYS_SWAPOFF,
+	"swapon":          syscall.SYS_SWAPON,
+	"umount2":         syscall.SYS_UMOUNT2,
+	"unshare":         syscall.SYS_UNSHARE,
+}
+
+func checkSeccomp(names []string) error {
+	for _, n := range names {
+		if n == seccompDefault {
+			continue
+		}
+		if _, ok := seccompSyscalls[n]; !ok {
+			return fmt.Errorf("%s: unsupported syscall", n)
+		}
+	}
+	return nil
+}
+
+func hardenCommand(cmd *exec.Cmd, names []string) ([]string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Args = append([]string{exe}, cmd.Args...)
+	cmd.Path = exe
+	extra := []string{hardenEnv + "=" + strings.Join(names, ",")}
+	cmd.Env = append(os.Environ(), extra...)
+	return extra, nil
+}
+
+func ExecHardened() error {
+	str, ok := os.LookupEnv(hardenEnv)
+	if !ok {
+		return nil
+	}
+	os.Unsetenv(hardenEnv)
+	if len(os.Args) < 2 {
+		return fmt.Errorf("hardening: command expected")
+	}
+	path, err := exec.LookPath(os.Args[1])
+	if err != nil {
+		return err
+	}
+	runtime.LockOSThread()
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return fmt.Errorf("hardening: no_new_privs: %w", errno)
+	}
+	if str != "" {
+		if err := loadSeccomp(strings.Split(str, ",")); err != nil {
+			return fmt.Errorf("hardening: seccomp: %w", err)
+		}
+	}
+	return syscall.Exec(path, os.Args[1:], os.Environ())
+}
+
+func loadSeccomp(names []string) error {
+	arch, ok := auditArch[runtime.GOARCH]
+	if !ok {
+		return fmt.Errorf("%s: unsupported architecture", runtime.GOARCH)
+	}
+	var (
+		deny  []uintptr
+		clone bool
+	)
+	for _, n := range names {
+		if n == seccompDefault {
+			for _, nr := range seccompSyscalls {
+				deny = append(deny, nr)
+			}
+			clone = true
+			continue
+		}
+		nr, ok := seccompSyscalls[n]
+		if !ok {
+			return fmt.Errorf("%s: unsupported syscall", n)
+		}
+		deny = append(deny, nr)
+		clone = clone || nr == syscall.SYS_UNSHARE
+	}
+	var (
+		eperm  = seccompRetErrno | uint32(syscall.EPERM)
+		filter = []syscall.SockFilter{
+			bpfStmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, 4),
+			bpfJump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, arch, 1, 0),
+			bpfStmt(syscall.BPF_RET|syscall.BPF_K, seccompRetKill),
+			bpfStmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, 0),
+			bpfJump(syscall.BPF_JMP|syscall.BPF_JGE|syscall.BPF_K, syscallX32, 0, 1),
+			bpfStmt(syscall.BPF_RET|syscall.BPF_K, eperm),
+		}
+	)
+	for _, nr := range deny {
+		filter = append(filter,
+			bpfJump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, uint32(nr), 0, 1),
+			bpfStmt(syscall.BPF_RET|syscall.BPF_K, eperm),
+		)
+	}
+	if clone {
+		// the flags of clone3 are given in a struct that seccomp can not read:
+		// the C library falls back to clone when clone3 is not implemented
+		offset, ok := cloneFlags[runtime.GOARCH]
+		if !ok {
+			offset = 16
+		}
+		filter = append(filter,
+			bpfJump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, sysClone3, 0, 1),
+			bpfStmt(syscall.BPF_RET|syscall.BPF_K, seccompRetErrno|uint32(syscall.ENOSYS)),
+			bpfJump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, uint32(syscall.SYS_CLONE), 0, 3),
+			bpfStmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, offset),
+			bpfJump(syscall.BPF_JMP|syscall.BPF_JSET|syscall.BPF_K, cloneNamespaces, 0, 1),
+			bpfStmt(syscall.BPF_RET|syscall.BPF_K, eperm),
+		)
+	}
+	filter = append(filter, bpfStmt(syscall.BPF_RET|syscall.BPF_K, seccompRetAllow))
+	prog := syscall.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: &filter[0],
+	}
+	_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetSeccomp, seccompModeFilter, uintptr(unsafe.Pointer(&prog)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func bpfStmt(code uint16, k uint32) syscall.SockFilter {
+	return syscall.SockFilter{Code: code, K: k}
+}
+
+func bpfJump(code uint16, k uint32, jt, jf uint8) syscall.SockFilter {
+	return syscall.SockFilter{Code: code, K: k, Jt: jt, Jf: jf}
+}
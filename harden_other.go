@@ -0,0 +1,20 @@
+//go:build !linux
+
+package maestro
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+func checkSeccomp(_ []string) error {
+	return nil
+}
+
+func hardenCommand(_ *exec.Cmd, _ []string) ([]string, error) {
+	return nil, fmt.Errorf("hardening not supported on this platform")
+}
+
+func ExecHardened() error {
+	return nil
+}
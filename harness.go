@@ -0,0 +1,125 @@
+package maestro
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/midbel/tish"
+)
+
+func (m *Maestro) Test(args []string) error {
+	var (
+		set    = flag.NewFlagSet(CmdTest, flag.ContinueOnError)
+		format = set.String("f", "tap", "report format (tap, junit)")
+		file   = set.String("o", "", "write report to file")
+	)
+	if err := parseFlags(set, args); err != nil {
+		return err
+	}
+	report, err := getReporter(*format)
+	if err != nil {
+		return err
+	}
+	var (
+		ctx     = interruptContext()
+		names   = set.Args()
+		results []TestResult
+		failed  int
+	)
+	sort.Strings(names)
+	if err := m.evaluate(); err != nil {
+		return err
+	}
+	for _, c := range m.Commands.Values() {
+		if !c.IsTest() {
+			continue
+		}
+		x := sort.SearchStrings(names, c.Command())
+		if len(names) > 0 && (x >= len(names) || names[x] != c.Command()) {
+			continue
+		}
+		res := m.runTest(ctx, c)
+		if res.Failed() {
+			failed++
+		}
+		results = append(results, res)
+	}
+	var w io.Writer = m.Stdout
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := report.Report(w, results); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d/%d test(s) failed", failed, len(results))
+	}
+	return nil
+}
+
+func (m *Maestro) runTest(ctx context.Context, cmd CommandSettings) TestResult {
+	var (
+		res = TestResult{
+			Name: cmd.Command(),
+		}
+		buf = newSpillBuffer(m.MetaExec.SpillThreshold)
+		now = time.Now()
+	)
+	defer buf.Close()
+	cmd, x, err := m.setupCommand(ctx, cmd.Command(), false)
+	if err != nil {
+		res.Failures = append(res.Failures, err.Error())
+		return res
+	}
+	option := ctreeOption{
+		NoDeps: m.NoDeps,
+	}
+	ex, err := m.resolve(x, nil, option)
+	if err != nil {
+		res.Failures = append(res.Failures, err.Error())
+		return res
+	}
+	if c, ok := ex.(io.Closer); ok {
+		defer c.Close()
+	}
+	err = ex.Execute(ctx, buf, buf)
+
+	res.Elapsed = time.Since(now)
+	res.Output = buf.String()
+	dir := cmd.workDir()
+	if dir == "" {
+		dir = filepath.Dir(m.MetaAbout.File)
+	}
+	res.Failures = cmd.Expect.Check(exitCode(err), buf.Reader(), dir)
+	return res
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var (
+		e    interface{ ExitCode() int }
+		code tish.ExitCode
+	)
+	switch {
+	case errors.As(err, &e):
+		return e.ExitCode()
+	case errors.As(err, &code):
+		return int(uint8(code))
+	default:
+		return 1
+	}
+}
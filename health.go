@@ -0,0 +1,110 @@
+package maestro
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+const (
+	schedIdle = iota
+	schedRunning
+	schedStopped
+)
+
+type healthState struct {
+	mu    sync.Mutex
+	sched int
+	err   error
+}
+
+func (h *healthState) setSchedule(state int, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sched = state
+	h.err = err
+}
+
+func (h *healthState) schedule() (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.sched, h.err
+}
+
+type readyCheck struct {
+	Name string
+	Err  error
+}
+
+func ServeHealth(mst *Maestro) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}
+	return http.HandlerFunc(fn)
+}
+
+func ServeReady(mst *Maestro) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		var (
+			checks = mst.ready()
+			code   = http.StatusOK
+		)
+		for _, c := range checks {
+			if c.Err != nil {
+				code = http.StatusServiceUnavailable
+				break
+			}
+		}
+		w.WriteHeader(code)
+		for _, c := range checks {
+			status := "ok"
+			if c.Err != nil {
+				status = c.Err.Error()
+			}
+			fmt.Fprintf(w, "%s: %s", c.Name, status)
+			fmt.Fprintln(w)
+		}
+	}
+	return http.HandlerFunc(fn)
+}
+
+func (m *Maestro) ready() []readyCheck {
+	var list []readyCheck
+
+	var err error
+	if m.Commands.Len() == 0 {
+		err = fmt.Errorf("no command loaded")
+	}
+	list = append(list, readyCheck{Name: "file", Err: err})
+
+	if state, err := m.health.schedule(); state != schedIdle {
+		if err == nil && state == schedStopped {
+			err = fmt.Errorf("scheduler stopped")
+		}
+		list = append(list, readyCheck{Name: "scheduler", Err: err})
+	}
+	var (
+		remote bool
+		ssh    error
+	)
+	for _, c := range m.Commands.Values() {
+		for _, h := range c.RemoteHosts() {
+			remote = true
+			if ssh != nil {
+				break
+			}
+			config := c.HostSettings[h].ClientConfig(m.MetaSSH)
+			switch {
+			case config.User == "":
+				ssh = fmt.Errorf("%s: no user defined", h)
+			case len(config.Auth) == 0:
+				ssh = fmt.Errorf("%s: no password or key defined", h)
+			}
+		}
+	}
+	if remote {
+		list = append(list, readyCheck{Name: "ssh", Err: ssh})
+	}
+	return list
+}
@@ -0,0 +1,125 @@
+package maestro
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type HistoryEntry struct {
+	Command string    `json:"command"`
+	Args    []string  `json:"args,omitempty"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Host    string    `json:"host,omitempty"`
+	Code    int       `json:"code"`
+	Error   string    `json:"error,omitempty"`
+
+	Script []string          `json:"script,omitempty"`
+	Vars   map[string]string `json:"vars,omitempty"`
+}
+
+func createEntryHistory(name string, args []string, host string, start time.Time, err error) HistoryEntry {
+	e := HistoryEntry{
+		Command: name,
+		Args:    args,
+		Start:   start,
+		End:     time.Now(),
+		Host:    host,
+		Code:    exitCode(err),
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return e
+}
+
+func (h HistoryEntry) Elapsed() time.Duration {
+	return h.End.Sub(h.Start)
+}
+
+func (h HistoryEntry) Failed() bool {
+	return h.Error != ""
+}
+
+func appendHistory(file string, e HistoryEntry) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	w, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	return json.NewEncoder(w).Encode(e)
+}
+
+func readHistory(file string) ([]HistoryEntry, error) {
+	r, err := os.Open(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return nil, err
+	}
+	defer r.Close()
+
+	var (
+		list []HistoryEntry
+		dec  = json.NewDecoder(r)
+	)
+	for {
+		var e HistoryEntry
+		if err := dec.Decode(&e); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, nil
+}
+
+type CommandStats struct {
+	Command  string
+	Runs     int
+	Failures int
+	Total    time.Duration
+	Last     time.Time
+}
+
+func (c CommandStats) Average() time.Duration {
+	if c.Runs == 0 {
+		return 0
+	}
+	return c.Total / time.Duration(c.Runs)
+}
+
+func (c CommandStats) FailureRate() float64 {
+	if c.Runs == 0 {
+		return 0
+	}
+	return float64(c.Failures) / float64(c.Runs)
+}
+
+func computeStats(list []HistoryEntry) map[string]CommandStats {
+	stats := make(map[string]CommandStats)
+	for _, e := range list {
+		s := stats[e.Command]
+		s.Command = e.Command
+		s.Runs++
+		s.Total += e.Elapsed()
+		if e.Failed() {
+			s.Failures++
+		}
+		if e.Start.After(s.Last) {
+			s.Last = e.Start
+		}
+		stats[e.Command] = s
+	}
+	return stats
+}
@@ -0,0 +1,159 @@
+package maestro_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/midbel/maestro"
+)
+
+func TestRecordHistory(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(history))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	var buf bytes.Buffer
+	mst.Stdout, mst.Stderr = &buf, &buf
+	mst.MetaExec.History = filepath.Join(t.TempDir(), "history", "runs.json")
+
+	if err := mst.Execute("build", nil); err != nil {
+		t.Fatalf("fail to execute build: %s", err)
+	}
+	if err := mst.Execute("broken", nil); err == nil {
+		t.Fatalf("broken should fail")
+	}
+	r, err := os.Open(mst.MetaExec.History)
+	if err != nil {
+		t.Fatalf("history not recorded: %s", err)
+	}
+	defer r.Close()
+
+	var (
+		list []maestro.HistoryEntry
+		dec  = json.NewDecoder(r)
+	)
+	for {
+		var e maestro.HistoryEntry
+		if err := dec.Decode(&e); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			t.Fatalf("fail to decode history: %s", err)
+		}
+		list = append(list, e)
+	}
+	if len(list) != 2 {
+		t.Fatalf("entries mismatched! want 2, got %d", len(list))
+	}
+	want := []struct {
+		Command string
+		Code    int
+		Failed  bool
+	}{
+		{Command: "build"},
+		{Command: "broken", Code: 3, Failed: true},
+	}
+	for i, w := range want {
+		e := list[i]
+		if e.Command != w.Command {
+			t.Errorf("%d: command mismatched! want %s, got %s", i, w.Command, e.Command)
+		}
+		if e.Code != w.Code {
+			t.Errorf("%s: code mismatched! want %d, got %d", e.Command, w.Code, e.Code)
+		}
+		if e.Failed() != w.Failed {
+			t.Errorf("%s: failure mismatched! want %t, got %t (%s)", e.Command, w.Failed, e.Failed(), e.Error)
+		}
+		if e.Host != maestro.HostLocal {
+			t.Errorf("%s: host mismatched! want %s, got %s", e.Command, maestro.HostLocal, e.Host)
+		}
+		if e.Start.IsZero() || e.End.Before(e.Start) {
+			t.Errorf("%s: invalid times recorded: %s - %s", e.Command, e.Start, e.End)
+		}
+	}
+}
+
+const history = `
+build: {
+	echo build
+}
+broken: {
+	exit 3
+}
+clean: {
+	echo clean
+}
+`
+
+func TestStats(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(history))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	if err := mst.Stats(nil); err == nil {
+		t.Errorf("stats should fail when history is not enabled")
+	}
+	mst.MetaExec.History = filepath.Join(t.TempDir(), "runs.json")
+
+	var (
+		when = time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC)
+		runs = []maestro.HistoryEntry{
+			{Command: "build", Start: when, End: when.Add(time.Second)},
+			{Command: "broken", Start: when, End: when.Add(500 * time.Millisecond), Code: 3, Error: "exit: 3"},
+			{Command: "build", Start: when.Add(time.Hour), End: when.Add(time.Hour + 2*time.Second), Code: 1, Error: "failed"},
+			{Command: "build", Start: when.Add(2 * time.Hour), End: when.Add(2*time.Hour + 3*time.Second)},
+		}
+		buf bytes.Buffer
+		enc = json.NewEncoder(&buf)
+	)
+	for _, e := range runs {
+		if err := enc.Encode(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(mst.MetaExec.History, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	mst.Stdout = &buf
+
+	tests := []struct {
+		Args []string
+		Want []string
+	}{
+		{
+			Want: []string{
+				"build                    3 runs  33.33% failed avg 2s           last 2024-05-02 12:00:00",
+				"broken                   1 runs 100.00% failed avg 500ms        last 2024-05-02 10:00:00",
+			},
+		},
+		{
+			Args: []string{"build"},
+			Want: []string{
+				"build                    3 runs  33.33% failed avg 2s           last 2024-05-02 12:00:00",
+			},
+		},
+		{
+			Args: []string{"-u"},
+			Want: []string{"- clean"},
+		},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		if err := mst.Stats(tt.Args); err != nil {
+			t.Errorf("%v: fail to compute stats: %s", tt.Args, err)
+			continue
+		}
+		got := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if strings.Join(got, "\n") != strings.Join(tt.Want, "\n") {
+			t.Errorf("%v: stats mismatched!\nwant:\n%s\ngot:\n%s", tt.Args, strings.Join(tt.Want, "\n"), strings.Join(got, "\n"))
+		}
+	}
+}
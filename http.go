@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"path"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -23,32 +25,115 @@ const (
 	httpHdrTrailer = "Trailer"
 )
 
-func setupRoutes(m *Maestro) {
-	http.Handle("/help", serveRequest(ServeHelp(m)))
-	http.Handle("/version", serveRequest(ServeVersion(m)))
-	http.Handle("/", serveRequest(ServeExecute(m)))
+func setupRoutes(m *Maestro) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/help", serveRequest(ServeHelp(m)))
+	mux.Handle("/version", serveRequest(ServeVersion(m)))
+	mux.Handle("/healthz", serveRequest(ServeHealth(m)))
+	mux.Handle("/readyz", serveRequest(ServeReady(m)))
+	mux.Handle("/commands/", serveRequest(ServeExecute(m)))
+	mux.Handle("/", serveRequest(ServeExecute(m)))
+	if m.MetaHttp.Base == "" || m.MetaHttp.Base == "/" {
+		return mux
+	}
+	return http.StripPrefix(strings.TrimRight(m.MetaHttp.Base, "/"), mux)
 }
 
 func ServeExecute(mst *Maestro) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
 		var (
-			name   = path.Base(r.URL.Path)
+			name   = commandName(r.URL.Path)
 			option = getOption(r)
+			args   = r.URL.Query()["arg"]
 		)
 		if name == "" {
 			name = mst.MetaExec.Default
 		}
+		var (
+			start     = time.Now()
+			remote    = requestRemote(r, mst.MetaHttp.Proxies)
+			principal string
+			audit     = func(err error) {
+				mst.audit(createEntryAudit(auditHttp, remote, principal, name, args, start, err))
+			}
+		)
+		if cmd, err := mst.Commands.Lookup(name); err == nil {
+			secret, err := mst.webhookSecret(cmd)
+			if err == nil {
+				err = verifyWebhook(r, secret)
+			}
+			if err == nil && secret != "" {
+				principal = webhookPrincipal(r)
+			}
+			if err == nil && secret == "" {
+				var who string
+				if who, err = mst.authorize(r, cmd); who != "" {
+					principal = who
+				}
+			}
+			if err != nil {
+				code := http.StatusInternalServerError
+				switch {
+				case errors.Is(err, errUnauthorized), errors.Is(err, errUnauthenticated):
+					code = http.StatusUnauthorized
+				case errors.Is(err, errForbidden):
+					code = http.StatusForbidden
+				}
+				audit(err)
+				w.WriteHeader(code)
+				io.WriteString(w, err.Error())
+				return
+			}
+			body, err := io.ReadAll(r.Body)
+			if err == nil {
+				var list []string
+				if list, err = cmd.mapPayload(body); err == nil {
+					args = append(list, args...)
+				}
+			}
+			if err != nil {
+				audit(err)
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, err.Error())
+				return
+			}
+			if !mst.limiter.allow(cmd, time.Now()) {
+				err := fmt.Errorf("%s: rate limit exceeded (%d per minute)", name, cmd.RateLimit)
+				audit(err)
+				w.WriteHeader(http.StatusTooManyRequests)
+				io.WriteString(w, err.Error())
+				return
+			}
+			if cmd.Debounce > 0 {
+				mst.debounceCommand(serverContext(r), cmd, args, option, remote, principal)
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+		}
 		w.Header().Set(httpHdrTrailer, httpHdrExit)
 		var (
-			err  = executeCommand(r.Context(), w, name, option, mst)
+			out  = flushWriter{w: w}
+			err  = executeCommand(r.Context(), &out, name, args, option, mst)
 			code int
 		)
+		audit(err)
+		var usage UsageError
 		switch {
-		case errors.Is(err, errNotFound):
+		case err == nil:
+		case errors.As(err, &usage):
 			code = http.StatusBadRequest
-		case errors.Is(err, errResolve):
+		case errors.Is(err, errNotFound):
+			code = http.StatusNotFound
+		case errors.Is(err, errForbidden):
+			code = http.StatusForbidden
+		case errors.Is(err, errBusy):
+			code = http.StatusTooManyRequests
+		case !out.Written():
 			code = http.StatusInternalServerError
-		default:
 		}
 		if code >= http.StatusBadRequest {
 			w.WriteHeader(code)
@@ -64,8 +149,69 @@ func ServeExecute(mst *Maestro) http.Handler {
 	return http.HandlerFunc(fn)
 }
 
+func (m *Maestro) debounceCommand(ctx context.Context, cmd CommandSettings, args []string, option ctreeOption, remote, principal string) {
+	name := cmd.Command()
+	m.limiter.debounce(cmd, args, func(args []string) {
+		var (
+			now = time.Now()
+			err = executeCommand(ctx, m.Stdout, name, args, option, m)
+		)
+		m.audit(createEntryAudit(auditHttp, remote, principal, name, args, now, err))
+		if err != nil {
+			fmt.Fprintf(m.Stderr, "%s: %s", name, err)
+			fmt.Fprintln(m.Stderr)
+		}
+	})
+}
+
+type serverKey struct{}
+
+func serverContext(r *http.Request) context.Context {
+	if ctx, ok := r.Context().Value(serverKey{}).(context.Context); ok {
+		return ctx
+	}
+	return context.Background()
+}
+
+func commandName(str string) string {
+	str = strings.Trim(str, "/")
+	if str == "commands" {
+		return ""
+	}
+	return strings.TrimPrefix(str, "commands/")
+}
+
+type flushWriter struct {
+	w       http.ResponseWriter
+	mu      sync.Mutex
+	written bool
+}
+
+func (f *flushWriter) Write(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.written = true
+	n, err := f.w.Write(b)
+	if x, ok := f.w.(http.Flusher); ok {
+		x.Flush()
+	}
+	return n, err
+}
+
+func (f *flushWriter) Written() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.written
+}
+
 func ServeHelp(mst *Maestro) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
+		if _, err := mst.authenticate(r); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, err.Error())
+			return
+		}
 		q := r.URL.Query()
 		mst.executeHelp(q.Get("command"), w)
 	}
@@ -102,25 +248,30 @@ func parseBool(str string) bool {
 }
 
 var (
-	errNotFound = errors.New("command not found")
-	errResolve  = errors.New("fail to resolve dependencies")
-	errExecute  = errors.New("execution fail")
+	errNotFound  = errors.New("command not found")
+	errForbidden = errors.New("command not allowed")
+	errResolve   = errors.New("fail to resolve dependencies")
+	errExecute   = errors.New("execution fail")
 )
 
-func executeCommand(ctx context.Context, w io.Writer, name string, option ctreeOption, mst *Maestro) error {
+func executeCommand(ctx context.Context, w io.Writer, name string, args []string, option ctreeOption, mst *Maestro) error {
+	if err := mst.checkBudget(name, option); err != nil {
+		return err
+	}
 	x, err := mst.setup(ctx, name, true)
 	if err != nil {
 		return err
 	}
-	ex, err := mst.resolve(x, nil, option)
+	ex, err := mst.resolve(x, args, option)
 	if err != nil {
-		return errResolve
+		return fmt.Errorf("%w: %s", errResolve, err)
 	}
 	if c, ok := ex.(io.Closer); ok {
 		defer c.Close()
 	}
 	err = ex.Execute(ctx, w, w)
-	if err != nil {
+	var usage UsageError
+	if err != nil && !errors.Is(err, errBusy) && !errors.Is(err, errForbidden) && !errors.As(err, &usage) {
 		err = fmt.Errorf("%w %s: %s", errExecute, name, err)
 	}
 	return err
@@ -0,0 +1,385 @@
+package maestro_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/midbel/maestro"
+)
+
+func TestServeExecute(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(served))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	tests := []struct {
+		Method string
+		Path   string
+		Code   int
+	}{
+		{Method: http.MethodGet, Path: "/commands/unknown", Code: http.StatusNotFound},
+		{Method: http.MethodGet, Path: "/commands/hidden", Code: http.StatusForbidden},
+		{Method: http.MethodDelete, Path: "/commands/echo", Code: http.StatusMethodNotAllowed},
+		{Method: http.MethodGet, Path: "/commands/echo?arg=foo", Code: http.StatusOK},
+	}
+	h := maestro.ServeExecute(mst)
+	for _, tt := range tests {
+		var (
+			req = httptest.NewRequest(tt.Method, tt.Path, nil)
+			rec = httptest.NewRecorder()
+		)
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.Code {
+			t.Errorf("%s %s: status mismatched! want %d, got %d", tt.Method, tt.Path, tt.Code, rec.Code)
+		}
+	}
+}
+
+const served = `
+echo: {
+	echo $@
+}
+
+%hidden: {
+	echo hidden
+}
+`
+
+func TestServeThrottle(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(throttled))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	tests := []struct {
+		Path string
+		Code int
+	}{
+		{Path: "/commands/limited", Code: http.StatusOK},
+		{Path: "/commands/limited", Code: http.StatusTooManyRequests},
+		{Path: "/commands/debounced", Code: http.StatusAccepted},
+		{Path: "/commands/debounced", Code: http.StatusAccepted},
+	}
+	h := maestro.ServeExecute(mst)
+	for _, tt := range tests {
+		var (
+			req = httptest.NewRequest(http.MethodGet, tt.Path, nil)
+			rec = httptest.NewRecorder()
+		)
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.Code {
+			t.Errorf("%s: status mismatched! want %d, got %d", tt.Path, tt.Code, rec.Code)
+		}
+	}
+}
+
+const throttled = `
+limited(rate_limit = 1): {
+	echo limited
+}
+
+debounced(debounce = "1h"): {
+	echo debounced
+}
+`
+
+func TestServeWebhook(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(webhook))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	const body = `{"ref": "refs/heads/main"}`
+	mac := hmac.New(sha256.New, []byte("s3cr3t"))
+	mac.Write([]byte(body))
+
+	tests := []struct {
+		Header string
+		Value  string
+		Code   int
+	}{
+		{Code: http.StatusUnauthorized},
+		{Header: "X-Hub-Signature-256", Value: "sha256=" + hex.EncodeToString(mac.Sum(nil)), Code: http.StatusOK},
+		{Header: "X-Hub-Signature-256", Value: "sha256=00ff", Code: http.StatusUnauthorized},
+		{Header: "X-Gitlab-Token", Value: "s3cr3t", Code: http.StatusOK},
+		{Header: "X-Gitlab-Token", Value: "secret", Code: http.StatusUnauthorized},
+	}
+	h := maestro.ServeExecute(mst)
+	for _, tt := range tests {
+		var (
+			req = httptest.NewRequest(http.MethodPost, "/commands/deploy", strings.NewReader(body))
+			rec = httptest.NewRecorder()
+		)
+		if tt.Header != "" {
+			req.Header.Set(tt.Header, tt.Value)
+		}
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.Code {
+			t.Errorf("%s: status mismatched! want %d, got %d", tt.Header, tt.Code, rec.Code)
+		}
+	}
+}
+
+const webhook = `
+.HTTP_WEBHOOK_SECRET = "s3cr3t"
+
+deploy: {
+	echo deploy
+}
+`
+
+func TestServeHttpMap(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(httpMapped))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	tests := []struct {
+		Body string
+		Code int
+		Want string
+	}{
+		{Body: `{"env": "prod", "ref": "--force"}`, Code: http.StatusOK, Want: "false prod --force"},
+		{Body: `{"ref": "main"}`, Code: http.StatusBadRequest},
+	}
+	h := maestro.ServeExecute(mst)
+	for _, tt := range tests {
+		var (
+			req = httptest.NewRequest(http.MethodPost, "/commands/deploy", strings.NewReader(tt.Body))
+			rec = httptest.NewRecorder()
+		)
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.Code {
+			t.Errorf("%s: status mismatched! want %d, got %d", tt.Body, tt.Code, rec.Code)
+		}
+		if got := rec.Body.String(); tt.Want != "" && !strings.Contains(got, tt.Want) {
+			t.Errorf("%s: output mismatched! want %q, got %q", tt.Body, tt.Want, got)
+		}
+	}
+}
+
+const httpMapped = `
+deploy(
+	options  = (
+		long = force,
+		flag = true,
+	),
+	args     = env target,
+	http_map = (
+		env    = $.env,
+		target = $.ref,
+	),
+): {
+	echo $force $env $target
+}
+`
+
+func TestServeReady(t *testing.T) {
+	tests := []struct {
+		Input string
+		Code  int
+	}{
+		{Input: served, Code: http.StatusOK},
+		{Input: remote, Code: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		mst, err := maestro.Decode(strings.NewReader(tt.Input))
+		if err != nil {
+			t.Fatalf("fail to decode: %s", err)
+		}
+		var (
+			req = httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			rec = httptest.NewRecorder()
+		)
+		maestro.ServeReady(mst).ServeHTTP(rec, req)
+		if rec.Code != tt.Code {
+			t.Errorf("status mismatched! want %d, got %d (%s)", tt.Code, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+const remote = `
+deploy(hosts = "10.0.0.1:22"): {
+	echo deploy
+}
+`
+
+func TestServeAudit(t *testing.T) {
+	tests := []struct {
+		Proxies string
+		Remote  string
+	}{
+		{Remote: "192.0.2.1"},
+		{Proxies: "10.0.0.0/8", Remote: "192.0.2.1"},
+		{Proxies: "192.0.2.1", Remote: "10.0.0.5"},
+		{Proxies: "192.0.2.0/24, 10.0.0.5", Remote: "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		file := filepath.Join(t.TempDir(), "audit.log")
+		mst, err := maestro.Decode(strings.NewReader(fmt.Sprintf(audited, file)))
+		if err != nil {
+			t.Fatalf("fail to decode: %s", err)
+		}
+		if tt.Proxies != "" {
+			mst.MetaHttp.Proxies = strings.Split(tt.Proxies, ", ")
+		}
+		var (
+			req = httptest.NewRequest(http.MethodGet, "/commands/echo?arg=foo", nil)
+			rec = httptest.NewRecorder()
+		)
+		req.SetBasicAuth("admin", "admin")
+		req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.5")
+		req.Header.Set("X-Hub-Signature-256", "sha256=00")
+		maestro.ServeExecute(mst).ServeHTTP(rec, req)
+
+		buf, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("fail to read audit log: %s", err)
+		}
+		var e maestro.AuditEntry
+		if err := json.Unmarshal(buf, &e); err != nil {
+			t.Fatalf("fail to decode audit entry: %s", err)
+		}
+		if e.Source != "http" || e.Command != "echo" || e.Result != "ok" {
+			t.Errorf("unexpected audit entry: %+v", e)
+		}
+		if e.Principal != "" {
+			t.Errorf("unauthenticated principal recorded: %s", e.Principal)
+		}
+		if e.Remote != tt.Remote {
+			t.Errorf("remote mismatched with proxies %q! want %s, got %s", tt.Proxies, tt.Remote, e.Remote)
+		}
+		if len(e.Args) != 1 || e.Args[0] != "foo" {
+			t.Errorf("args mismatched! want [foo], got %s", e.Args)
+		}
+	}
+}
+
+func TestServeSandbox(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(jailedHttp))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	for _, path := range []string{"/commands/inner", "/commands/outer"} {
+		var (
+			req = httptest.NewRequest(http.MethodGet, path, nil)
+			rec = httptest.NewRecorder()
+		)
+		maestro.ServeExecute(mst).ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: status mismatched! want %d, got %d (%s)", path, http.StatusForbidden, rec.Code, rec.Body.String())
+		}
+		if strings.Contains(rec.Body.String(), "jailed") {
+			t.Errorf("%s: sandboxed command executed: %s", path, rec.Body.String())
+		}
+	}
+}
+
+const jailedHttp = `
+inner(sandbox = true): {
+	echo jailed
+}
+
+outer: inner {
+	echo free
+}
+`
+
+const audited = `
+.AUDIT_LOG = "%s"
+
+echo: {
+	echo $@
+}
+`
+
+func TestServeTokens(t *testing.T) {
+	t.Setenv("DEPLOY_TOKEN", "deploy")
+	mst, err := maestro.Decode(strings.NewReader(tokens))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	tests := []struct {
+		Path  string
+		Token string
+		Code  int
+	}{
+		{Path: "/commands/deploy_web", Code: http.StatusUnauthorized},
+		{Path: "/commands/deploy_web", Token: "unknown", Code: http.StatusUnauthorized},
+		{Path: "/commands/deploy_web", Token: "viewer", Code: http.StatusForbidden},
+		{Path: "/commands/build", Token: "deploy", Code: http.StatusForbidden},
+		{Path: "/commands/deploy_web", Token: "deploy", Code: http.StatusOK},
+		{Path: "/commands/build", Token: "builder", Code: http.StatusOK},
+	}
+	h := maestro.ServeExecute(mst)
+	for _, tt := range tests {
+		var (
+			req = httptest.NewRequest(http.MethodGet, tt.Path, nil)
+			rec = httptest.NewRecorder()
+		)
+		if tt.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.Token)
+		}
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.Code {
+			t.Errorf("%s (%s): status code mismatched! want %d, got %d", tt.Path, tt.Token, tt.Code, rec.Code)
+		}
+	}
+	var (
+		req = httptest.NewRequest(http.MethodGet, "/help", nil)
+		rec = httptest.NewRecorder()
+	)
+	req.Header.Set("Authorization", "Bearer viewer")
+	maestro.ServeHelp(mst).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("help: status code mismatched! want %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+const tokens = `
+.HTTP_TOKENS = (
+	name  = viewer,
+	token = viewer,
+	view  = true,
+), (
+	name     = deployer,
+	token    = "env:DEPLOY_TOKEN",
+	commands = "deploy_*",
+), (
+	name  = builder,
+	token = builder,
+	tags  = ci,
+)
+
+deploy_web: {
+	echo deploy
+}
+
+build(tag = ci): {
+	echo build
+}
+`
+
+func TestServeUsage(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(terminated))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	var (
+		req = httptest.NewRequest(http.MethodGet, "/commands/echo?arg=-z", nil)
+		rec = httptest.NewRecorder()
+	)
+	maestro.ServeExecute(mst).ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status code mismatched! want %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "flag provided but not defined: -z") {
+		t.Errorf("unexpected error message: %s", body)
+	}
+}
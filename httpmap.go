@@ -0,0 +1,148 @@
+package maestro
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var errPayload = errors.New("invalid payload")
+
+type HttpField struct {
+	Name string
+	Path string
+}
+
+func (s CommandSettings) mapPayload(body []byte) ([]string, error) {
+	if len(s.HttpMap) == 0 || len(body) == 0 {
+		return nil, nil
+	}
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %s", errPayload, err)
+	}
+	var (
+		opts []string
+		pos  = make([]string, len(s.Args))
+		set  = make([]bool, len(s.Args))
+		rest int
+	)
+	for _, f := range s.HttpMap {
+		v, err := jsonPath(doc, f.Path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %s", errPayload, f.Name, err)
+		}
+		if v == nil {
+			continue
+		}
+		str, err := jsonString(v)
+		if err != nil {
+			return nil, err
+		}
+		if i := s.argIndex(f.Name); i >= 0 {
+			pos[i], set[i] = str, true
+			if i >= rest {
+				rest = i + 1
+			}
+			continue
+		}
+		opt, ok := s.option(f.Name)
+		if ok && opt.Flag {
+			if b, _ := strconv.ParseBool(str); b {
+				opts = append(opts, optionName(f.Name))
+			}
+			continue
+		}
+		opts = append(opts, optionName(f.Name), str)
+	}
+	if rest == 0 {
+		return opts, nil
+	}
+	for i := 0; i < rest; i++ {
+		if !set[i] {
+			return nil, fmt.Errorf("%w: %s: argument missing", errPayload, s.Args[i].Name)
+		}
+	}
+	opts = append(opts, "--")
+	return append(opts, pos[:rest]...), nil
+}
+
+func (s CommandSettings) argIndex(name string) int {
+	for i, a := range s.Args {
+		if a.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s CommandSettings) option(name string) (CommandOption, bool) {
+	for _, o := range s.Options {
+		if o.Short == name || o.Long == name {
+			return o, true
+		}
+	}
+	return CommandOption{}, false
+}
+
+func optionName(name string) string {
+	if len(name) == 1 {
+		return "-" + name
+	}
+	return "--" + name
+}
+
+func jsonString(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		buf, err := json.Marshal(v)
+		return string(buf), err
+	}
+}
+
+func jsonPath(doc interface{}, path string) (interface{}, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("%s: path should start with $", path)
+	}
+	path = path[1:]
+	for path != "" {
+		switch path[0] {
+		case '.':
+			path = path[1:]
+			n := strings.IndexAny(path, ".[")
+			if n < 0 {
+				n = len(path)
+			}
+			obj, ok := doc.(map[string]interface{})
+			if !ok {
+				return nil, nil
+			}
+			doc, path = obj[path[:n]], path[n:]
+		case '[':
+			n := strings.IndexByte(path, ']')
+			if n < 0 {
+				return nil, fmt.Errorf("%s: missing ]", path)
+			}
+			i, err := strconv.Atoi(path[1:n])
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid index", path[1:n])
+			}
+			arr, ok := doc.([]interface{})
+			if !ok || i < 0 || i >= len(arr) {
+				return nil, nil
+			}
+			doc, path = arr[i], path[n+1:]
+		default:
+			return nil, fmt.Errorf("%s: unexpected character in path", path)
+		}
+	}
+	return doc, nil
+}
@@ -3,6 +3,8 @@ package env
 import (
 	"fmt"
 	"strings"
+
+	"github.com/midbel/maestro/internal/copyslice"
 )
 
 type Values map[string][]string
@@ -46,6 +48,18 @@ func (e *Env) Delete(key string) error {
 	return nil
 }
 
+func (e *Env) Has(key string) bool {
+	_, ok := e.locals[key]
+	return ok
+}
+
+func (e *Env) Defined(key string) bool {
+	if e.Has(key) {
+		return true
+	}
+	return e.parent != nil && e.parent.Defined(key)
+}
+
 func (e *Env) Resolve(key string) ([]string, error) {
 	vs, ok := e.locals[key]
 	if !ok && e.parent != nil {
@@ -71,14 +85,10 @@ func (e *Env) Copy() *Env {
 	return &x
 }
 
-func (e *Env) register(ident string, v Values) {
-
-}
-
 func copyLocals(locals Values) Values {
 	others := make(Values)
 	for k, vs := range locals {
-		others[k] = append(others[k], vs...)
+		others[k] = copyslice.Copy(vs)
 	}
 	return others
 }
@@ -29,4 +29,7 @@ func TestEnv(t *testing.T) {
 	if len(values) != 0 {
 		t.Fatalf("empty values expected! got %v", values)
 	}
+	if !e.Has("foobar") || e.Has("foo") {
+		t.Fatalf("only local variables should be reported")
+	}
 }
@@ -17,7 +17,10 @@ Available commands:
 {{$k}}:
 {{repeat "-" $k}}-
 {{- range $cs}}
-  - {{printf "%-20s %s" .Name .Short -}}
+  - {{printf "%-20s %s" .Command .Short -}}
+{{range $n, $p := .Presets}}
+      {{printf "%-18s %s" $n (join $p " ") -}}
+{{end}}
 {{end -}}
 {{end}}
 
@@ -33,12 +36,14 @@ const cmdhelp = `
 {{- with .Options}}
 Options:
 {{range . }}
-  {{if .Short}}-{{.Short}}{{end}}{{if and .Long .Short}}, {{end}}{{if .Long}}--{{.Long}}{{end}}{{if .Help}}  {{.Help}}{{end}}
+  {{if .Short}}-{{.Short}}{{end}}{{if and .Long .Short}}, {{end}}{{if .Long}}--{{.Long}}{{end}}{{if .Help}}  {{.Help}}{{end}}{{if .Exclusive}} (exclusive: {{.Exclusive}}){{end}}
 {{- end}}
 {{end}}
 usage: {{.Usage}}
 {{if .Alias}}alias: {{join .Alias ", "}}
 {{end -}}
+{{range $n, $p := .Presets}}  {{$n}}: {{$.Command}} {{join $p " "}}
+{{end -}}
 {{if .Tags}}tags:  {{join .Tags ", "}}
 {{end -}}
 `
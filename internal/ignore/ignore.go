@@ -0,0 +1,187 @@
+package ignore
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const DefaultFile = ".maestroignore"
+
+type pattern struct {
+	segments []string
+	negate   bool
+	dir      bool
+	anchored bool
+}
+
+func parsePattern(line string) (pattern, bool) {
+	var pat pattern
+	line = strings.TrimRight(line, " \t")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pat, false
+	}
+	if strings.HasPrefix(line, "!") {
+		pat.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		pat.dir = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return pat, false
+	}
+	pat.anchored = strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	pat.segments = strings.Split(line, "/")
+	return pat, true
+}
+
+func (p pattern) match(parts []string) bool {
+	if !p.anchored {
+		ok, _ := path.Match(p.segments[0], parts[len(parts)-1])
+		return ok
+	}
+	return matchSegments(p.segments, parts)
+}
+
+type Matcher struct {
+	patterns []pattern
+}
+
+func Parse(r io.Reader) (*Matcher, error) {
+	var (
+		m    Matcher
+		scan = bufio.NewScanner(r)
+	)
+	for scan.Scan() {
+		if p, ok := parsePattern(scan.Text()); ok {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+	return &m, scan.Err()
+}
+
+func Load(dir string, files ...string) (*Matcher, error) {
+	var all Matcher
+	for _, f := range files {
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(dir, f)
+		}
+		r, err := os.Open(f)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		m, err := Parse(r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		all.patterns = append(all.patterns, m.patterns...)
+	}
+	return &all, nil
+}
+
+func (m *Matcher) Match(file string, dir bool) bool {
+	if m == nil || len(m.patterns) == 0 {
+		return false
+	}
+	parts := split(file)
+	if len(parts) == 0 {
+		return false
+	}
+	for i := 1; i < len(parts); i++ {
+		if m.match(parts[:i], true) {
+			return true
+		}
+	}
+	return m.match(parts, dir)
+}
+
+func (m *Matcher) match(parts []string, dir bool) bool {
+	var ignored bool
+	for _, p := range m.patterns {
+		if p.dir && !dir {
+			continue
+		}
+		if p.match(parts) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+func (m *Matcher) Glob(root string, patterns ...string) ([]string, error) {
+	var list []string
+	err := filepath.WalkDir(root, func(file string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil || rel == "." {
+			return err
+		}
+		if m.Match(rel, e.IsDir()) {
+			if e.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if e.IsDir() {
+			return nil
+		}
+		parts := split(rel)
+		for _, p := range patterns {
+			if matchSegments(split(p), parts) {
+				list = append(list, file)
+				break
+			}
+		}
+		return nil
+	})
+	return list, err
+}
+
+func matchSegments(pat, parts []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			pat = pat[1:]
+			if len(pat) == 0 {
+				return len(parts) > 0
+			}
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pat, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], parts[0]); !ok {
+			return false
+		}
+		pat, parts = pat[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+func split(file string) []string {
+	file = strings.Trim(filepath.ToSlash(file), "/")
+	if file == "" || file == "." {
+		return nil
+	}
+	return strings.Split(file, "/")
+}
@@ -0,0 +1,48 @@
+package ignore_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/midbel/maestro/internal/ignore"
+)
+
+const patterns = `
+# a comment
+node_modules/
+*.log
+!keep.log
+/build
+docs/**/*.tmp
+`
+
+func TestMatcher(t *testing.T) {
+	m, err := ignore.Parse(strings.NewReader(patterns))
+	if err != nil {
+		t.Fatalf("fail to parse patterns: %s", err)
+	}
+	data := []struct {
+		File    string
+		Dir     bool
+		Ignored bool
+	}{
+		{File: "node_modules", Dir: true, Ignored: true},
+		{File: "web/node_modules/pkg/index.js", Ignored: true},
+		{File: "node_modules", Dir: false, Ignored: false},
+		{File: "debug.log", Ignored: true},
+		{File: "logs/debug.log", Ignored: true},
+		{File: "keep.log", Ignored: false},
+		{File: "build", Dir: true, Ignored: true},
+		{File: "build/maestro", Ignored: true},
+		{File: "cmd/build", Dir: true, Ignored: false},
+		{File: "docs/a/b/file.tmp", Ignored: true},
+		{File: "docs/file.tmp", Ignored: true},
+		{File: "main.go", Ignored: false},
+	}
+	for _, d := range data {
+		got := m.Match(d.File, d.Dir)
+		if got != d.Ignored {
+			t.Errorf("%s: ignored mismatched! want %t, got %t", d.File, d.Ignored, got)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package ordered
+
+type Map[K comparable, V any] struct {
+	keys   []K
+	values map[K]V
+}
+
+func New[K comparable, V any]() *Map[K, V] {
+	return &Map[K, V]{
+		values: make(map[K]V),
+	}
+}
+
+func (m *Map[K, V]) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.keys)
+}
+
+func (m *Map[K, V]) Set(key K, value V) {
+	if m.values == nil {
+		m.values = make(map[K]V)
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *Map[K, V]) Get(key K) (V, bool) {
+	var (
+		v  V
+		ok bool
+	)
+	if m != nil {
+		v, ok = m.values[key]
+	}
+	return v, ok
+}
+
+func (m *Map[K, V]) Has(key K) bool {
+	_, ok := m.Get(key)
+	return ok
+}
+
+func (m *Map[K, V]) Delete(key K) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	for i := range m.keys {
+		if m.keys[i] == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+func (m *Map[K, V]) Keys() []K {
+	if m == nil {
+		return nil
+	}
+	ks := make([]K, len(m.keys))
+	copy(ks, m.keys)
+	return ks
+}
+
+func (m *Map[K, V]) Values() []V {
+	if m == nil {
+		return nil
+	}
+	vs := make([]V, 0, len(m.keys))
+	for _, k := range m.keys {
+		vs = append(vs, m.values[k])
+	}
+	return vs
+}
+
+func (m *Map[K, V]) Copy() *Map[K, V] {
+	x := New[K, V]()
+	if m == nil {
+		return x
+	}
+	for _, k := range m.keys {
+		x.Set(k, m.values[k])
+	}
+	return x
+}
+
+// Map returns a copy of the values in a plain map. The order of the keys is lost:
+// use Keys or Values when it matters.
+func (m *Map[K, V]) Map() map[K]V {
+	x := make(map[K]V)
+	if m == nil {
+		return x
+	}
+	for k, v := range m.values {
+		x[k] = v
+	}
+	return x
+}
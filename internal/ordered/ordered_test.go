@@ -0,0 +1,48 @@
+package ordered_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/midbel/maestro/internal/ordered"
+)
+
+func TestMap(t *testing.T) {
+	m := ordered.New[string, int]()
+	m.Set("foo", 1)
+	m.Set("bar", 2)
+	m.Set("qux", 3)
+	m.Set("foo", 4)
+
+	if got := strings.Join(m.Keys(), ","); got != "foo,bar,qux" {
+		t.Fatalf("keys mismatched! got %s", got)
+	}
+	if v, _ := m.Get("foo"); v != 4 {
+		t.Fatalf("value mismatched! want 4, got %d", v)
+	}
+	c := m.Copy()
+	m.Delete("bar")
+	if got := strings.Join(m.Keys(), ","); got != "foo,qux" {
+		t.Fatalf("keys mismatched after delete! got %s", got)
+	}
+	if c.Len() != 3 || !c.Has("bar") {
+		t.Fatalf("copy should not be modified! got %v", c.Keys())
+	}
+}
+
+func TestMapZero(t *testing.T) {
+	var m ordered.Map[string, int]
+	m.Set("foo", 1)
+	m.Set("bar", 2)
+	m.Delete("qux")
+
+	if got := strings.Join(m.Keys(), ","); got != "foo,bar" {
+		t.Fatalf("keys mismatched! got %s", got)
+	}
+	if v, ok := m.Get("bar"); !ok || v != 2 {
+		t.Fatalf("value mismatched! want 2, got %d", v)
+	}
+	if x := m.Map(); len(x) != 2 || x["foo"] != 1 {
+		t.Fatalf("map mismatched! got %v", x)
+	}
+}
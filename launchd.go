@@ -0,0 +1,214 @@
+package maestro
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/midbel/maestro/schedule"
+)
+
+const launchdPrefix = "maestro."
+
+type launchAgent struct {
+	Label    string
+	Args     []string
+	Dir      string
+	Interval time.Duration
+	Calendar []launchDate
+	Stdout   string
+	Stderr   string
+}
+
+type launchDate struct {
+	Month   int
+	Day     int
+	Weekday int
+	Hour    int
+	Minute  int
+}
+
+func (m *Maestro) launchd(names []string, program string) ([]launchAgent, error) {
+	jobs, err := m.scheduledJobs(names)
+	if err != nil {
+		return nil, err
+	}
+	var agents []launchAgent
+	for _, j := range jobs {
+		a := launchAgent{
+			Label: launchdPrefix + j.Name,
+			Args:  append([]string{program}, j.Args...),
+			Dir:   j.Dir,
+		}
+		if f := j.Schedule.Stdout.File; f != "" {
+			a.Stdout = launchdPath(j.Dir, f)
+		}
+		if f := j.Schedule.Stderr.File; f != "" {
+			a.Stderr = launchdPath(j.Dir, f)
+		}
+		spec := j.Schedule.Sched.Spec()
+		if spec.Every > 0 {
+			if spec.Every < time.Second {
+				return nil, fmt.Errorf("%s: launchd can not start a job more than once per second", j.Command.Command())
+			}
+			a.Interval = spec.Every
+		} else {
+			a.Calendar, err = launchDates(spec)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", j.Command.Command(), err)
+			}
+		}
+		agents = append(agents, a)
+	}
+	return agents, nil
+}
+
+func launchDates(spec schedule.Spec) ([]launchDate, error) {
+	if spec.Reboot {
+		return nil, fmt.Errorf("launchd can not start a job when maestro schedule starts (@reboot)")
+	}
+	if len(spec.Seconds) != 1 || spec.Seconds[0] != 0 {
+		return nil, fmt.Errorf("launchd can not start a job at a given second")
+	}
+	var weekdays []int
+	for _, w := range spec.Weekdays {
+		weekdays = append(weekdays, int(w))
+	}
+	var list []launchDate
+	if spec.Days == nil || weekdays == nil {
+		list = launchProduct(spec.Months, spec.Days, weekdays, spec.Hours, spec.Minutes)
+	} else {
+		list = launchProduct(spec.Months, spec.Days, nil, spec.Hours, spec.Minutes)
+		list = append(list, launchProduct(spec.Months, nil, weekdays, spec.Hours, spec.Minutes)...)
+	}
+	return list, nil
+}
+
+func launchProduct(months, days, weekdays, hours, minutes []int) []launchDate {
+	each := func(vs []int) []int {
+		if vs == nil {
+			return []int{-1}
+		}
+		return vs
+	}
+	var list []launchDate
+	for _, mo := range each(months) {
+		for _, d := range each(days) {
+			for _, w := range each(weekdays) {
+				for _, h := range each(hours) {
+					for _, mi := range each(minutes) {
+						list = append(list, launchDate{
+							Month:   mo,
+							Day:     d,
+							Weekday: w,
+							Hour:    h,
+							Minute:  mi,
+						})
+					}
+				}
+			}
+		}
+	}
+	return list
+}
+
+func launchdProgram() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return "maestro"
+	}
+	return exe
+}
+
+func launchdPath(dir, file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(dir, file)
+}
+
+func writeLaunchdDir(dir string, agents []launchAgent) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, a := range agents {
+		f, err := os.Create(filepath.Join(dir, a.Label+".plist"))
+		if err != nil {
+			return err
+		}
+		writePlist(f, a)
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writePlist(w io.Writer, a launchAgent) {
+	fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(w, `<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">`)
+	fmt.Fprintln(w, `<plist version="1.0">`)
+	fmt.Fprintln(w, "<dict>")
+	writePlistString(w, "Label", a.Label)
+	fmt.Fprintln(w, "  <key>ProgramArguments</key>")
+	fmt.Fprintln(w, "  <array>")
+	for _, s := range a.Args {
+		fmt.Fprintf(w, "    <string>%s</string>", xmlText(s))
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "  </array>")
+	writePlistString(w, "WorkingDirectory", a.Dir)
+	if a.Interval > 0 {
+		fmt.Fprintln(w, "  <key>StartInterval</key>")
+		fmt.Fprintf(w, "  <integer>%d</integer>", int(a.Interval.Seconds()))
+		fmt.Fprintln(w)
+	} else {
+		fmt.Fprintln(w, "  <key>StartCalendarInterval</key>")
+		fmt.Fprintln(w, "  <array>")
+		for _, d := range a.Calendar {
+			writePlistDate(w, d)
+		}
+		fmt.Fprintln(w, "  </array>")
+	}
+	if a.Stdout != "" {
+		writePlistString(w, "StandardOutPath", a.Stdout)
+	}
+	if a.Stderr != "" {
+		writePlistString(w, "StandardErrorPath", a.Stderr)
+	}
+	fmt.Fprintln(w, "</dict>")
+	fmt.Fprintln(w, "</plist>")
+}
+
+func writePlistString(w io.Writer, key, value string) {
+	fmt.Fprintf(w, "  <key>%s</key>", key)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "  <string>%s</string>", xmlText(value))
+	fmt.Fprintln(w)
+}
+
+func writePlistDate(w io.Writer, d launchDate) {
+	fields := []struct {
+		Key   string
+		Value int
+	}{
+		{Key: "Month", Value: d.Month},
+		{Key: "Day", Value: d.Day},
+		{Key: "Weekday", Value: d.Weekday},
+		{Key: "Hour", Value: d.Hour},
+		{Key: "Minute", Value: d.Minute},
+	}
+	fmt.Fprintln(w, "    <dict>")
+	for _, f := range fields {
+		if f.Value < 0 {
+			continue
+		}
+		fmt.Fprintf(w, "      <key>%s</key>", f.Key)
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "      <integer>%d</integer>", f.Value)
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "    </dict>")
+}
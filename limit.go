@@ -0,0 +1,81 @@
+package maestro
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	PolicyQueue  = "queue"
+	PolicyReject = "reject"
+)
+
+var errBusy = errors.New("too many running instances")
+
+type limitSet struct {
+	mu   sync.Mutex
+	sems map[string]chan struct{}
+}
+
+func (s *limitSet) acquire(ctx context.Context, cmd CommandSettings) (func(), error) {
+	if s == nil || cmd.MaxConcurrent <= 0 {
+		return func() {}, nil
+	}
+	sem := s.get(cmd)
+	if cmd.Policy == PolicyReject {
+		select {
+		case sem <- struct{}{}:
+		default:
+			return nil, fmt.Errorf("%s: %w (max %d)", cmd.Command(), errBusy, cmd.MaxConcurrent)
+		}
+	} else {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return func() { <-sem }, nil
+}
+
+func (s *limitSet) get(cmd CommandSettings) chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sems == nil {
+		s.sems = make(map[string]chan struct{})
+	}
+	sem, ok := s.sems[cmd.Command()]
+	if !ok {
+		sem = make(chan struct{}, cmd.MaxConcurrent)
+		s.sems[cmd.Command()] = sem
+	}
+	return sem
+}
+
+type limited struct {
+	Executer
+	cmd    CommandSettings
+	limits *limitSet
+}
+
+func limitExecuter(cmd CommandSettings, ex Executer, limits *limitSet) Executer {
+	if limits == nil || cmd.MaxConcurrent <= 0 {
+		return ex
+	}
+	return &limited{
+		Executer: ex,
+		cmd:      cmd,
+		limits:   limits,
+	}
+}
+
+func (i *limited) Execute(ctx context.Context, args []string) error {
+	release, err := i.limits.acquire(ctx, i.cmd)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return i.Executer.Execute(ctx, args)
+}
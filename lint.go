@@ -0,0 +1,231 @@
+package maestro
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+func (m *Maestro) Validate() error {
+	return m.Commands.CheckCycles()
+}
+
+func (m *Maestro) Lint(args []string) error {
+	set := flag.NewFlagSet(CmdLint, flag.ContinueOnError)
+	if err := parseFlags(set, args); err != nil {
+		return err
+	}
+	problems := m.lint()
+	for _, p := range problems {
+		fmt.Fprintf(m.Stdout, "%s: %s", m.MetaAbout.File, p)
+		fmt.Fprintln(m.Stdout)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problem(s) found", len(problems))
+	}
+	return nil
+}
+
+func (m *Maestro) lint() []error {
+	var list []error
+	if err := m.Validate(); err != nil {
+		list = append(list, err)
+	}
+	for _, c := range m.Commands.Values() {
+		for _, d := range c.Deps {
+			if _, err := m.Commands.Lookup(d.Key()); err != nil && !d.Optional {
+				list = append(list, fmt.Errorf("%s: dependency %s not found", c.Command(), d.Key()))
+			}
+		}
+	}
+	metas := []struct {
+		Name  string
+		Names []string
+	}{
+		{Name: metaAll, Names: m.MetaExec.All},
+		{Name: metaDefault, Names: []string{m.MetaExec.Default}},
+		{Name: metaBefore, Names: m.MetaExec.Before},
+		{Name: metaAfter, Names: m.MetaExec.After},
+		{Name: metaError, Names: m.MetaExec.Error},
+		{Name: metaSuccess, Names: m.MetaExec.Success},
+	}
+	for _, meta := range metas {
+		for _, n := range meta.Names {
+			if n == "" {
+				continue
+			}
+			if _, err := m.Commands.Lookup(n); errors.Is(err, errNotFound) {
+				list = append(list, fmt.Errorf(".%s: command %s not found", meta.Name, n))
+			}
+		}
+	}
+	for _, c := range m.Commands.Values() {
+		list = append(list, lintScript(c)...)
+	}
+	return list
+}
+
+var shellSpecials = map[string]struct{}{
+	"HOME":    {},
+	"SECONDS": {},
+	"PWD":     {},
+	"OLDPWD":  {},
+	"PID":     {},
+	"PPID":    {},
+	"RANDOM":  {},
+	"SHELL":   {},
+}
+
+// hostSpecials are the variables expected in the environment of any host.
+// The others inherited from the environment of maestro are not known by the
+// linter, so that its result does not depend on the machine running it.
+var hostSpecials = map[string]struct{}{
+	"PATH":    {},
+	"USER":    {},
+	"LOGNAME": {},
+	"LANG":    {},
+	"TERM":    {},
+	"TMPDIR":  {},
+}
+
+func lintScript(cmd CommandSettings) []error {
+	var (
+		list    []error
+		refs    = make(map[string]bool)
+		defined = make(map[string]struct{})
+	)
+	for _, line := range cmd.Lines {
+		scanReferences(line, refs, defined)
+	}
+	environ, err := cmd.exported()
+	if err != nil {
+		cmd.Inherit = false
+		environ = cmd.Environ()
+	}
+	for _, n := range cmd.NeedsEnv {
+		environ[n] = ""
+	}
+	known := func(ident string) bool {
+		if _, ok := defined[ident]; ok {
+			return true
+		}
+		if _, ok := environ[ident]; ok {
+			return true
+		}
+		if _, ok := shellSpecials[ident]; ok {
+			return true
+		}
+		if _, ok := hostSpecials[ident]; ok && cmd.Inherit {
+			return true
+		}
+		return cmd.locals.Defined(ident)
+	}
+	for _, o := range cmd.Options {
+		for _, n := range []string{o.Short, o.Long} {
+			if n != "" {
+				defined[n] = struct{}{}
+			}
+		}
+		_, short := refs[o.Short]
+		_, long := refs[o.Long]
+		if !short && !long {
+			name := o.Long
+			if name == "" {
+				name = o.Short
+			}
+			list = append(list, fmt.Errorf("%s: option %s never used in script", cmd.Command(), optionName(name)))
+		}
+	}
+	for _, a := range cmd.Args {
+		defined[a.Name] = struct{}{}
+	}
+	var undefined []string
+	for ident, required := range refs {
+		if required && !known(ident) {
+			undefined = append(undefined, ident)
+		}
+	}
+	sort.Strings(undefined)
+	for _, ident := range undefined {
+		list = append(list, fmt.Errorf("%s: $%s: variable not defined", cmd.Command(), ident))
+	}
+	return list
+}
+
+func scanReferences(line string, refs map[string]bool, defined map[string]struct{}) {
+	var (
+		str    = []rune(line)
+		quoted bool
+		word   = true
+	)
+	readIdent := func(i int) (string, int) {
+		pos := i
+		for i < len(str) && isIdent(str[i]) {
+			i++
+		}
+		return string(str[pos:i]), i
+	}
+	for i := 0; i < len(str); i++ {
+		switch c := str[i]; {
+		case c == backslash:
+			i++
+		case c == squote && !quoted:
+			for i++; i < len(str) && str[i] != squote; i++ {
+			}
+		case c == dquote:
+			quoted = !quoted
+		case c == dollar && i+1 < len(str):
+			i++
+			enclosed := str[i] == lcurly
+			if enclosed {
+				i++
+				if i < len(str) && str[i] == pound {
+					i++
+				}
+			}
+			if i >= len(str) || !(isLetter(str[i]) || str[i] == underscore) {
+				i--
+				break
+			}
+			ident, next := readIdent(i)
+			if !refs[ident] {
+				refs[ident] = !enclosed || !hasDefault(str[next:])
+			}
+			i = next - 1
+		case word && (isLetter(c) || c == underscore):
+			ident, next := readIdent(i)
+			switch rest := strings.TrimLeft(string(str[next:]), " \t"); {
+			case strings.HasPrefix(rest, "+="):
+				defined[ident] = struct{}{}
+			case strings.HasPrefix(rest, "=") && !strings.HasPrefix(rest, "=="):
+				defined[ident] = struct{}{}
+			case ident == "for" || ident == "read" || ident == "local" || ident == "export":
+				for _, f := range strings.Fields(rest) {
+					if f == "in" || strings.ContainsAny(f, ";|&") {
+						break
+					}
+					f, _, _ = strings.Cut(f, "=")
+					if !strings.HasPrefix(f, "-") {
+						defined[f] = struct{}{}
+					}
+				}
+			}
+			i = next - 1
+		}
+		if i < len(str) {
+			word = isBlank(str[i]) || strings.ContainsRune(";&|({", str[i])
+		}
+	}
+}
+
+func hasDefault(str []rune) bool {
+	rest := string(str)
+	for _, p := range []string{":-", ":=", ":+", "-", "=", "+"} {
+		if strings.HasPrefix(rest, p) {
+			return true
+		}
+	}
+	return false
+}
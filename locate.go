@@ -0,0 +1,99 @@
+package maestro
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const DefaultProjectDir = ".maestro"
+
+type Location struct {
+	File    string
+	Reason  string
+	Checked []string
+}
+
+func Locate(file, env string) Location {
+	var loc Location
+	if file != "" {
+		loc.File = file
+		loc.Reason = "given with -f/--file"
+		return loc
+	}
+	if env != "" {
+		loc.File = env
+		loc.Reason = "given with MAESTRO_FILE"
+		return loc
+	}
+	candidates := []string{DefaultFile, DefaultYAMLFile, filepath.Join(DefaultProjectDir, DefaultFile)}
+	for _, c := range candidates {
+		if loc.exists(c) {
+			loc.File = c
+			loc.Reason = "found in the current directory"
+			if filepath.Dir(c) == DefaultProjectDir {
+				loc.Reason = fmt.Sprintf("found in the %s directory", DefaultProjectDir)
+			}
+			return loc
+		}
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		loc.File = DefaultFile
+		loc.Reason = "default file (current directory unknown)"
+		return loc
+	}
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+		for _, c := range candidates {
+			if c = filepath.Join(dir, c); loc.exists(c) {
+				loc.File = c
+				loc.Reason = fmt.Sprintf("found in the parent directory %s", dir)
+				return loc
+			}
+		}
+	}
+	loc.File = DefaultFile
+	loc.Reason = "default file (no maestro file found)"
+	return loc
+}
+
+func (l *Location) exists(file string) bool {
+	i, err := os.Stat(file)
+	if err == nil && i.Mode().IsRegular() {
+		return true
+	}
+	l.Checked = append(l.Checked, file)
+	return false
+}
+
+func (m *Maestro) WhichFile(args []string) error {
+	set := flag.NewFlagSet(CmdWhichFile, flag.ContinueOnError)
+	if err := parseFlags(set, args); err != nil {
+		return err
+	}
+	file, err := filepath.Abs(m.Location.File)
+	if err != nil {
+		file = m.Location.File
+	}
+	fmt.Fprintf(m.Stdout, "file:   %s", file)
+	fmt.Fprintln(m.Stdout)
+	fmt.Fprintf(m.Stdout, "reason: %s", m.Location.Reason)
+	fmt.Fprintln(m.Stdout)
+	if len(m.Location.Checked) > 0 {
+		fmt.Fprintln(m.Stdout, "checked:")
+		for _, c := range m.Location.Checked {
+			fmt.Fprintf(m.Stdout, "  %s: not found", c)
+			fmt.Fprintln(m.Stdout)
+		}
+	}
+	if _, err := os.Stat(m.Location.File); err != nil {
+		return err
+	}
+	return nil
+}
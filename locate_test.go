@@ -0,0 +1,60 @@
+package maestro_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/midbel/maestro"
+)
+
+func TestLocate(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		project = filepath.Join(root, maestro.DefaultProjectDir)
+		deep    = filepath.Join(root, "sub", "deep")
+	)
+	for _, d := range []string{project, deep} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(project, maestro.DefaultFile), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if loc := maestro.Locate("other.mf", "env.mf"); loc.File != "other.mf" {
+		t.Errorf("file given with -f should be picked, got %s", loc.File)
+	}
+	if loc := maestro.Locate("", "env.mf"); loc.File != "env.mf" {
+		t.Errorf("file given with MAESTRO_FILE should be picked, got %s", loc.File)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(maestro.DefaultProjectDir, maestro.DefaultFile)
+	if loc := maestro.Locate("", ""); loc.File != want {
+		t.Errorf("file in project directory should be picked! want %s, got %s", want, loc.File)
+	}
+	if err := os.Chdir(deep); err != nil {
+		t.Fatal(err)
+	}
+	want = filepath.Join(project, maestro.DefaultFile)
+	if loc := maestro.Locate("", ""); loc.File != want {
+		t.Errorf("file in parent directory should be picked! want %s, got %s", want, loc.File)
+	}
+	if err := os.WriteFile(filepath.Join(deep, maestro.DefaultFile), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if loc := maestro.Locate("", ""); loc.File != maestro.DefaultFile {
+		t.Errorf("file in current directory should be picked, got %s", loc.File)
+	}
+}
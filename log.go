@@ -0,0 +1,89 @@
+package maestro
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+func (m *Maestro) Log(args []string) error {
+	var (
+		set    = flag.NewFlagSet(CmdLog, flag.ContinueOnError)
+		limit  = set.Int("n", 10, "show the last n runs")
+		failed = set.Bool("f", false, "only show failed runs")
+	)
+	if err := parseFlags(set, args); err != nil {
+		return err
+	}
+	if m.MetaExec.History == "" {
+		return fmt.Errorf("history not enabled")
+	}
+	list, err := readHistory(m.MetaExec.History)
+	if err != nil {
+		return err
+	}
+	var (
+		name = set.Arg(0)
+		runs = filterHistory(list, name, *failed)
+	)
+	if *limit > 0 && len(runs) > *limit {
+		runs = runs[len(runs)-*limit:]
+	}
+	for i := len(runs) - 1; i >= 0; i-- {
+		showEntry(m.Stdout, runs[i])
+	}
+	if name == "" {
+		return nil
+	}
+	all := filterHistory(list, name, false)
+	if len(all) == 0 {
+		return nil
+	}
+	s := computeStats(all)[name]
+	fmt.Fprintln(m.Stdout)
+	fmt.Fprintf(m.Stdout, "runs: %d, failures: %d, average: %s", s.Runs, s.Failures, s.Average().Round(time.Millisecond))
+	fmt.Fprintln(m.Stdout)
+	if e, ok := lastFailure(all); ok {
+		fmt.Fprintf(m.Stdout, "last failure: %s (%s)", e.Start.Format("2006-01-02 15:04:05"), e.Error)
+		fmt.Fprintln(m.Stdout)
+	}
+	return nil
+}
+
+func filterHistory(list []HistoryEntry, name string, failed bool) []HistoryEntry {
+	var es []HistoryEntry
+	for _, e := range list {
+		if name != "" && e.Command != name {
+			continue
+		}
+		if failed && !e.Failed() {
+			continue
+		}
+		es = append(es, e)
+	}
+	return es
+}
+
+func lastFailure(list []HistoryEntry) (HistoryEntry, bool) {
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i].Failed() {
+			return list[i], true
+		}
+	}
+	return HistoryEntry{}, false
+}
+
+func showEntry(w io.Writer, e HistoryEntry) {
+	status := "ok"
+	if e.Failed() {
+		status = fmt.Sprintf("failed (%d)", e.Code)
+	}
+	host := e.Host
+	if host == "" {
+		host = HostLocal
+	}
+	fmt.Fprintf(w, "%s %-20s %-12s %-10s %-20s %s", e.Start.Format("2006-01-02 15:04:05"), e.Command, status, e.Elapsed().Round(time.Millisecond), host, strings.Join(e.Args, " "))
+	fmt.Fprintln(w)
+}
@@ -1,8 +1,11 @@
 package maestro
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -13,12 +16,17 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/midbel/distance"
+	"github.com/midbel/maestro/internal/copyslice"
 	"github.com/midbel/maestro/internal/env"
 	"github.com/midbel/maestro/internal/help"
+	"github.com/midbel/maestro/internal/ignore"
+	"github.com/midbel/maestro/internal/ordered"
 	"github.com/midbel/maestro/internal/stdio"
+	"github.com/midbel/maestro/schedule"
 	"github.com/midbel/tish"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/sync/errgroup"
@@ -26,18 +34,37 @@ import (
 )
 
 const (
-	CmdHelp     = "help"
-	CmdVersion  = "version"
-	CmdAll      = "all"
-	CmdDefault  = "default"
-	CmdListen   = "listen"
-	CmdServe    = "serve"
-	CmdGraph    = "graph"
-	CmdSchedule = "schedule"
+	CmdHelp       = "help"
+	CmdVersion    = "version"
+	CmdAll        = "all"
+	CmdDefault    = "default"
+	CmdListen     = "listen"
+	CmdServe      = "serve"
+	CmdGraph      = "graph"
+	CmdSchedule   = "schedule"
+	CmdStats      = "stats"
+	CmdTest       = "test"
+	CmdExport     = "export"
+	CmdEntrypoint = "entrypoint"
+	CmdOrder      = "order"
+	CmdDeps       = "deps"
+	CmdBatch      = "batch"
+	CmdRun        = "run"
+	CmdLint       = "lint"
+	CmdEncrypt    = "encrypt"
+	CmdLog        = "log"
+	CmdSchema     = "schema"
+	CmdDiff       = "diff"
+	CmdExplain    = "explain"
+	CmdCompletion = "completion"
+	CmdWhichFile  = "which-file"
 )
 
+const HostLocal = "local"
+
 const (
 	DefaultFile     = "maestro.mf"
+	DefaultYAMLFile = "maestro.yaml"
 	DefaultVersion  = "0.1.0"
 	DefaultHttpAddr = ":9090"
 )
@@ -47,14 +74,40 @@ type Maestro struct {
 	MetaAbout
 	MetaSSH
 	MetaHttp
+	MetaSMTP
 
 	Includes Dirs
+	Config   string
+	Location Location
 	Locals   *env.Env
 	Commands Registry
+	Runs     map[string][]string
+	Excludes *ignore.Matcher
 
-	Remote     bool
+	Remote     RemoteMode
 	NoDeps     bool
+	Force      bool
+	Yes        bool
+	Budget     float64
 	WithPrefix bool
+	Github     bool
+	Report     string
+	Globals    []string
+	SkipDeps   Patterns
+	OnlyDeps   Patterns
+	DepArgs    Overrides
+	Failures   Failures
+
+	Stdout io.Writer
+	Stderr io.Writer
+
+	results   *recordSet
+	limits    *limitSet
+	limiter   *throttle
+	health    *healthState
+	artifacts *artifactCache
+	evaluated *evaluation
+	confirmed map[string]struct{}
 }
 
 func New() *Maestro {
@@ -62,14 +115,32 @@ func New() *Maestro {
 		File:    DefaultFile,
 		Version: DefaultVersion,
 	}
+	mexec := MetaExec{
+		IgnoreFiles:     []string{ignore.DefaultFile},
+		SpillThreshold:  DefaultSpillThreshold,
+		ScheduleWorkers: maxParallelJob,
+		ScheduleQueue:   maxParallelJob,
+		ScheduleGrace:   defaultGrace,
+	}
 	mhttp := MetaHttp{
 		Addr: DefaultHttpAddr,
 	}
 	return &Maestro{
 		Locals:    env.EmptyEnv(),
+		MetaExec:  mexec,
 		MetaAbout: about,
 		MetaHttp:  mhttp,
-		Commands:  make(Registry),
+		results:   &recordSet{},
+		limits:    &limitSet{},
+		artifacts: &artifactCache{},
+		evaluated: &evaluation{},
+		limiter:   &throttle{},
+		health:    &healthState{},
+		confirmed: make(map[string]struct{}),
+		Commands:  NewRegistry(),
+		Runs:      make(map[string][]string),
+		Stdout:    stdio.Stdout,
+		Stderr:    stdio.Stderr,
 	}
 }
 
@@ -84,43 +155,145 @@ func (m *Maestro) Load(file string) error {
 	}
 	defer r.Close()
 
-	d, err := NewDecoderWithEnv(r, m.Locals)
+	var rs io.Reader = r
+	if isYAML(file) {
+		if rs, err = readYAML(r); err != nil {
+			return err
+		}
+	}
+	d, err := NewDecoderWithEnv(rs, m.Locals)
 	if err != nil {
 		return err
 	}
+	d.setFile(file)
 	if err := d.decode(m); err != nil {
 		return err
 	}
+	if err := m.loadConfig(file); err != nil {
+		return err
+	}
 	m.MetaAbout.File = file
-	return nil
+	if c := m.MetaExec.Cache; c != "" && !filepath.IsAbs(c) {
+		m.MetaExec.Cache = filepath.Join(filepath.Dir(file), c)
+	}
+	m.Excludes, err = ignore.Load(filepath.Dir(file), m.MetaExec.IgnoreFiles...)
+	return err
 }
 
 func (m *Maestro) Register(cmd CommandSettings) error {
-	_, ok := m.Commands[cmd.Name]
-	if !ok {
-		m.Commands[cmd.Name] = cmd
-		return nil
-	}
-	return fmt.Errorf("%s command already registered", cmd.Name)
+	return m.Commands.Register(cmd)
+}
+
+type evaluation struct {
+	once sync.Once
+	err  error
+}
+
+// evaluate runs the command substitutions of the properties of the commands
+// once, the first time maestro needs them and never when the file is decoded.
+// The tags are always evaluated since they are used to select the commands.
+// The hosts and the workdir of the lazy commands are left as they are and
+// evaluated each time the commands are executed
+func (m *Maestro) evaluate() error {
+	m.evaluated.once.Do(func() {
+		for _, c := range m.Commands.Values() {
+			x, err := c.evaluate()
+			if err != nil {
+				m.evaluated.err = err
+				return
+			}
+			m.Commands.Put(x)
+		}
+	})
+	return m.evaluated.err
 }
 
 func (m *Maestro) ListenAndServe(args []string) error {
 	var (
-		set  = flag.NewFlagSet(CmdServe, flag.ExitOnError)
-		addr = set.String("a", m.MetaHttp.Addr, "listening address")
+		set       = flag.NewFlagSet(CmdServe, flag.ContinueOnError)
+		addr      = set.String("a", m.MetaHttp.Addr, "listening address")
+		schedules = set.Bool("s", false, "run scheduled commands alongside the server")
 	)
-	if err := set.Parse(args); err != nil {
+	if err := parseFlags(set, args); err != nil {
 		return err
 	}
-	setupRoutes(m)
+	ctx := interruptContext()
 	server := http.Server{
-		Addr: *addr,
+		Addr:    *addr,
+		Handler: setupRoutes(m),
+		BaseContext: func(net.Listener) context.Context {
+			return context.WithValue(context.Background(), serverKey{}, ctx)
+		},
+	}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+	if err := m.evaluate(); err != nil {
+		return err
+	}
+	if *schedules {
+		cmds, err := m.scheduledCommands(nil, "")
+		if err != nil {
+			return err
+		}
+		m.health.setSchedule(schedRunning, nil)
+		go func() {
+			err := m.schedule(ctx, cmds, m.Stdout, m.Stderr)
+			m.health.setSchedule(schedStopped, err)
+		}()
+	}
+	var err error
+	if m.MetaHttp.CertFile != "" && m.MetaHttp.KeyFile != "" {
+		err = server.ListenAndServeTLS(m.MetaHttp.CertFile, m.MetaHttp.KeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
 	}
-	return server.ListenAndServe()
+	return err
 }
 
-func (m *Maestro) Graph(name string) error {
-	all, err := m.traverseGraph(name, 0)
+func (m *Maestro) Graph(args []string) error {
+	var (
+		set    = flag.NewFlagSet(CmdGraph, flag.ContinueOnError)
+		full   = set.Bool("full", false, "show hooks and schedules attached to commands")
+		format string
+	)
+	set.StringVar(&format, "f", graphTree, "output format (tree, dot, json)")
+	set.StringVar(&format, "format", graphTree, "output format (tree, dot, json)")
+	if err := parseFlags(set, args); err != nil {
+		return err
+	}
+	name := set.Arg(0)
+	if name == "" {
+		name = m.MetaExec.Default
+	}
+	switch format {
+	case graphTree:
+	case graphDot, graphJSON:
+		g, err := m.graph(name)
+		if err != nil {
+			return err
+		}
+		if format == graphDot {
+			writeDot(m.Stdout, g)
+			return nil
+		}
+		return writeGraphJSON(m.Stdout, g)
+	default:
+		return fmt.Errorf("%s: unsupported graph format", format)
+	}
+	if *full {
+		showHooks(m.Stdout, "before", m.MetaExec.Before)
+	}
+	all, err := m.traverseGraph(name, 0, *full)
+	if *full {
+		showHooks(m.Stdout, "after", m.MetaExec.After)
+		showHooks(m.Stdout, "on error", m.MetaExec.Error)
+		showHooks(m.Stdout, "on success", m.MetaExec.Success)
+	}
 
 	var (
 		seen = make(map[string]struct{})
@@ -134,106 +307,402 @@ func (m *Maestro) Graph(name string) error {
 		seen[n] = zero
 		deps = append(deps, n)
 	}
-	fmt.Fprintf(stdio.Stdout, "order %s -> %s", strings.Join(deps, " -> "), name)
-	fmt.Fprintln(stdio.Stdout)
+	fmt.Fprintf(m.Stdout, "order %s -> %s", strings.Join(deps, " -> "), name)
+	fmt.Fprintln(m.Stdout)
 	return err
 }
 
+func (m *Maestro) Deps(args []string) error {
+	var (
+		set  = flag.NewFlagSet(CmdDeps, flag.ContinueOnError)
+		only = set.String("only", "all", "dependencies to execute (all, direct)")
+		with = set.Bool("with-root", false, "execute the command itself after its dependencies")
+		skip = set.Bool("skip-root", true, "do not execute the command itself (default)")
+	)
+	if err := parseFlags(set, args); err != nil {
+		return err
+	}
+	// skip-root is kept for compatibility: skipping the command is the default
+	*with = *with || !*skip
+	name, rest := m.MetaExec.Default, set.Args()
+	if len(rest) > 0 {
+		name, rest = rest[0], rest[1:]
+	}
+	option := m.executeOption()
+	switch *only {
+	case "all":
+	case "direct":
+		option.Direct = true
+	default:
+		return fmt.Errorf("%s: invalid value for only (expected all or direct)", *only)
+	}
+	option.NoDeps = false
+
+	var (
+		ctx = interruptContext()
+		ex  executer
+	)
+	if *with {
+		cmd, err := m.setup(ctx, name, true)
+		if err != nil {
+			return err
+		}
+		list, err := m.resolveDependencies(cmd.Dependencies(), option)
+		if err != nil {
+			return err
+		}
+		root := createMain(m.observe(cmd, option), rest, list)
+		root.ignore = option.Ignore
+		ex = root
+	} else {
+		cmd, err := m.Commands.Lookup(name)
+		if err != nil {
+			return m.suggest(err, name)
+		}
+		list, err := m.resolveDependencies(cmd.Deps, option)
+		if err != nil {
+			return err
+		}
+		ex = list
+	}
+	tree, err := createTree(ex)
+	if err != nil {
+		return err
+	}
+	defer tree.Close()
+	tree.prefix = option.Prefix
+
+	err = tree.Execute(ctx, m.Stdout, m.Stderr)
+	if err := m.writeReport(); err != nil {
+		fmt.Fprintf(m.Stderr, "report: %s", err)
+		fmt.Fprintln(m.Stderr)
+	}
+	return err
+}
+
+func (m *Maestro) Order(args []string) error {
+	set := flag.NewFlagSet(CmdOrder, flag.ContinueOnError)
+	if err := parseFlags(set, args); err != nil {
+		return err
+	}
+	name := set.Arg(0)
+	if name == "" {
+		name = m.MetaExec.Default
+	}
+	list, err := m.order(name)
+	if err != nil {
+		return err
+	}
+	for _, n := range list {
+		fmt.Fprintln(m.Stdout, n)
+	}
+	return nil
+}
+
+func (m *Maestro) order(name string) ([]string, error) {
+	var (
+		list     []string
+		done     = make(map[string]struct{})
+		visiting = make(map[string]struct{})
+		zero     = struct{}{}
+		traverse func(string, bool) error
+	)
+	traverse = func(name string, optional bool) error {
+		if _, ok := done[name]; ok {
+			return nil
+		}
+		if _, ok := visiting[name]; ok {
+			return fmt.Errorf("%s: dependency cycle detected", name)
+		}
+		cmd, err := m.Commands.Lookup(name)
+		if err != nil {
+			if optional {
+				return nil
+			}
+			return err
+		}
+		visiting[name] = zero
+		for _, d := range cmd.Deps {
+			if err := traverse(d.Key(), d.Optional); err != nil {
+				return err
+			}
+		}
+		delete(visiting, name)
+		done[name] = zero
+		list = append(list, name)
+		return nil
+	}
+	return list, traverse(name, false)
+}
+
+func showHooks(w io.Writer, kind string, names []string) {
+	if len(names) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s: %s", kind, strings.Join(names, ", "))
+	fmt.Fprintln(w)
+}
+
 func (m *Maestro) Schedule(args []string) error {
 	var (
-		set   = flag.NewFlagSet(CmdSchedule, flag.ExitOnError)
+		set   = flag.NewFlagSet(CmdSchedule, flag.ContinueOnError)
 		list  = set.Bool("l", false, "show list of schedule command")
 		limit = set.Int("n", 0, "show next schedule time")
+		tag   = set.String("t", "", "only schedule commands having the given tag")
+		dry   = set.Bool("d", false, "print the schedules that would be run without running them")
+		asjs  = set.Bool("j", false, "print the upcoming runs as JSON")
 	)
-	if err := set.Parse(args); err != nil {
+	if err := parseFlags(set, args); err != nil {
 		return err
 	}
-	if *list {
-		return m.scheduleList(args, *limit)
+	cmds, err := m.scheduledCommands(set.Args(), *tag)
+	if err != nil {
+		return err
+	}
+	switch {
+	case *asjs:
+		if *limit <= 0 {
+			*limit = 1
+		}
+		return m.scheduleJSON(cmds, *limit)
+	case *dry:
+		m.scheduleDry(cmds)
+		return nil
+	case *list:
+		return m.scheduleList(cmds, *limit)
+	default:
+		return m.schedule(interruptContext(), cmds, m.Stdout, m.Stderr)
 	}
-	return m.schedule(args, stdio.Stdout, stdio.Stderr)
 }
 
-func (m *Maestro) schedule(args []string, stdout, stderr io.Writer) error {
-	sort.Strings(args)
-	grp, ctx := errgroup.WithContext(interruptContext())
-	for _, c := range m.Commands {
-		var (
-			x = sort.SearchStrings(args, c.Name)
-			k = x < len(args) && args[x] == c.Name
-		)
-		if len(args) > 0 && !k {
-			continue
+func (m *Maestro) schedule(ctx context.Context, cmds []CommandSettings, stdout, stderr io.Writer) error {
+	for _, c := range cmds {
+		if err := m.confirmRequired(c); err != nil {
+			return err
 		}
+	}
+	pool := schedule.NewPool(int(m.MetaExec.ScheduleWorkers), int(m.MetaExec.ScheduleQueue))
+	defer pool.Close()
+
+	grp, ctx := errgroup.WithContext(ctx)
+	runs, cancel := graceContext(ctx, m.MetaExec.ScheduleGrace)
+	defer cancel()
+	for _, c := range cmds {
 		for i := range c.Schedules {
 			var (
 				c = scheduleContext(c, m.WithPrefix, m.Trace)
 				e = c.Schedules[i]
 			)
+			c.limits = m.limits
+			c.smtp = m.MetaSMTP
+			c.failures = m.Failures
+			c.pool = pool
+			c.runs = runs
 			grp.Go(func() error {
 				return e.Run(ctx, m.Commands.Copy(), c, stdout, stderr)
 			})
 		}
 	}
-	return grp.Wait()
+	err := grp.Wait()
+	if errors.Is(err, context.Canceled) {
+		err = nil
+	}
+	return err
 }
 
-func (m *Maestro) scheduleList(args []string, limit int) error {
+func (m *Maestro) scheduleList(cmds []CommandSettings, limit int) error {
 	if limit == 0 {
-		m.showScheduleShort(args)
+		m.showScheduleShort(cmds)
 	} else {
-		m.showScheduleLong(args, limit)
+		m.showScheduleLong(cmds, limit)
 	}
 	return nil
 }
 
-func (m *Maestro) showScheduleShort(args []string) {
+func (m *Maestro) scheduleDry(cmds []CommandSettings) {
+	for _, c := range cmds {
+		for _, s := range c.Schedules {
+			when := scheduleStartup
+			if !s.reboot() {
+				when = s.Sched.Now().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(m.Stdout, "* %s at %s", c.Command(), when)
+			fmt.Fprintln(m.Stdout)
+			if len(s.Args) > 0 {
+				fmt.Fprintf(m.Stdout, "  args: %s", strings.Join(s.Args, " "))
+				fmt.Fprintln(m.Stdout)
+			}
+			if s.Stdout.File != "" {
+				fmt.Fprintf(m.Stdout, "  stdout: %s", s.Stdout.File)
+				fmt.Fprintln(m.Stdout)
+			}
+			if s.Stderr.File != "" {
+				fmt.Fprintf(m.Stdout, "  stderr: %s", s.Stderr.File)
+				fmt.Fprintln(m.Stdout)
+			}
+			fmt.Fprintf(m.Stdout, "  overlap: %t", s.Overlap)
+			fmt.Fprintln(m.Stdout)
+		}
+	}
+}
+
+type scheduledRun struct {
+	Command string      `json:"command"`
+	Args    []string    `json:"args,omitempty"`
+	Reboot  bool        `json:"reboot,omitempty"`
+	Runs    []time.Time `json:"runs"`
+}
+
+func (m *Maestro) scheduleJSON(cmds []CommandSettings, limit int) error {
+	list := []scheduledRun{}
+	for _, c := range cmds {
+		for _, s := range c.Schedules {
+			r := scheduledRun{
+				Command: c.Command(),
+				Args:    s.Args,
+				Reboot:  s.reboot(),
+				Runs:    []time.Time{},
+			}
+			for i := 0; i < limit && !r.Reboot; i++ {
+				r.Runs = append(r.Runs, s.Sched.Next())
+			}
+			list = append(list, r)
+		}
+	}
+	enc := json.NewEncoder(m.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(list)
+}
+
+func (m *Maestro) showScheduleShort(cmds []CommandSettings) {
 	now := time.Now()
-	for _, c := range m.getCommandByNames(args) {
+	for _, c := range cmds {
 		for _, s := range c.Schedules {
+			if s.reboot() {
+				fmt.Fprintf(m.Stdout, "- %s at %s", c.Command(), scheduleStartup)
+				fmt.Fprintln(m.Stdout)
+				continue
+			}
 			var wait time.Duration
 			for wait <= 0 {
 				next := s.Sched.Next()
 				wait = next.Sub(now)
 			}
-			fmt.Fprintf(stdio.Stdout, "- %s in %s", c.Command(), wait)
-			fmt.Fprintln(stdio.Stdout)
+			fmt.Fprintf(m.Stdout, "- %s in %s", c.Command(), wait)
+			fmt.Fprintln(m.Stdout)
 		}
 	}
 }
 
-func (m *Maestro) showScheduleLong(args []string, limit int) {
-	for _, c := range m.getCommandByNames(args) {
+func (m *Maestro) showScheduleLong(cmds []CommandSettings, limit int) {
+	for _, c := range cmds {
 		for _, s := range c.Schedules {
-			fmt.Fprintln(stdio.Stdout, "*", c.Command())
+			fmt.Fprintln(m.Stdout, "*", c.Command())
+			if s.reboot() {
+				fmt.Fprintf(m.Stdout, "  at %s", scheduleStartup)
+				fmt.Fprintln(m.Stdout)
+				continue
+			}
 			prefix := "next"
 			for i := 0; i < limit; i++ {
 				w := s.Sched.Next()
-				fmt.Fprintf(stdio.Stdout, "  %s at %s", prefix, w.Format("2006-01-02 15:04:05"))
-				fmt.Fprintln(stdio.Stdout)
+				fmt.Fprintf(m.Stdout, "  %s at %s", prefix, w.Format("2006-01-02 15:04:05"))
+				fmt.Fprintln(m.Stdout)
 				prefix = "then"
 			}
 		}
 	}
 }
 
-func (m *Maestro) getCommandByNames(names []string) []CommandSettings {
+func (m *Maestro) scheduledCommands(names []string, tag string) ([]CommandSettings, error) {
+	if err := m.evaluate(); err != nil {
+		return nil, err
+	}
+	var cs []CommandSettings
+	sort.Strings(names)
+	for _, c := range m.Commands.Values() {
+		if len(c.Schedules) == 0 {
+			continue
+		}
+		x := sort.SearchStrings(names, c.Command())
+		if len(names) > 0 && (x >= len(names) || names[x] != c.Command()) {
+			continue
+		}
+		if tag != "" && !hasTag(c.Categories, tag) {
+			continue
+		}
+		cs = append(cs, c)
+	}
+	return cs, nil
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Maestro) Stats(args []string) error {
 	var (
-		cs  []CommandSettings
-		all []CommandSettings
+		set    = flag.NewFlagSet(CmdStats, flag.ContinueOnError)
+		unused = set.Bool("u", false, "show commands that have never been executed")
 	)
+	if err := parseFlags(set, args); err != nil {
+		return err
+	}
+	if m.MetaExec.History == "" {
+		return fmt.Errorf("history not enabled")
+	}
+	list, err := readHistory(m.MetaExec.History)
+	if err != nil {
+		return err
+	}
+	stats := computeStats(list)
+	if *unused {
+		m.showUnused(stats)
+		return nil
+	}
+	m.showStats(stats, set.Args())
+	return nil
+}
+
+func (m *Maestro) showStats(stats map[string]CommandStats, names []string) {
+	var list []CommandStats
 	sort.Strings(names)
-	for n, c := range m.Commands {
-		all = append(all, c)
+	for n, s := range stats {
 		x := sort.SearchStrings(names, n)
-		if x < len(names) && names[x] == n {
-			cs = append(cs, c)
+		if len(names) > 0 && (x >= len(names) || names[x] != n) {
+			continue
+		}
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Runs == list[j].Runs {
+			return list[i].Command < list[j].Command
+		}
+		return list[i].Runs > list[j].Runs
+	})
+	for _, s := range list {
+		fmt.Fprintf(m.Stdout, "%-20s %5d runs %6.2f%% failed avg %-12s last %s", s.Command, s.Runs, s.FailureRate()*100, s.Average().Round(time.Millisecond), s.Last.Format("2006-01-02 15:04:05"))
+		fmt.Fprintln(m.Stdout)
+	}
+}
+
+func (m *Maestro) showUnused(stats map[string]CommandStats) {
+	var list []string
+	for _, n := range m.Commands.Names() {
+		if _, ok := stats[n]; ok {
+			continue
 		}
+		list = append(list, n)
 	}
-	if len(cs) == 0 {
-		return all
+	sort.Strings(list)
+	for _, n := range list {
+		fmt.Fprintln(m.Stdout, "-", n)
 	}
-	return cs
 }
 
 func (m *Maestro) Dry(name string, args []string) error {
@@ -241,8 +710,11 @@ func (m *Maestro) Dry(name string, args []string) error {
 	if err != nil {
 		return err
 	}
-	cmd.SetOut(stdio.Stdout)
-	cmd.SetErr(stdio.Stderr)
+	cmd.SetOut(m.Stdout)
+	cmd.SetErr(m.Stderr)
+	if err := m.dryDiff(name, args); err != nil {
+		return err
+	}
 	return cmd.Dry(args)
 }
 
@@ -250,7 +722,7 @@ func (m *Maestro) ExecuteDefault(args []string) error {
 	if m.MetaExec.Default == "" {
 		return fmt.Errorf("default command not defined")
 	}
-	return m.execute(m.MetaExec.Default, args, stdio.Stdout, stdio.Stderr)
+	return m.execute(m.MetaExec.Default, args, m.Stdout, m.Stderr)
 }
 
 func (m *Maestro) ExecuteAll(args []string) error {
@@ -258,7 +730,7 @@ func (m *Maestro) ExecuteAll(args []string) error {
 		return fmt.Errorf("all command not defined")
 	}
 	for _, n := range m.MetaExec.All {
-		if err := m.execute(n, args, stdio.Stdout, stdio.Stderr); err != nil {
+		if err := m.execute(n, args, m.Stdout, m.Stderr); err != nil {
 			return err
 		}
 	}
@@ -266,11 +738,11 @@ func (m *Maestro) ExecuteAll(args []string) error {
 }
 
 func (m *Maestro) ExecuteHelp(name string) error {
-	return m.executeHelp(name, stdio.Stdout)
+	return m.executeHelp(name, m.Stdout)
 }
 
 func (m *Maestro) ExecuteVersion() error {
-	return m.executeVersion(stdio.Stdout)
+	return m.executeVersion(m.Stdout)
 }
 
 func (m *Maestro) Execute(name string, args []string) error {
@@ -283,32 +755,106 @@ func (m *Maestro) Execute(name string, args []string) error {
 	if m.MetaExec.Dry {
 		return m.Dry(name, args)
 	}
-	if m.Remote {
-		return m.executeRemote(name, args, stdio.Stdout, stdio.Stderr)
+	if m.Remote.Enabled() {
+		return m.executeRemote(name, args, m.Stdout, m.Stderr)
 	}
-	return m.execute(name, args, stdio.Stdout, stdio.Stderr)
+	return m.execute(name, args, m.Stdout, m.Stderr)
 }
 
 func (m *Maestro) execute(name string, args []string, stdout, stderr io.Writer) error {
-	ctx := interruptContext()
+	if err := m.confirm(name); err != nil {
+		return err
+	}
+	if cmd, err := m.Commands.Lookup(name); err == nil {
+		if done, err := m.sandbox(cmd, args, stdout, stderr); done || err != nil {
+			return err
+		}
+	}
+	return m.executeContext(interruptContext(), name, args, stdout, stderr)
+}
+
+func (m *Maestro) executeContext(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	option := m.executeOption()
+	if err := m.checkBudget(name, option); err != nil {
+		return err
+	}
 	cmd, err := m.setup(ctx, name, true)
 	if err != nil {
 		return err
 	}
+	ex, err := m.resolve(cmd, args, option)
+	if err != nil {
+		return err
+	}
+	if c, ok := ex.(io.Closer); ok {
+		defer c.Close()
+	}
+	var (
+		script []string
+		vars   map[string]string
+	)
+	if m.MetaExec.History != "" {
+		script, vars, _ = m.snapshot(name, args)
+	}
+	var (
+		now = time.Now()
+		res = ex.Execute(ctx, stdout, stderr)
+	)
+	e := createEntryHistory(name, args, HostLocal, now, res)
+	e.Script, e.Vars = script, vars
+	m.record(e)
+	if err := m.writeReport(); err != nil {
+		fmt.Fprintf(m.Stderr, "report: %s", err)
+		fmt.Fprintln(m.Stderr)
+	}
+	return res
+}
+
+func (m *Maestro) executeOption() ctreeOption {
 	option := ctreeOption{
 		Trace:  m.Trace,
 		NoDeps: m.NoDeps,
-		Prefix: m.WithPrefix,
+		Prefix: m.WithPrefix && !m.Github,
 		Ignore: m.Ignore,
+		Github: m.Github,
+		Skip:   m.SkipDeps,
+		Only:   m.OnlyDeps,
+		Args:   m.DepArgs,
 	}
-	ex, err := m.resolve(cmd, args, option)
+	if m.Report != "" {
+		option.Record = m.results
+	}
+	return option
+}
+
+func (m *Maestro) writeReport() error {
+	if m.Report == "" {
+		return nil
+	}
+	format, file, ok := strings.Cut(m.Report, "=")
+	if !ok || file == "" {
+		return fmt.Errorf("%s: report should be given as format=file", m.Report)
+	}
+	report, err := getReporter(format)
 	if err != nil {
 		return err
 	}
-	if c, ok := ex.(io.Closer); ok {
-		defer c.Close()
+	w, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	return report.Report(w, m.results.Results())
+}
+
+func (m *Maestro) record(e HistoryEntry) {
+	if m.MetaExec.History == "" {
+		return
+	}
+	if err := appendHistory(m.MetaExec.History, e); err != nil {
+		fmt.Fprintf(m.Stderr, "history: %s", err)
+		fmt.Fprintln(m.Stderr)
 	}
-	return ex.Execute(ctx, stdout, stderr)
 }
 
 func (m *Maestro) executeHelp(name string, w io.Writer) error {
@@ -338,10 +884,36 @@ func (m *Maestro) executeVersion(w io.Writer) error {
 }
 
 func (m *Maestro) executeRemote(name string, args []string, stdout, stderr io.Writer) error {
-	cmd, err := m.Commands.LookupRemote(name)
+	if err := m.evaluate(); err != nil {
+		return err
+	}
+	cmd, err := m.Commands.Lookup(name)
 	if err != nil {
+		return m.suggest(err, name)
+	}
+	if cmd, err = cmd.compute(); err != nil {
 		return err
 	}
+	if err := m.canExecute(cmd); err != nil {
+		return err
+	}
+	if !cmd.Remote() {
+		switch {
+		case cmd.Local():
+		case m.Remote == RemoteAuto:
+			fmt.Fprintf(m.Stderr, "warning: %s has no remote hosts - executing locally", name)
+			fmt.Fprintln(m.Stderr)
+		default:
+			_, err := m.Commands.LookupRemote(name)
+			return err
+		}
+		return m.execute(name, args, stdout, stderr)
+	}
+	if cmd.Local() {
+		if err := m.execute(name, args, stdout, stderr); err != nil {
+			return err
+		}
+	}
 	ex, err := cmd.Prepare()
 	if err != nil {
 		return err
@@ -350,8 +922,9 @@ func (m *Maestro) executeRemote(name string, args []string, stdout, stderr io.Wr
 	if err != nil {
 		return err
 	}
+	hosts := cmd.RemoteHosts()
 	if m.MetaSSH.Parallel <= 0 {
-		n := len(cmd.Hosts)
+		n := len(hosts)
 		m.MetaSSH.Parallel = int64(n)
 	}
 	var (
@@ -368,7 +941,7 @@ func (m *Maestro) executeRemote(name string, args []string, stdout, stderr io.Wr
 	go io.Copy(stdout, pout)
 	go io.Copy(stderr, perr)
 
-	for _, h := range cmd.Hosts {
+	for _, h := range hosts {
 		if _, ok := seen[h]; ok {
 			continue
 		}
@@ -376,19 +949,28 @@ func (m *Maestro) executeRemote(name string, args []string, stdout, stderr io.Wr
 		if err := sema.Acquire(parent, 1); err != nil {
 			return err
 		}
-		host := h
+		host := cmd.HostSettings[h]
+		host.Addr = h
 		grp.Go(func() error {
 			defer sema.Release(1)
-			return m.executeHost(ctx, ex, host, scripts, sshout, ssherr)
+			var (
+				now    = time.Now()
+				err    = m.executeHost(ctx, ex, cmd, host, scripts, sshout, ssherr)
+				config = host.ClientConfig(m.MetaSSH)
+			)
+			m.audit(createEntryAudit(auditSSH, host.Addr, config.User, name, args, now, err))
+			m.record(createEntryHistory(name, args, host.Addr, now, err))
+			return err
 		})
 	}
 	sema.Acquire(parent, m.MetaSSH.Parallel)
 	return grp.Wait()
 }
 
-func (m *Maestro) executeHost(ctx context.Context, cmd Executer, addr string, scripts []string, stdout, stderr io.Writer) error {
+func (m *Maestro) executeHost(ctx context.Context, cmd Executer, settings CommandSettings, host HostSSH, scripts []string, stdout, stderr io.Writer) error {
 	var (
-		prefix = fmt.Sprintf("%s;%s;%s", m.MetaSSH.User, addr, cmd.Command())
+		config = host.ClientConfig(m.MetaSSH)
+		prefix = fmt.Sprintf("%s;%s;%s", config.User, host.Addr, cmd.Command())
 		exec   = func(sess *ssh.Session, line string) error {
 			setPrefix(stdout, prefix)
 			setPrefix(stderr, prefix)
@@ -400,16 +982,16 @@ func (m *Maestro) executeHost(ctx context.Context, cmd Executer, addr string, sc
 			return sess.Run(line)
 		}
 	)
-	config := ssh.ClientConfig{
-		User:            m.MetaSSH.User,
-		Auth:            m.MetaSSH.AuthMethod(),
-		HostKeyCallback: m.CheckHostKey,
-	}
-	client, err := ssh.Dial("tcp", addr, &config)
+	client, err := ssh.Dial("tcp", host.Addr, config)
 	if err != nil {
 		return err
 	}
 	defer client.Close()
+	for _, t := range settings.Uploads {
+		if err := upload(client, t); err != nil {
+			return fmt.Errorf("upload %s: %w", t.Local, err)
+		}
+	}
 	for i := range scripts {
 		select {
 		case <-ctx.Done():
@@ -424,6 +1006,12 @@ func (m *Maestro) executeHost(ctx context.Context, cmd Executer, addr string, sc
 			return err
 		}
 	}
+	multi := len(settings.RemoteHosts()) > 1
+	for _, t := range settings.Downloads {
+		if err := download(client, t, t.localFor(host.Addr, multi)); err != nil {
+			return fmt.Errorf("download %s: %w", t.Remote, err)
+		}
+	}
 	return nil
 }
 
@@ -441,10 +1029,17 @@ func (m *Maestro) help() (string, error) {
 		Help:     m.Help,
 		Commands: make(map[string][]CommandSettings),
 	}
-	for _, c := range m.Commands {
+	if err := m.evaluate(); err != nil {
+		return "", err
+	}
+	for _, c := range m.Commands.Values() {
 		if c.Blocked() {
 			continue
 		}
+		if c.Space != "" {
+			h.Commands[c.Space] = append(h.Commands[c.Space], c)
+			continue
+		}
 		for _, t := range c.Tags() {
 			h.Commands[t] = append(h.Commands[t], c)
 		}
@@ -459,12 +1054,54 @@ func (m *Maestro) help() (string, error) {
 
 func (m *Maestro) canExecute(cmd CommandSettings) error {
 	if cmd.Blocked() {
-		return fmt.Errorf("%s: command can not be called", cmd.Command())
+		return fmt.Errorf("%s: %w", cmd.Command(), errForbidden)
 	}
-	if m.Remote && !cmd.Remote() {
-		return fmt.Errorf("%s can not be executly on remote system", cmd.Command())
+	if m.Remote == RemoteOn && !cmd.Remote() && !cmd.Local() {
+		return fmt.Errorf("%s: %w on remote system", cmd.Command(), errForbidden)
+	}
+	if w, ok := cmd.Blackout.Active(time.Now()); ok && !m.Force {
+		return fmt.Errorf("%s: blackout window %s is active (use --force): %w", cmd.Command(), w, errForbidden)
+	}
+	if err := m.confirmRequired(cmd); err != nil {
+		return err
+	}
+	return m.envRequired(cmd)
+}
+
+func (m *Maestro) envRequired(cmd CommandSettings) error {
+	if m.MetaExec.Dry {
+		return nil
+	}
+	return cmd.envRequired()
+}
+
+func (m *Maestro) confirmRequired(cmd CommandSettings) error {
+	if !cmd.Confirm || m.Yes || m.MetaExec.Dry {
+		return nil
+	}
+	if _, ok := m.confirmed[cmd.Command()]; ok {
+		return nil
+	}
+	return fmt.Errorf("%s: confirmation required (use --yes): %w", cmd.Command(), errForbidden)
+}
+
+func (m *Maestro) confirm(name string) error {
+	cmd, err := m.Commands.Lookup(name)
+	if err != nil || m.confirmRequired(cmd) == nil {
+		return nil
+	}
+	if i, err := os.Stdin.Stat(); err != nil || i.Mode()&os.ModeCharDevice == 0 {
+		return m.confirmRequired(cmd)
+	}
+	fmt.Fprintf(m.Stderr, "Run %s? [y/N] ", cmd.Command())
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		m.confirmed[cmd.Command()] = struct{}{}
+		return nil
+	default:
+		return fmt.Errorf("%s: execution not confirmed", cmd.Command())
 	}
-	return nil
 }
 
 func (m *Maestro) resolve(cmd Executer, args []string, option ctreeOption) (executer, error) {
@@ -473,13 +1110,13 @@ func (m *Maestro) resolve(cmd Executer, args []string, option ctreeOption) (exec
 		err  error
 	)
 	if !option.NoDeps {
-		list, err = m.resolveDependencies(cmd, option)
+		list, err = m.resolveDependencies(cmd.Dependencies(), option)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	root := createMain(cmd, args, list)
+	root := createMain(m.observe(cmd, option), args, list)
 	root.ignore = option.Ignore
 	root.pre, err = m.resolveList(m.Before)
 	root.post, err = m.resolveList(m.After)
@@ -506,91 +1143,132 @@ func (m *Maestro) resolveList(names []string) ([]Executer, error) {
 		if err != nil {
 			return nil, err
 		}
+		m.Failures.inject(x, n)
 		list = append(list, x)
 	}
 	return list, nil
 }
 
-func (m *Maestro) resolveDependencies(cmd Executer, option ctreeOption) (deplist, error) {
-	var (
-		traverse func(Executer) (deplist, error)
-		seen     = make(map[string]struct{})
-		empty    = struct{}{}
-	)
+func (m *Maestro) resolveDependencies(list []CommandDep, option ctreeOption) (deplist, error) {
+	deps, err := m.walkDependencies(list, option)
+	if err != nil {
+		return nil, err
+	}
+	return m.resolveTree(deps, option)
+}
 
-	traverse = func(cmd Executer) (deplist, error) {
-		var set []executer
-		for _, d := range cmd.Dependencies() {
-			if _, ok := seen[d.Key()]; ok && !d.Mandatory {
-				continue
-			}
-			seen[d.Key()] = empty
-			c, err := m.setup(context.Background(), d.Key(), false)
+func (m *Maestro) resolveTree(deps depTree, option ctreeOption) (deplist, error) {
+	var set []executer
+	for _, d := range deps {
+		if d.Reason != "" {
+			continue
+		}
+		var (
+			c   Executer
+			err error
+		)
+		if d.Selected {
+			c, err = m.setup(context.Background(), d.Key(), false)
 			if err != nil {
 				if d.Optional && !d.Mandatory {
 					continue
 				}
 				return nil, err
 			}
-			list, err := traverse(c)
-			if err != nil {
-				return nil, err
-			}
-			ed := createDep(c, d.Args, list)
-			ed.background = d.Bg
+		}
+		list, err := m.resolveTree(d.Deps, option)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			set = append(set, list...)
+			continue
+		}
+		args := append(copyslice.Copy(d.Args), option.Args[d.Key()]...)
+		ed := createDep(m.observe(c, option), args, list)
+		ed.background = d.Bg
 
-			var ex executer = ed
-			if option.Trace {
-				ex = trace(ex)
-			}
-			set = append(set, ex)
+		var ex executer = ed
+		if option.Trace {
+			ex = trace(ex)
 		}
-		return deplist(set), nil
+		set = append(set, ex)
 	}
-	return traverse(cmd)
+	return deplist(set), nil
 }
 
 func (m *Maestro) setup(ctx context.Context, name string, can bool) (Executer, error) {
+	_, ex, err := m.setupCommand(ctx, name, can)
+	return ex, err
+}
+
+func (m *Maestro) setupCommand(ctx context.Context, name string, can bool) (CommandSettings, Executer, error) {
+	if err := m.evaluate(); err != nil {
+		return CommandSettings{}, nil, err
+	}
 	cmd, err := m.Commands.Lookup(name)
 	if err != nil {
-		return nil, m.suggest(err, name)
+		return cmd, nil, m.suggest(err, name)
 	}
-	if err := m.canExecute(cmd); can && err != nil {
-		return nil, err
+	if cmd, err = cmd.compute(); err != nil {
+		return cmd, nil, err
+	}
+	if can {
+		err = m.canExecute(cmd)
+	} else {
+		err = m.envRequired(cmd)
+	}
+	if err != nil {
+		return cmd, nil, err
 	}
 	ex, err := cmd.Prepare(tish.WithFinder(makeFinder(m.Namespace, m.Commands)))
 	if err != nil {
-		return nil, err
+		return cmd, nil, err
 	}
-	return ex, nil
+	m.Failures.inject(ex, cmd.Command())
+	ex = artifactExecuter(cmd, ex, m)
+	return cmd, limitExecuter(cmd, ex, m.limits), nil
 }
 
 func (m *Maestro) suggest(err error, name string) error {
 	var all []string
-	for _, c := range m.Commands {
+	for _, c := range m.Commands.Values() {
 		all = append(all, c.Command())
 		all = append(all, c.Alias...)
 	}
-	all = append(all, CmdHelp, CmdVersion, CmdAll, CmdDefault, CmdServe, CmdGraph, CmdSchedule)
+	all = append(all, CmdHelp, CmdVersion, CmdAll, CmdDefault, CmdServe, CmdGraph, CmdSchedule, CmdStats, CmdTest, CmdExport, CmdEntrypoint, CmdOrder, CmdDeps, CmdBatch, CmdRun, CmdLint, CmdEncrypt, CmdLog, CmdSchema, CmdDiff, CmdExplain, CmdCompletion, CmdWhichFile)
 	return Suggest(err, name, all)
 }
 
-func (m *Maestro) traverseGraph(name string, level int) ([]string, error) {
+func (m *Maestro) traverseGraph(name string, level int, full bool) ([]string, error) {
 	cmd, err := m.Commands.Lookup(name)
 	if err != nil {
 		return nil, err
 	}
 
-	fmt.Fprintf(stdio.Stdout, "%s- %s", strings.Repeat(" ", level*2), name)
-	fmt.Fprintln(stdio.Stdout)
+	fmt.Fprintf(m.Stdout, "%s- %s", strings.Repeat(" ", level*2), name)
+	fmt.Fprintln(m.Stdout)
+	if full {
+		for _, s := range cmd.Schedules {
+			when := scheduleStartup
+			if !s.reboot() {
+				when = s.Sched.Now().Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(m.Stdout, "%s@ scheduled at %s", strings.Repeat(" ", (level+1)*2), when)
+			if len(s.Args) > 0 {
+				fmt.Fprintf(m.Stdout, " with %s", strings.Join(s.Args, " "))
+			}
+			fmt.Fprintln(m.Stdout)
+		}
+	}
 	var list []string
 	for _, d := range cmd.Deps {
-		others, err := m.traverseGraph(d.Name, level+1)
+		others, err := m.traverseGraph(d.Key(), level+1, full)
 		if err != nil {
 			return nil, err
 		}
 		list = append(list, others...)
-		list = append(list, d.Name)
+		list = append(list, d.Key())
 	}
 	return list, nil
 }
@@ -600,6 +1278,20 @@ type MetaExec struct {
 	Namespace string
 	Dry       bool
 	Ignore    bool
+	History   string
+	Audit     string
+	Cache     string
+
+	SpillThreshold int64
+
+	ScheduleWorkers int64
+	ScheduleQueue   int64
+	ScheduleGrace   time.Duration
+
+	IgnoreFiles []string
+	EnvFiles    []EnvFile
+
+	ExportFilter ExportFilter
 
 	Trace bool
 
@@ -639,6 +1331,28 @@ func (m MetaSSH) AuthMethod() []ssh.AuthMethod {
 	return list
 }
 
+type HostSSH struct {
+	Addr string
+	User string
+	Pass string
+	Key  ssh.Signer
+}
+
+func (h HostSSH) ClientConfig(meta MetaSSH) *ssh.ClientConfig {
+	if h.User != "" {
+		meta.User = h.User
+	}
+	if h.Pass != "" || h.Key != nil {
+		meta.Pass = h.Pass
+		meta.Key = h.Key
+	}
+	return &ssh.ClientConfig{
+		User:            meta.User,
+		Auth:            meta.AuthMethod(),
+		HostKeyCallback: meta.CheckHostKey,
+	}
+}
+
 func (m MetaSSH) CheckHostKey(host string, addr net.Addr, key ssh.PublicKey) error {
 	if len(m.Hosts) == 0 {
 		return nil
@@ -661,16 +1375,93 @@ type MetaHttp struct {
 	KeyFile  string
 	Addr     string
 	Base     string
+	Secret   string
+	Tokens   []HttpToken
+	Proxies  []string
 }
 
-type Registry map[string]CommandSettings
+type Registry struct {
+	set *ordered.Map[string, CommandSettings]
+}
+
+func NewRegistry() Registry {
+	return Registry{
+		set: ordered.New[string, CommandSettings](),
+	}
+}
+
+func (r Registry) Len() int {
+	return r.set.Len()
+}
+
+func (r Registry) Names() []string {
+	return r.set.Keys()
+}
+
+func (r Registry) Values() []CommandSettings {
+	return r.set.Values()
+}
+
+func (r Registry) Get(name string) (CommandSettings, bool) {
+	return r.set.Get(name)
+}
+
+func (r Registry) Put(cmd CommandSettings) {
+	r.set.Set(cmd.Command(), cmd)
+}
+
+func (r Registry) Register(cmd CommandSettings) error {
+	if r.set.Has(cmd.Command()) {
+		return fmt.Errorf("%s command already registered", cmd.Command())
+	}
+	r.Put(cmd)
+	return nil
+}
+
+func (r Registry) CheckCycles() error {
+	var (
+		done     = make(map[string]struct{})
+		visiting = make(map[string]int)
+		path     []string
+		traverse func(CommandSettings) error
+	)
+	traverse = func(cmd CommandSettings) error {
+		name := cmd.Command()
+		if _, ok := done[name]; ok {
+			return nil
+		}
+		if i, ok := visiting[name]; ok {
+			cycle := append(copyslice.Copy(path[i:]), name)
+			return CycleError{Path: cycle}
+		}
+		visiting[name] = len(path)
+		path = append(path, name)
+		for _, d := range cmd.Deps {
+			other, err := r.Lookup(d.Key())
+			if err != nil {
+				continue
+			}
+			if err := traverse(other); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		delete(visiting, name)
+		done[name] = struct{}{}
+		return nil
+	}
+	for _, c := range r.Values() {
+		if err := traverse(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
 func (r Registry) Copy() Registry {
-	x := make(Registry)
-	for k, v := range r {
-		x[k] = v
+	return Registry{
+		set: r.set.Copy(),
 	}
-	return x
 }
 
 func (r Registry) Prepare(name string) (Executer, error) {
@@ -693,17 +1484,18 @@ func (r Registry) LookupRemote(name string) (CommandSettings, error) {
 }
 
 func (r Registry) Lookup(name string) (CommandSettings, error) {
-	cmd, ok := r[name]
+	cmd, ok := r.Get(name)
 	if ok {
 		return cmd, nil
 	}
-	for _, c := range r {
+	for _, c := range r.Values() {
 		i := sort.SearchStrings(c.Alias, name)
 		if i < len(c.Alias) && c.Alias[i] == name {
+			c.preset = c.Presets[name]
 			return c, nil
 		}
 	}
-	return cmd, fmt.Errorf("%s: command not defined", name)
+	return cmd, fmt.Errorf("%s: %w", name, errNotFound)
 }
 
 type commandFinder struct {
@@ -719,7 +1511,7 @@ func makeFinder(ns string, set Registry) tish.CommandFinder {
 }
 
 func (c *commandFinder) Find(ctx context.Context, name string) (tish.Command, error) {
-	cmd, ok := c.Commands[name]
+	cmd, ok := c.Commands.Get(name)
 	if !ok {
 		cmd, ok = c.findByName(name)
 		if !ok {
@@ -734,20 +1526,24 @@ func (c *commandFinder) Find(ctx context.Context, name string) (tish.Command, er
 }
 
 func (c *commandFinder) findByName(name string) (CommandSettings, bool) {
-	var list []CommandSettings
-	for _, cmd := range c.Commands {
+	for _, cmd := range c.Commands.Values() {
 		for _, a := range cmd.Alias {
 			if a == name {
 				return cmd, true
 			}
 		}
 	}
-	if len(list) > 0 {
-		return list[0], true
-	}
 	return CommandSettings{}, false
 }
 
+type CycleError struct {
+	Path []string
+}
+
+func (c CycleError) Error() string {
+	return fmt.Sprintf("dependency cycle detected: %s", strings.Join(c.Path, " -> "))
+}
+
 type SuggestionError struct {
 	Others []string
 	Err    error
@@ -768,6 +1564,10 @@ func (s SuggestionError) Error() string {
 	return s.Err.Error()
 }
 
+func (s SuggestionError) Unwrap() error {
+	return s.Err
+}
+
 const defaultKnownHost = "~/.ssh/known_hosts"
 
 type hostEntry struct {
@@ -783,16 +1583,15 @@ func createEntry(host string, key ssh.PublicKey) hostEntry {
 }
 
 func hasHelp(args []string) bool {
-	as := make([]string, len(args))
-	copy(as, args)
-	sort.Strings(as)
-	i := sort.Search(len(as), func(i int) bool {
-		return "-h" <= as[i] || "-help" <= as[i] || "--help" <= as[i]
-	})
-	if i >= len(as) {
-		return false
+	for _, a := range args {
+		switch a {
+		case "--":
+			return false
+		case "-h", "-help", "--help":
+			return true
+		}
 	}
-	return as[i] == "-h" || as[i] == "-help" || as[i] == "--help"
+	return false
 }
 
 func hasError(errs ...error) error {
@@ -0,0 +1,161 @@
+package maestro_test
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/midbel/maestro"
+)
+
+func TestUsageError(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader("echo: {\n\techo\n}\n"))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	tests := map[string]func([]string) error{
+		maestro.CmdLint:     mst.Lint,
+		maestro.CmdEncrypt:  mst.Encrypt,
+		maestro.CmdGraph:    mst.Graph,
+		maestro.CmdDeps:     mst.Deps,
+		maestro.CmdOrder:    mst.Order,
+		maestro.CmdSchedule: mst.Schedule,
+		maestro.CmdStats:    mst.Stats,
+		maestro.CmdTest:     mst.Test,
+		maestro.CmdServe:    mst.ListenAndServe,
+	}
+	for name, fn := range tests {
+		var usage maestro.UsageError
+		err := fn([]string{"-unknown-flag"})
+		if !errors.As(err, &usage) {
+			t.Errorf("%s: usage error expected, got %v", name, err)
+			continue
+		}
+		if usage.Command != name {
+			t.Errorf("%s: command mismatched! got %s", name, usage.Command)
+		}
+		if !strings.HasPrefix(usage.Usage, "usage: "+name) {
+			t.Errorf("%s: usage mismatched! got %s", name, usage.Usage)
+		}
+		if err := fn([]string{"-h"}); !errors.As(err, &usage) || !errors.Is(usage.Err, flag.ErrHelp) {
+			t.Errorf("%s: help should be given as a usage error, got %v", name, err)
+		}
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(confirmed))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("fail to create pipe: %s", err)
+	}
+	w.Close()
+	defer r.Close()
+
+	var (
+		buf   bytes.Buffer
+		stdin = os.Stdin
+	)
+	os.Stdin, mst.Stdout = r, &buf
+	defer func() {
+		os.Stdin = stdin
+	}()
+
+	mst.Yes = false
+	err = mst.Execute("deploy", nil)
+	if err == nil || !strings.Contains(err.Error(), "confirmation required (use --yes)") {
+		t.Errorf("deploy should require a confirmation, got %v", err)
+	}
+	mst.Yes = true
+	if err := mst.Execute("deploy", nil); err != nil {
+		t.Fatalf("deploy should be executed: %s", err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != "deployed" {
+		t.Errorf("output mismatched! want %q, got %q", "deployed", got)
+	}
+}
+
+const confirmed = `
+deploy(confirm = true): {
+	echo deployed
+}
+`
+
+func TestDeps(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(depsRoot))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	var buf bytes.Buffer
+	mst.Stdout = &buf
+
+	tests := []struct {
+		Args []string
+		Want string
+	}{
+		{Args: []string{"build"}, Want: "gen"},
+		{Args: []string{"--skip-root", "build"}, Want: "gen"},
+		{Args: []string{"--with-root", "build"}, Want: "gen\nbuild"},
+		{Args: []string{"--skip-root=false", "build"}, Want: "gen\nbuild"},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		if err := mst.Deps(tt.Args); err != nil {
+			t.Errorf("%v: fail to execute dependencies: %s", tt.Args, err)
+			continue
+		}
+		if got := strings.TrimSpace(buf.String()); got != tt.Want {
+			t.Errorf("%v: output mismatched! want %q, got %q", tt.Args, tt.Want, got)
+		}
+	}
+}
+
+const depsRoot = `
+gen: {
+	echo gen
+}
+build: gen {
+	echo build
+}
+`
+
+func TestScheduleReboot(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(rebooted))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	var buf bytes.Buffer
+	mst.Stdout = &buf
+
+	tests := []struct {
+		Args []string
+		Want string
+	}{
+		{Args: []string{"-l"}, Want: "- warmup at startup"},
+		{Args: []string{"-l", "-n", "3"}, Want: "* warmup\n  at startup"},
+		{Args: []string{"-d"}, Want: "* warmup at startup"},
+		{Args: nil, Want: "warmed up"},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		if err := mst.Schedule(tt.Args); err != nil {
+			t.Errorf("%v: fail to schedule: %s", tt.Args, err)
+			continue
+		}
+		if got := buf.String(); !strings.Contains(got, tt.Want) {
+			t.Errorf("%v: %q not found in output %q", tt.Args, tt.Want, got)
+		}
+	}
+}
+
+const rebooted = `
+warmup(schedule = (time = @reboot)): {
+	echo warmed up
+}
+`
@@ -0,0 +1,288 @@
+package maestro
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/midbel/maestro/internal/stdio"
+	"github.com/midbel/maestro/schedule"
+)
+
+const (
+	NotifyFailure = "failure"
+	NotifySuccess = "success"
+	NotifyAlways  = "always"
+)
+
+const (
+	notifyMail  = "mailto:"
+	notifyExec  = "exec:"
+	notifyHttp  = "http://"
+	notifyHttps = "https://"
+)
+
+const (
+	notifyTimeout = 30 * time.Second
+	notifyTail    = 4096
+)
+
+const defaultNotifyTemplate = `[{{.Status}}] {{.Command}} (exit code: {{.Code}}, elapsed: {{.Duration}})
+{{if .Error}}error: {{.Error}}
+{{end}}{{if .Output}}
+{{.Output}}
+{{end}}`
+
+type MetaSMTP struct {
+	Host     string
+	Username string
+	Password string
+	From     string
+}
+
+type Notification struct {
+	Command string    `json:"command"`
+	Args    []string  `json:"args,omitempty"`
+	Status  string    `json:"status"`
+	Code    int       `json:"code"`
+	Error   string    `json:"error,omitempty"`
+	Start   time.Time `json:"start"`
+	Elapsed float64   `json:"elapsed"`
+	Output  string    `json:"output,omitempty"`
+}
+
+func (n Notification) Duration() time.Duration {
+	return time.Duration(n.Elapsed * float64(time.Second)).Round(time.Millisecond)
+}
+
+func checkNotifyTarget(target string) error {
+	switch {
+	case strings.HasPrefix(target, notifyMail):
+	case strings.HasPrefix(target, notifyExec):
+	case strings.HasPrefix(target, notifyHttp), strings.HasPrefix(target, notifyHttps):
+	case strings.Contains(target, "@") && !strings.Contains(target, ":"):
+	default:
+		return fmt.Errorf("%s: unsupported notify target (email address, mailto:, exec:, http(s):// expected)", target)
+	}
+	return nil
+}
+
+// tailKey gives to the runner the buffer keeping the end of the output of the
+// current run. Each run has its own buffer since runs of a schedule can overlap
+type tailKey struct{}
+
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (t *tailBuffer) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, b...)
+	if n := len(t.buf) - notifyTail; n > 0 {
+		t.buf = t.buf[n:]
+		if x := bytes.IndexByte(t.buf, '\n'); x >= 0 {
+			t.buf = t.buf[x+1:]
+		}
+	}
+	return len(b), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return strings.TrimSpace(string(t.buf))
+}
+
+type notifyRunner struct {
+	schedule.Runner
+	name    string
+	args    []string
+	targets []string
+	on      string
+	tmpl    *template.Template
+	custom  bool
+	smtp    MetaSMTP
+}
+
+func (s *Schedule) notifyRunner(r runner, cmd ScheduleContext) (schedule.Runner, error) {
+	text := s.NotifyTemplate
+	if text == "" {
+		text = defaultNotifyTemplate
+	}
+	tmpl, err := template.New("notify").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &notifyRunner{
+		Runner:  r,
+		name:    cmd.Command(),
+		args:    s.Args,
+		targets: s.Notify,
+		on:      s.NotifyOn,
+		tmpl:    tmpl,
+		custom:  s.NotifyTemplate != "",
+		smtp:    cmd.smtp,
+	}, nil
+}
+
+func (r *notifyRunner) Run(ctx context.Context) error {
+	var (
+		tail = &tailBuffer{}
+		now  = time.Now()
+		err  = r.Runner.Run(context.WithValue(ctx, tailKey{}, tail))
+	)
+	if !r.should(err) {
+		return err
+	}
+	n := Notification{
+		Command: r.name,
+		Args:    r.args,
+		Status:  "ok",
+		Code:    exitCode(err),
+		Start:   now,
+		Elapsed: time.Since(now).Seconds(),
+		Output:  tail.String(),
+	}
+	if err != nil {
+		n.Status = "failed"
+		n.Error = err.Error()
+	}
+	for _, t := range r.targets {
+		if e := r.send(t, n); e != nil {
+			fmt.Fprintf(stdio.Stderr, "[%s] notify %s: %s", r.name, t, e)
+			fmt.Fprintln(stdio.Stderr)
+		}
+	}
+	return err
+}
+
+func (r *notifyRunner) should(err error) bool {
+	switch r.on {
+	case NotifyAlways:
+		return true
+	case NotifySuccess:
+		return err == nil
+	default:
+		return err != nil
+	}
+}
+
+func (r *notifyRunner) send(target string, n Notification) error {
+	switch {
+	case strings.HasPrefix(target, notifyExec):
+		return r.sendExec(strings.TrimPrefix(target, notifyExec), n)
+	case strings.HasPrefix(target, notifyHttp), strings.HasPrefix(target, notifyHttps):
+		return r.sendHttp(target, n)
+	default:
+		return r.sendMail(strings.TrimPrefix(target, notifyMail), n)
+	}
+}
+
+func (r *notifyRunner) message(n Notification) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := r.tmpl.Execute(&buf, n); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (r *notifyRunner) sendMail(to string, n Notification) error {
+	if r.smtp.Host == "" {
+		return fmt.Errorf("SMTP host not defined")
+	}
+	body, err := r.message(n)
+	if err != nil {
+		return err
+	}
+	from := r.smtp.From
+	if from == "" {
+		from = r.smtp.Username
+	}
+	var auth smtp.Auth
+	if r.smtp.Username != "" {
+		pass, err := resolveSecret(r.smtp.Password)
+		if err != nil {
+			return err
+		}
+		host, _, _ := net.SplitHostPort(r.smtp.Host)
+		auth = smtp.PlainAuth("", r.smtp.Username, pass, host)
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: [maestro] %s %s\r\n", n.Command, n.Status)
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n")
+	fmt.Fprintf(&msg, "\r\n")
+	msg.Write(body)
+	return smtp.SendMail(r.smtp.Host, auth, from, strings.Split(to, ","), msg.Bytes())
+}
+
+func (r *notifyRunner) sendExec(command string, n Notification) error {
+	body, err := r.message(n)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout = stdio.Stdout
+	cmd.Stderr = stdio.Stderr
+	cmd.Env = append(os.Environ(),
+		"MAESTRO_COMMAND="+n.Command,
+		"MAESTRO_STATUS="+n.Status,
+		"MAESTRO_CODE="+strconv.Itoa(n.Code),
+		"MAESTRO_ELAPSED="+n.Duration().String(),
+	)
+	return cmd.Run()
+}
+
+func (r *notifyRunner) sendHttp(url string, n Notification) error {
+	var (
+		body []byte
+		kind = "application/json"
+		err  error
+	)
+	if r.custom {
+		body, err = r.message(n)
+		if err == nil && !json.Valid(body) {
+			kind = "text/plain; charset=utf-8"
+		}
+	} else {
+		body, err = json.Marshal(n)
+	}
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(httpHdrContent, kind)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package maestro_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/midbel/maestro"
+)
+
+func TestNotifyWebhook(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf, _ := io.ReadAll(r.Body)
+		if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+			t.Errorf("content type mismatched! want text/plain, got %s", ct)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		bodies = append(bodies, string(buf))
+	}))
+	defer srv.Close()
+
+	mst, err := maestro.Decode(strings.NewReader(fmt.Sprintf(webhooked, srv.URL)))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("tick")
+	if err != nil {
+		t.Fatalf("command not found: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+	cmd.Schedules[0].Run(ctx, mst.Commands, maestro.ScheduleContext{CommandSettings: cmd}, io.Discard, io.Discard)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) < 2 {
+		t.Fatalf("overlapping runs should be notified, got %d notification(s)", len(bodies))
+	}
+	for _, b := range bodies {
+		if !strings.HasPrefix(b, "tick: ") || strings.Count(b, "run") != 1 {
+			t.Errorf("notification should only have the output of its run, got %q", b)
+		}
+	}
+}
+
+const webhooked = `
+tick(
+	schedule = (
+		time            = "@every 10ms",
+		overlap         = true,
+		notify          = "%s",
+		notify_on       = always,
+		notify_template = "{{.Command}}: {{.Status}}: {{.Output}}",
+	),
+): {
+	echo run
+	sleep 0.05
+}
+`
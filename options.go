@@ -2,9 +2,14 @@ package maestro
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+
+	"github.com/midbel/shlex"
 )
 
 type Dirs struct {
@@ -19,6 +24,10 @@ func (d *Dirs) Set(str string) error {
 	return nil
 }
 
+func (d *Dirs) Add(dirs ...string) {
+	d.List = append(d.List, dirs...)
+}
+
 func (d *Dirs) String() string {
 	if len(d.List) == 0 {
 		return "directories"
@@ -26,13 +35,184 @@ func (d *Dirs) String() string {
 	return strings.Join(d.List, ", ")
 }
 
-func (d *Dirs) Exists(file string) (string, bool) {
+func (d *Dirs) Exists(file string) (string, error) {
+	if filepath.IsAbs(file) {
+		if !isRegular(file) {
+			return file, fmt.Errorf("%s: %w", file, fs.ErrNotExist)
+		}
+		return file, nil
+	}
+	var searched []string
 	for i := range d.List {
 		f := filepath.Join(d.List[i], file)
-		if i, err := os.Stat(f); err == nil && i.Mode().IsRegular() {
-			return f, true
+		if isRegular(f) {
+			return f, nil
 		}
+		searched = append(searched, d.List[i])
 	}
+	if isRegular(file) {
+		return file, nil
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		searched = append(searched, cwd)
+	}
+	return file, fmt.Errorf("%s: %w (searched in %s)", file, fs.ErrNotExist, strings.Join(searched, ", "))
+}
+
+func isRegular(file string) bool {
 	i, err := os.Stat(file)
-	return file, err == nil && i.Mode().IsRegular()
+	return err == nil && i.Mode().IsRegular()
+}
+
+type Patterns []string
+
+func (p *Patterns) Set(str string) error {
+	if _, err := path.Match(str, ""); err != nil {
+		return fmt.Errorf("%s: invalid pattern", str)
+	}
+	*p = append(*p, str)
+	return nil
+}
+
+func (p *Patterns) String() string {
+	return strings.Join(*p, ", ")
+}
+
+func (p Patterns) Match(name string) bool {
+	for _, str := range p {
+		if ok, _ := path.Match(str, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type Overrides map[string][]string
+
+func (o *Overrides) Set(str string) error {
+	x := splitDependency(str)
+	if x <= 0 {
+		return fmt.Errorf("%s: expected name:args", str)
+	}
+	args, err := shlex.Split(strings.NewReader(str[x+1:]))
+	if err != nil {
+		return err
+	}
+	if *o == nil {
+		*o = make(Overrides)
+	}
+	(*o)[str[:x]] = append((*o)[str[:x]], args...)
+	return nil
+}
+
+func (o *Overrides) String() string {
+	var list []string
+	for k, vs := range *o {
+		list = append(list, fmt.Sprintf("%s:%s", k, strings.Join(vs, " ")))
+	}
+	return strings.Join(list, ", ")
+}
+
+type Failures map[string][]int
+
+func (f *Failures) Set(str string) error {
+	var (
+		name    = str
+		attempt int
+	)
+	if x := splitDependency(str); x >= 0 {
+		n, err := strconv.Atoi(str[x+1:])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("%s: expected name[:attempt] (attempt > 0)", str)
+		}
+		name, attempt = str[:x], n
+	}
+	if name == "" {
+		return fmt.Errorf("%s: expected name[:attempt]", str)
+	}
+	if *f == nil {
+		*f = make(Failures)
+	}
+	(*f)[name] = append((*f)[name], attempt)
+	return nil
+}
+
+func (f *Failures) String() string {
+	var list []string
+	for k, vs := range *f {
+		for _, v := range vs {
+			if v == 0 {
+				list = append(list, k)
+			} else {
+				list = append(list, fmt.Sprintf("%s:%d", k, v))
+			}
+		}
+	}
+	return strings.Join(list, ", ")
+}
+
+func (f Failures) inject(ex Executer, name string) {
+	c, ok := ex.(*command)
+	if !ok || len(f[name]) == 0 {
+		return
+	}
+	c.failures = f[name]
+}
+
+func splitDependency(str string) int {
+	for i := 0; i < len(str); i++ {
+		if str[i] != ':' {
+			continue
+		}
+		if i+1 < len(str) && str[i+1] == ':' {
+			i++
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+type RemoteMode int8
+
+const (
+	RemoteOff RemoteMode = iota
+	RemoteOn
+	RemoteAuto
+)
+
+func (r *RemoteMode) Set(str string) error {
+	switch str {
+	case "auto":
+		*r = RemoteAuto
+	default:
+		on, err := strconv.ParseBool(str)
+		if err != nil {
+			return fmt.Errorf("%s: invalid value for remote (expected true, false or auto)", str)
+		}
+		*r = RemoteOff
+		if on {
+			*r = RemoteOn
+		}
+	}
+	return nil
+}
+
+func (r *RemoteMode) String() string {
+	switch *r {
+	case RemoteOn:
+		return "true"
+	case RemoteAuto:
+		return "auto"
+	default:
+		return "false"
+	}
+}
+
+func (r *RemoteMode) IsBoolFlag() bool {
+	return true
+}
+
+func (r RemoteMode) Enabled() bool {
+	return r != RemoteOff
 }
@@ -0,0 +1,60 @@
+package maestro_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/midbel/maestro"
+)
+
+func TestInjectFailure(t *testing.T) {
+	tests := []struct {
+		Inject []string
+		Fail   bool
+		Runs   int
+	}{
+		{Inject: nil, Runs: 1},
+		{Inject: []string{"unstable:1"}, Runs: 1},
+		{Inject: []string{"unstable:1", "unstable:2"}, Runs: 1},
+		{Inject: []string{"unstable:1", "unstable:2", "unstable:3"}, Fail: true},
+		{Inject: []string{"unstable"}, Fail: true},
+		{Inject: []string{"other"}, Runs: 1},
+	}
+	for _, tt := range tests {
+		mst, err := maestro.Decode(strings.NewReader(unstable))
+		if err != nil {
+			t.Fatalf("fail to decode: %s", err)
+		}
+		var buf bytes.Buffer
+		mst.Stdout = &buf
+		for _, i := range tt.Inject {
+			if err := mst.Failures.Set(i); err != nil {
+				t.Fatalf("%s: unexpected error: %s", i, err)
+			}
+		}
+		err = mst.Execute("unstable", nil)
+		if got := strings.Count(buf.String(), "run"); got != tt.Runs {
+			t.Errorf("%v: runs mismatched! want %d, got %d", tt.Inject, tt.Runs, got)
+		}
+		if tt.Fail {
+			if err == nil || !strings.Contains(err.Error(), "failure injected (attempt 3)") {
+				t.Errorf("%v: injected failure not reported: %v", tt.Inject, err)
+			}
+		} else if err != nil {
+			t.Errorf("%v: unexpected error: %s", tt.Inject, err)
+		}
+	}
+	var f maestro.Failures
+	for _, str := range []string{"cmd:0", "cmd:x", ":1"} {
+		if err := f.Set(str); err == nil {
+			t.Errorf("%s: invalid value should be rejected", str)
+		}
+	}
+}
+
+const unstable = `
+unstable(retry = 3): {
+	echo run
+}
+`
@@ -0,0 +1,206 @@
+package maestro
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+type TestResult struct {
+	Name     string
+	Output   string
+	Elapsed  time.Duration
+	Failures []string
+}
+
+func (t TestResult) Failed() bool {
+	return len(t.Failures) > 0
+}
+
+type Reporter interface {
+	Report(io.Writer, []TestResult) error
+}
+
+func getReporter(format string) (Reporter, error) {
+	switch format {
+	case "", "tap":
+		return tapReporter{}, nil
+	case "junit", "xml":
+		return junitReporter{}, nil
+	default:
+		return nil, fmt.Errorf("%s: unsupported report format", format)
+	}
+}
+
+type tapReporter struct{}
+
+func (_ tapReporter) Report(w io.Writer, list []TestResult) error {
+	fmt.Fprintln(w, "TAP version 13")
+	fmt.Fprintf(w, "1..%d", len(list))
+	fmt.Fprintln(w)
+	for i, r := range list {
+		status := "ok"
+		if r.Failed() {
+			status = "not ok"
+		}
+		fmt.Fprintf(w, "%s %d - %s", status, i+1, r.Name)
+		fmt.Fprintln(w)
+		if !r.Failed() {
+			continue
+		}
+		fmt.Fprintln(w, "  ---")
+		fmt.Fprintln(w, "  failures:")
+		for _, f := range r.Failures {
+			fmt.Fprintf(w, "    - %q", f)
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "  duration_ms: %d", r.Elapsed.Milliseconds())
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "  ...")
+	}
+	return nil
+}
+
+type junitReporter struct{}
+
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     float64     `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Output    string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Content string `xml:",chardata"`
+}
+
+func (_ junitReporter) Report(w io.Writer, list []TestResult) error {
+	suite := junitSuite{
+		Name:  "maestro",
+		Tests: len(list),
+	}
+	for _, r := range list {
+		c := junitCase{
+			Name:      r.Name,
+			ClassName: "maestro",
+			Time:      r.Elapsed.Seconds(),
+			Output:    r.Output,
+		}
+		if r.Failed() {
+			suite.Failures++
+			c.Failure = &junitFailure{
+				Message: r.Failures[0],
+				Content: strings.Join(r.Failures, "\n"),
+			}
+		}
+		suite.Time += c.Time
+		suite.Cases = append(suite.Cases, c)
+	}
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+	return nil
+}
+
+type recordSet struct {
+	mu   sync.Mutex
+	list []TestResult
+}
+
+func (r *recordSet) add(res TestResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.list = append(r.list, res)
+}
+
+func (r *recordSet) Results() []TestResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]TestResult, len(r.list))
+	copy(list, r.list)
+	return list
+}
+
+type recorder struct {
+	Executer
+	file   string
+	pos    Position
+	github bool
+	stdout io.Writer
+	set    *recordSet
+}
+
+func (m *Maestro) observe(cmd Executer, option ctreeOption) Executer {
+	if option.Record == nil && !option.Github {
+		return cmd
+	}
+	r := recorder{
+		Executer: cmd,
+		github:   option.Github,
+		set:      option.Record,
+	}
+	if s, err := m.Commands.Lookup(cmd.Command()); err == nil {
+		r.file = s.File
+		r.pos = s.Pos
+	}
+	return &r
+}
+
+func (r *recorder) SetOut(w io.Writer) {
+	r.stdout = w
+	r.Executer.SetOut(w)
+}
+
+func (r *recorder) Execute(ctx context.Context, args []string) error {
+	if r.github && r.stdout != nil {
+		fmt.Fprintf(r.stdout, "::group::%s", r.Command())
+		fmt.Fprintln(r.stdout)
+	}
+	var (
+		now = time.Now()
+		err = r.Executer.Execute(ctx, args)
+		res = TestResult{
+			Name:    r.Command(),
+			Elapsed: time.Since(now),
+		}
+	)
+	if err != nil {
+		res.Failures = append(res.Failures, err.Error())
+	}
+	if r.set != nil {
+		r.set.add(res)
+	}
+	if r.github && r.stdout != nil {
+		fmt.Fprintln(r.stdout, "::endgroup::")
+		if err != nil {
+			fmt.Fprintf(r.stdout, "::error file=%s,line=%d::%s: %s", r.file, r.pos.Line, r.Command(), annotate(err.Error()))
+			fmt.Fprintln(r.stdout)
+		}
+	}
+	return err
+}
+
+func annotate(str string) string {
+	str = strings.ReplaceAll(str, "%", "%25")
+	str = strings.ReplaceAll(str, "\r", "%0D")
+	return strings.ReplaceAll(str, "\n", "%0A")
+}
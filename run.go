@@ -0,0 +1,42 @@
+package maestro
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+func (m *Maestro) Run(args []string) error {
+	set := flag.NewFlagSet(CmdRun, flag.ContinueOnError)
+	if err := parseFlags(set, args); err != nil {
+		return err
+	}
+	if set.NArg() == 0 {
+		m.showRuns()
+		return nil
+	}
+	name := set.Arg(0)
+	preset, ok := m.Runs[name]
+	if !ok {
+		var all []string
+		for n := range m.Runs {
+			all = append(all, n)
+		}
+		return Suggest(fmt.Errorf("%s: run not defined", name), name, all)
+	}
+	args = append(append([]string{}, preset[1:]...), set.Args()[1:]...)
+	return m.Execute(preset[0], args)
+}
+
+func (m *Maestro) showRuns() {
+	var names []string
+	for n := range m.Runs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		fmt.Fprintf(m.Stdout, "%-20s %s", n, strings.Join(m.Runs[n], " "))
+		fmt.Fprintln(m.Stdout)
+	}
+}
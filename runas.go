@@ -0,0 +1,110 @@
+package maestro
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"os/user"
+	"strconv"
+
+	"github.com/midbel/tish"
+)
+
+func parseUmask(str string) (int, error) {
+	mask, err := strconv.ParseUint(str, 8, 32)
+	if err != nil || mask > 0777 {
+		return 0, fmt.Errorf("%s: invalid file mode creation mask", str)
+	}
+	return int(mask), nil
+}
+
+type execFinder struct {
+	next tish.CommandFinder
+	sh   *tish.Shell
+
+	user    *user.User
+	harden  bool
+	seccomp []string
+	umask   int
+}
+
+func (f *execFinder) Find(ctx context.Context, name string) (tish.Command, error) {
+	if f.next != nil {
+		if c, err := f.next.Find(ctx, name); err == nil {
+			return c, nil
+		}
+	}
+	if _, err := exec.LookPath(name); err != nil {
+		return nil, fmt.Errorf("%s: command not found", name)
+	}
+	c := execCommand{
+		Cmd:  exec.CommandContext(ctx, name),
+		name: name,
+	}
+	c.Dir = f.sh.Cwd()
+	if f.umask >= 0 {
+		if err := withUmask(c.Cmd, f.umask); err != nil {
+			return nil, err
+		}
+	}
+	if f.harden {
+		if f.user != nil && !canSetCredential() {
+			return nil, fmt.Errorf("%s: hardening can not be combined with sudo", name)
+		}
+		extra, err := hardenCommand(c.Cmd, f.seccomp)
+		if err != nil {
+			return nil, err
+		}
+		c.extra = extra
+	}
+	if f.user != nil {
+		if err := runAs(c.Cmd, f.user); err != nil {
+			return nil, err
+		}
+	}
+	c.base = len(c.Args)
+	return &c, nil
+}
+
+type execCommand struct {
+	*exec.Cmd
+	name  string
+	base  int
+	extra []string
+}
+
+func (c *execCommand) Command() string {
+	return c.name
+}
+
+func (c *execCommand) Type() tish.CommandType {
+	return tish.TypeRegular
+}
+
+func (c *execCommand) SetArgs(args []string) {
+	c.Args = append(c.Args[:c.base], args...)
+}
+
+func (c *execCommand) SetEnv(env []string) {
+	c.Env = append(append([]string{}, env...), c.extra...)
+}
+
+func (c *execCommand) SetIn(r io.Reader) {
+	c.Stdin = r
+}
+
+func (c *execCommand) SetOut(w io.Writer) {
+	c.Stdout = w
+}
+
+func (c *execCommand) SetErr(w io.Writer) {
+	c.Stderr = w
+}
+
+func (c *execCommand) Exit() (int, int) {
+	if c.ProcessState == nil {
+		return 0, 255
+	}
+	return c.ProcessState.Pid(), c.ProcessState.ExitCode()
+}
@@ -0,0 +1,21 @@
+//go:build windows || plan9
+
+package maestro
+
+import (
+	"fmt"
+	"os/exec"
+	"os/user"
+)
+
+func withUmask(_ *exec.Cmd, _ int) error {
+	return nil
+}
+
+func canSetCredential() bool {
+	return false
+}
+
+func runAs(_ *exec.Cmd, u *user.User) error {
+	return fmt.Errorf("%s: switching user not supported on this platform", u.Username)
+}
@@ -0,0 +1,54 @@
+//go:build !windows && !plan9
+
+package maestro
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+func withUmask(cmd *exec.Cmd, mask int) error {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		return err
+	}
+	script := fmt.Sprintf("umask %04o && exec \"$@\"", mask)
+	cmd.Args = append([]string{"sh", "-c", script, "sh", cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = sh
+	return nil
+}
+
+func canSetCredential() bool {
+	return os.Geteuid() == 0
+}
+
+func runAs(cmd *exec.Cmd, u *user.User) error {
+	if !canSetCredential() {
+		sudo, err := exec.LookPath("sudo")
+		if err != nil {
+			return err
+		}
+		cmd.Args = append([]string{"sudo", "-n", "-u", u.Username, "--"}, cmd.Args...)
+		cmd.Path = sudo
+		return nil
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return err
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return err
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{
+			Uid: uint32(uid),
+			Gid: uint32(gid),
+		},
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package maestro
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const sandboxEnv = "MAESTRO_SANDBOX"
+
+var errSandbox = fmt.Errorf("sandboxed command can only be executed from the command line: %w", errForbidden)
+
+// sandboxed is set once maestro runs inside the sandbox. The sandbox applies to
+// the whole process so, outside of it, the commands having the sandbox property
+// refuse to be executed (HTTP server, schedules, batches, dependencies)
+var sandboxed bool
+
+func (m *Maestro) sandbox(cmd CommandSettings, args []string, stdout, stderr io.Writer) (bool, error) {
+	if !cmd.Sandbox {
+		return false, nil
+	}
+	if m.Remote.Enabled() {
+		return true, fmt.Errorf("%s: sandbox can not be used in remote mode", cmd.Command())
+	}
+	if os.Getenv(sandboxEnv) != "" {
+		err := enterSandbox()
+		sandboxed = err == nil
+		return false, err
+	}
+	root, err := filepath.Abs(filepath.Dir(m.MetaAbout.File))
+	if err != nil {
+		return true, err
+	}
+	argv := append([]string{}, m.Globals...)
+	if cmd.Confirm {
+		argv = append(argv, "--yes")
+	}
+	argv = append(argv, cmd.Command())
+	argv = append(argv, args...)
+	if err := runSandbox(interruptContext(), root, argv, stdout, stderr); err != nil {
+		return true, fmt.Errorf("%s: sandbox: %w", cmd.Command(), err)
+	}
+	return true, nil
+}
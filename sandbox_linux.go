@@ -0,0 +1,127 @@
+//go:build linux
+
+package maestro
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const sandboxFlags = syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWNET | syscall.CLONE_NEWUTS
+
+// sandboxFd is the descriptor from which the sandboxed process reads the
+// directory to mount read-only. It is the first of cmd.ExtraFiles
+const sandboxFd = 3
+
+var errSandboxChild = fmt.Errorf("%s is only set by maestro when it starts a sandbox: %w", sandboxEnv, errForbidden)
+
+func runSandbox(ctx context.Context, root string, args []string, stdout, stderr io.Writer) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	cmd := exec.CommandContext(ctx, "/proc/self/exe", args...)
+	cmd.Args[0] = os.Args[0]
+	cmd.Env = append(os.Environ(), sandboxEnv+"=1")
+	cmd.ExtraFiles = []*os.File{r}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: sandboxFlags,
+		Pdeathsig:  syscall.SIGKILL,
+	}
+	if uid := os.Getuid(); uid != 0 {
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER
+		cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: uid, Size: 1},
+		}
+		cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: os.Getgid(), Size: 1},
+		}
+	}
+	err = cmd.Start()
+	r.Close()
+	if err != nil {
+		return err
+	}
+	io.WriteString(w, root)
+	w.Close()
+	return cmd.Wait()
+}
+
+// enterSandbox only trusts the environment when the process is the PID 1 of
+// the namespace created by runSandbox and has received the root from it. It
+// never mounts anything in the namespaces of the host otherwise
+func enterSandbox() error {
+	if os.Getpid() != 1 {
+		return errSandboxChild
+	}
+	root, err := readSandboxRoot()
+	if err != nil {
+		return err
+	}
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return err
+	}
+	if err := syscall.Mount("proc", "/proc", "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return err
+	}
+	scratch := os.TempDir()
+	if !isWithin(root, scratch) {
+		if err := syscall.Mount("tmpfs", scratch, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777"); err != nil {
+			return err
+		}
+	}
+	if err := syscall.Mount(root, root, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return err
+	}
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(root, &st); err != nil {
+		return err
+	}
+	flags := uintptr(st.Flags) & (syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC | syscall.MS_NOATIME | syscall.MS_NODIRATIME | syscall.MS_RELATIME)
+	if err := syscall.Mount("", root, "", flags|syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY, ""); err != nil {
+		return err
+	}
+	if err := os.Unsetenv(sandboxEnv); err != nil {
+		return err
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	return os.Chdir(dir)
+}
+
+func readSandboxRoot() (string, error) {
+	var st syscall.Stat_t
+	if err := syscall.Fstat(sandboxFd, &st); err != nil || st.Mode&syscall.S_IFMT != syscall.S_IFIFO {
+		return "", errSandboxChild
+	}
+	f := os.NewFile(sandboxFd, "sandbox")
+	defer f.Close()
+
+	buf, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	root := string(buf)
+	if !filepath.IsAbs(root) {
+		return "", errSandboxChild
+	}
+	return filepath.Clean(root), nil
+}
+
+func isWithin(dir, parent string) bool {
+	rel, err := filepath.Rel(parent, dir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
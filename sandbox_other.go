@@ -0,0 +1,17 @@
+//go:build !linux
+
+package maestro
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+func runSandbox(_ context.Context, _ string, _ []string, _, _ io.Writer) error {
+	return fmt.Errorf("sandbox not supported on this platform")
+}
+
+func enterSandbox() error {
+	return fmt.Errorf("sandbox not supported on this platform")
+}
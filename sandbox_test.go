@@ -0,0 +1,53 @@
+package maestro_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/midbel/maestro"
+)
+
+func TestSandboxRefused(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(jailed))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	var buf bytes.Buffer
+	mst.Stdout = &buf
+	err = mst.Execute("outer", nil)
+	if err == nil || !strings.Contains(err.Error(), "sandboxed command") {
+		t.Errorf("sandboxed dependency executed outside of the sandbox: %v", err)
+	}
+	if strings.Contains(buf.String(), "jailed") {
+		t.Errorf("sandboxed dependency output found: %s", buf.String())
+	}
+
+	cmd, err := mst.Commands.Lookup("inner")
+	if err != nil {
+		t.Fatalf("inner: command not found: %s", err)
+	}
+	ex, err := cmd.Prepare()
+	if err != nil {
+		t.Fatalf("inner: fail to prepare: %s", err)
+	}
+	buf.Reset()
+	ex.SetOut(&buf)
+	if err := ex.Execute(context.TODO(), nil); err == nil {
+		t.Errorf("inner: sandboxed command executed outside of the sandbox")
+	}
+	if buf.Len() > 0 {
+		t.Errorf("inner: unexpected output: %s", buf.String())
+	}
+}
+
+const jailed = `
+inner(sandbox = true): {
+	echo jailed
+}
+
+outer: inner {
+	echo free
+}
+`
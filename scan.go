@@ -116,6 +116,8 @@ func (s *Scanner) Scan() Token {
 		s.scanOperator(&tok)
 	case isDelimiter(s.char):
 		s.scanDelimiter(&tok)
+	case isMeta(s.char) && s.peek() == dot:
+		s.scanVariadic(&tok)
 	case isMeta(s.char):
 		s.scanMeta(&tok)
 	case isNL(s.char):
@@ -180,6 +182,17 @@ func (s *Scanner) scanEol(tok *Token) {
 	s.skipNL()
 }
 
+func (s *Scanner) scanVariadic(tok *Token) {
+	for i := 0; i < len(argVariadic); i++ {
+		if s.char != dot {
+			tok.Type = Invalid
+			return
+		}
+		s.read()
+	}
+	tok.Type = Variadic
+}
+
 func (s *Scanner) scanMeta(tok *Token) {
 	s.read()
 	for isUpper(s.char) || s.char == underscore {
@@ -222,7 +235,7 @@ func (s *Scanner) scanHeredoc(tok *Token) {
 		io.Copy(&s.str, &tmp)
 	}
 	tok.Literal = strings.TrimSpace(s.str.String())
-	tok.Type = String
+	tok.Type = Heredoc
 }
 
 func (s *Scanner) scanQuote(tok *Token) {
@@ -264,6 +277,10 @@ func (s *Scanner) scanString(tok *Token) {
 
 func (s *Scanner) scanVariable(tok *Token) {
 	s.read()
+	if s.char == lparen && s.peek() == lparen {
+		s.scanArithmetic(tok)
+		return
+	}
 	if s.char == lparen {
 		s.read()
 		for !s.done() && s.char != rparen {
@@ -299,6 +316,32 @@ func (s *Scanner) scanVariable(tok *Token) {
 	}
 }
 
+func (s *Scanner) scanArithmetic(tok *Token) {
+	s.read()
+	s.read()
+	var depth int
+	for !s.done() {
+		if s.char == rparen {
+			if depth == 0 && s.peek() == rparen {
+				break
+			}
+			depth--
+		} else if s.char == lparen {
+			depth++
+		}
+		s.str.WriteRune(s.char)
+		s.read()
+	}
+	tok.Literal = strings.TrimSpace(s.str.String())
+	tok.Type = Arithmetic
+	if s.char != rparen {
+		tok.Type = Invalid
+		return
+	}
+	s.read()
+	s.read()
+}
+
 func (s *Scanner) scanLiteral(tok *Token) {
 	var (
 		ident  = true
@@ -322,7 +365,7 @@ func (s *Scanner) scanLiteral(tok *Token) {
 	switch tok.Literal {
 	case kwTrue, kwFalse:
 		tok.Type = Boolean
-	case kwInclude, kwExport, kwDelete, kwAlias:
+	case kwInclude, kwExport, kwDelete, kwAlias, kwGlobal, kwRuns, kwIfdef, kwIfndef, kwElse, kwEndif:
 		tok.Type = Keyword
 	default:
 		tok.Type = Ident
@@ -335,6 +378,10 @@ func (s *Scanner) scanOperator(tok *Token) {
 		tok.Type = Background
 	case question:
 		tok.Type = Optional
+		if s.peek() == equal {
+			s.read()
+			tok.Type = Default
+		}
 	case star:
 		tok.Type = Mandatory
 	case percent:
@@ -349,9 +396,13 @@ func (s *Scanner) scanDelimiter(tok *Token) {
 	switch s.char {
 	case colon:
 		tok.Type = Dependency
-		if s.peek() == s.char {
+		switch s.peek() {
+		case colon:
 			s.read()
 			tok.Type = Resolution
+		case equal:
+			s.read()
+			tok.Type = Assign
 		}
 	case plus:
 		tok.Type = Append
@@ -406,7 +457,7 @@ func (s *Scanner) toggleBlank(tok Token) {
 		return
 	}
 	switch tok.Type {
-	case Assign, Append:
+	case Assign, Append, Default:
 		s.keepBlank = true
 		s.skipBlank()
 		s.state.Push(scanValue)
@@ -6,12 +6,18 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"runtime/debug"
+	"time"
 
 	"github.com/midbel/maestro/schedule"
 	"github.com/midbel/tish"
 )
 
-const maxParallelJob = 120
+const (
+	maxParallelJob  = 120
+	defaultGrace    = 30 * time.Second
+	scheduleStartup = "startup"
+)
 
 type ScheduleRedirect struct {
 	File      string
@@ -48,6 +54,12 @@ type ScheduleContext struct {
 	CommandSettings
 	Prefix bool
 	Trace  bool
+
+	limits   *limitSet
+	smtp     MetaSMTP
+	failures Failures
+	pool     *schedule.Pool
+	runs     context.Context
 }
 
 func scheduleContext(cmd CommandSettings, prefix, trace bool) ScheduleContext {
@@ -59,12 +71,25 @@ func scheduleContext(cmd CommandSettings, prefix, trace bool) ScheduleContext {
 }
 
 type Schedule struct {
+	Time    string
 	Sched   *schedule.Scheduler
 	Args    []string
 	Stdout  ScheduleRedirect
 	Stderr  ScheduleRedirect
 	Notify  []string
 	Overlap bool
+
+	NotifyOn       string
+	NotifyTemplate string
+
+	Jitter   time.Duration
+	MaxRuns  int
+	Backoff  time.Duration
+	Blackout Blackout
+}
+
+func (s *Schedule) reboot() bool {
+	return s.Sched != nil && s.Sched.Spec().Reboot
 }
 
 func (s *Schedule) Run(ctx context.Context, reg Registry, cmd ScheduleContext, stdout, stderr io.Writer) error {
@@ -75,6 +100,9 @@ func (s *Schedule) Run(ctx context.Context, reg Registry, cmd ScheduleContext, s
 	if c, ok := r.(io.Closer); ok {
 		defer c.Close()
 	}
+	if cmd.pool != nil {
+		return s.Sched.RunPool(ctx, r, cmd.pool)
+	}
 	return s.Sched.Run(ctx, r)
 }
 
@@ -85,37 +113,86 @@ func (s *Schedule) makeRunner(reg Registry, cmd ScheduleContext, stdout, stderr
 		return nil, err
 	}
 	if cmd.Prefix {
-		stdout = writePrefix(stdout, cmd.Name)
+		stdout = writePrefix(stdout, cmd.Command())
 	}
 	stderr, err = s.Stderr.Writer(stderr)
 	if err != nil {
 		return nil, err
 	}
 	if cmd.Prefix {
-		stderr = writePrefix(stderr, cmd.Name)
+		stderr = writePrefix(stderr, cmd.Command())
+	}
+	var (
+		x                 = createRunner(reg, cmd, s.Args, stdout, stderr)
+		r schedule.Runner = x
+	)
+	if len(s.Notify) > 0 {
+		if r, err = s.notifyRunner(x, cmd); err != nil {
+			return nil, err
+		}
+	}
+	if s.MaxRuns > 0 {
+		r = schedule.MaxRuns(r, s.MaxRuns)
+	}
+	if s.Backoff > 0 {
+		r = schedule.Backoff(r, s.Backoff)
+	} else {
+		r = schedule.IgnoreErrors(r)
+	}
+	if s.Jitter > 0 {
+		r = schedule.JitterRunner(r, s.Jitter)
 	}
-	r := createRunner(reg, cmd.CommandSettings, s.Args, stdout, stderr)
 	if !s.Overlap {
 		r = schedule.SkipRunning(r)
 	}
+	if b := cmd.Blackout.merge(s.Blackout); len(b) > 0 {
+		r = blackoutRunner{
+			name:     cmd.Command(),
+			blackout: b,
+			err:      stderr,
+			Runner:   r,
+		}
+	}
 	return r, nil
 }
 
+type blackoutRunner struct {
+	name     string
+	blackout Blackout
+	err      io.Writer
+	schedule.Runner
+}
+
+func (r blackoutRunner) Run(ctx context.Context) error {
+	if w, ok := r.blackout.Active(time.Now()); ok {
+		fmt.Fprintf(r.err, "[%s] run skipped: blackout window %s is active", r.name, w)
+		fmt.Fprintln(r.err)
+		return nil
+	}
+	return r.Runner.Run(ctx)
+}
+
 type runner struct {
-	reg  Registry
-	cmd  CommandSettings
-	args []string
-	out  io.Writer
-	err  io.Writer
+	reg      Registry
+	cmd      CommandSettings
+	args     []string
+	out      io.Writer
+	err      io.Writer
+	limits   *limitSet
+	failures Failures
+	runs     context.Context
 }
 
-func createRunner(reg Registry, cmd CommandSettings, args []string, stdout, stderr io.Writer) schedule.Runner {
+func createRunner(reg Registry, cmd ScheduleContext, args []string, stdout, stderr io.Writer) runner {
 	return runner{
-		reg:  reg,
-		cmd:  cmd,
-		args: args,
-		out:  stdout,
-		err:  stderr,
+		reg:      reg,
+		cmd:      cmd.CommandSettings,
+		args:     args,
+		out:      stdout,
+		err:      stderr,
+		limits:   cmd.limits,
+		failures: cmd.failures,
+		runs:     cmd.runs,
 	}
 }
 
@@ -124,6 +201,9 @@ func (r runner) Find(ctx context.Context, name string) (tish.Command, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := cmd.envRequired(); err != nil {
+		return nil, err
+	}
 	x, err := cmd.Prepare()
 	if err != nil {
 		return nil, err
@@ -132,18 +212,45 @@ func (r runner) Find(ctx context.Context, name string) (tish.Command, error) {
 }
 
 func (r runner) Run(ctx context.Context) error {
-	x, err := r.cmd.Prepare(tish.WithFinder(r))
-	if err != nil {
-		return nil
-	}
-	x.SetOut(r.out)
-	x.SetErr(r.err)
-	err = x.Execute(ctx, r.args)
+	err := r.execute(ctx)
 	if err != nil {
 		fmt.Fprintf(r.err, "[%s] %s", r.cmd.Command(), err)
 		fmt.Fprintln(r.err)
 	}
-	return nil
+	return err
+}
+
+// execute turns a panic into an error so that it is handled like any other
+// failure of the run (notifications, backoff) instead of stopping the schedule
+func (r runner) execute(ctx context.Context) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &schedule.PanicError{
+				Value: v,
+				Stack: debug.Stack(),
+			}
+		}
+	}()
+	if err := r.cmd.envRequired(); err != nil {
+		return err
+	}
+	x, err := r.cmd.Prepare(tish.WithFinder(r))
+	if err != nil {
+		return err
+	}
+	r.failures.inject(x, r.cmd.Command())
+	x = limitExecuter(r.cmd, x, r.limits)
+	if tail, ok := ctx.Value(tailKey{}).(*tailBuffer); ok {
+		x.SetOut(io.MultiWriter(r.out, tail))
+		x.SetErr(io.MultiWriter(r.err, tail))
+	} else {
+		x.SetOut(r.out)
+		x.SetErr(r.err)
+	}
+	if r.runs != nil {
+		ctx = r.runs
+	}
+	return x.Execute(ctx, r.args)
 }
 
 func (r runner) Close() error {
@@ -156,6 +263,28 @@ func (r runner) Close() error {
 	return nil
 }
 
+// graceContext returns the context given to the running commands of the
+// schedules. It is only cancelled once grace has elapsed after ctx is done so
+// that the runs in progress can end when the schedules are stopped
+func graceContext(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	runs, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-runs.Done():
+			return
+		}
+		t := time.NewTimer(grace)
+		defer t.Stop()
+		select {
+		case <-t.C:
+			cancel()
+		case <-runs.Done():
+		}
+	}()
+	return runs, cancel
+}
+
 func writePrefix(w io.Writer, prefix string) io.Writer {
 	pr, pw, _ := os.Pipe()
 	go func() {
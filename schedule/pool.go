@@ -0,0 +1,126 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+const (
+	DefaultWorkers = 16
+	DefaultQueue   = 16
+)
+
+var (
+	ErrQueueFull = errors.New("queue full")
+	ErrClosed    = errors.New("pool closed")
+)
+
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+func Recover(r Runner) Runner {
+	return &recoverRunner{
+		Runner: r,
+	}
+}
+
+type recoverRunner struct {
+	Runner
+}
+
+func (r *recoverRunner) Run(ctx context.Context) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{
+				Value: v,
+				Stack: debug.Stack(),
+			}
+		}
+	}()
+	return r.Runner.Run(ctx)
+}
+
+type job struct {
+	ctx  context.Context
+	run  Runner
+	done func(error)
+}
+
+type Pool struct {
+	mu     sync.RWMutex
+	closed bool
+	queue  chan job
+	wg     sync.WaitGroup
+}
+
+func NewPool(workers, queue int) *Pool {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	if queue < 0 {
+		queue = 0
+	}
+	p := Pool{
+		queue: make(chan job, queue),
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return &p
+}
+
+func (p *Pool) Go(ctx context.Context, r Runner, done func(error)) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrClosed
+	}
+	j := job{
+		ctx:  ctx,
+		run:  Recover(r),
+		done: done,
+	}
+	select {
+	case p.queue <- j:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrClosed
+	}
+	p.closed = true
+	close(p.queue)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	return nil
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for j := range p.queue {
+		err := j.ctx.Err()
+		if err == nil {
+			err = j.run.Run(j.ctx)
+		}
+		if j.done != nil {
+			j.done(err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package schedule_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/midbel/maestro/schedule"
+)
+
+type blockRunner struct {
+	running int32
+	max     int32
+	release chan struct{}
+}
+
+func (b *blockRunner) Run(_ context.Context) error {
+	n := atomic.AddInt32(&b.running, 1)
+	for {
+		m := atomic.LoadInt32(&b.max)
+		if n <= m || atomic.CompareAndSwapInt32(&b.max, m, n) {
+			break
+		}
+	}
+	<-b.release
+	atomic.AddInt32(&b.running, -1)
+	return nil
+}
+
+type panicRunner struct{}
+
+func (panicRunner) Run(_ context.Context) error {
+	panic("boom")
+}
+
+func TestPoolBounded(t *testing.T) {
+	var (
+		b = blockRunner{release: make(chan struct{})}
+		p = schedule.NewPool(2, 2)
+		w sync.WaitGroup
+	)
+	done := func(_ error) {
+		w.Done()
+	}
+	for i := 0; i < 4; i++ {
+		w.Add(1)
+		for {
+			err := p.Go(context.TODO(), &b, done)
+			if err == nil {
+				break
+			}
+			if !errors.Is(err, schedule.ErrQueueFull) {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := p.Go(context.TODO(), &b, nil); !errors.Is(err, schedule.ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	close(b.release)
+	w.Wait()
+	if b.max != 2 {
+		t.Errorf("concurrent runs mismatched! want 2, got %d", b.max)
+	}
+	p.Close()
+	if err := p.Go(context.TODO(), &b, nil); !errors.Is(err, schedule.ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestPoolRecover(t *testing.T) {
+	var (
+		p   = schedule.NewPool(1, 0)
+		res = make(chan error, 1)
+	)
+	defer p.Close()
+
+	var fn panicRunner
+	done := func(err error) {
+		res <- err
+	}
+	for {
+		err := p.Go(context.TODO(), fn, done)
+		if err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	var pe *schedule.PanicError
+	if err := <-res; !errors.As(err, &pe) {
+		t.Fatalf("expected PanicError, got %v", err)
+	}
+	if pe.Value != "boom" || len(pe.Stack) == 0 {
+		t.Errorf("panic not captured properly: %v", pe.Value)
+	}
+}
+
+func TestRunPoolRecover(t *testing.T) {
+	sched, err := schedule.Every(5 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("fail to create scheduler: %s", err)
+	}
+	var (
+		count int32
+		fn    = func(_ context.Context) error {
+			atomic.AddInt32(&count, 1)
+			panic("boom")
+		}
+		pool = schedule.NewPool(1, 1)
+	)
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+
+	r := schedule.IgnoreErrors(schedule.Recover(funcRunner(fn)))
+	if err := sched.RunPool(ctx, r, pool); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("schedule should only stop with its context, got %v", err)
+	}
+	if n := atomic.LoadInt32(&count); n < 2 {
+		t.Errorf("schedule stopped after a panic! got %d run(s)", n)
+	}
+}
+
+type funcRunner func(context.Context) error
+
+func (f funcRunner) Run(ctx context.Context) error {
+	return f(ctx)
+}
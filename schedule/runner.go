@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"log"
+	"math/rand"
 	"sync"
 	"time"
 )
@@ -57,6 +58,36 @@ func DelayRunner(r Runner, wait time.Duration) Runner {
 	}
 }
 
+func JitterRunner(r Runner, max time.Duration) Runner {
+	return &jitterRunner{
+		max:    max,
+		Runner: r,
+	}
+}
+
+func MaxRuns(r Runner, max int) Runner {
+	return &maxRunner{
+		limit:  max,
+		Runner: r,
+	}
+}
+
+func Backoff(r Runner, wait time.Duration) Runner {
+	return &backoffRunner{
+		wait:   wait,
+		Runner: r,
+	}
+}
+
+func IgnoreErrors(r Runner) Runner {
+	return DoAfter(r, func(err error) error {
+		if errors.Is(err, ErrDone) {
+			return err
+		}
+		return nil
+	})
+}
+
 type runFunc func(context.Context) error
 
 func (r runFunc) Run(ctx context.Context) error {
@@ -134,6 +165,100 @@ func (r *delayRunner) Run(ctx context.Context) error {
 	return r.Runner.Run(ctx)
 }
 
+type jitterRunner struct {
+	max time.Duration
+	Runner
+}
+
+func (r *jitterRunner) Run(ctx context.Context) error {
+	if r.max > 0 {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Duration(rand.Int63n(int64(r.max)))):
+		}
+	}
+	return r.Runner.Run(ctx)
+}
+
+type maxRunner struct {
+	mu    sync.Mutex
+	limit int
+	count int
+	Runner
+}
+
+func (r *maxRunner) Run(ctx context.Context) error {
+	if !r.inc() {
+		return ErrDone
+	}
+	err := r.Runner.Run(ctx)
+	if r.done() {
+		err = ErrDone
+	}
+	return err
+}
+
+func (r *maxRunner) inc() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.count >= r.limit {
+		return false
+	}
+	r.count++
+	return true
+}
+
+func (r *maxRunner) done() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.count >= r.limit
+}
+
+const maxBackoffShift = 6
+
+type backoffRunner struct {
+	mu       sync.Mutex
+	wait     time.Duration
+	failures int
+	until    time.Time
+	Runner
+}
+
+func (r *backoffRunner) Run(ctx context.Context) error {
+	if r.waiting(time.Now()) {
+		return nil
+	}
+	err := r.Runner.Run(ctx)
+	if errors.Is(err, ErrDone) {
+		return err
+	}
+	r.update(err, time.Now())
+	return nil
+}
+
+func (r *backoffRunner) waiting(now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return now.Before(r.until)
+}
+
+func (r *backoffRunner) update(err error, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil {
+		r.failures = 0
+		r.until = time.Time{}
+		return
+	}
+	shift := r.failures
+	if shift > maxBackoffShift {
+		shift = maxBackoffShift
+	}
+	r.failures++
+	r.until = now.Add(r.wait << shift)
+}
+
 type timeoutRunner struct {
 	timeout time.Duration
 	Runner
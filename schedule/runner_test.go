@@ -0,0 +1,64 @@
+package schedule_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/midbel/maestro/schedule"
+)
+
+type countRunner struct {
+	count int
+	err   error
+}
+
+func (c *countRunner) Run(_ context.Context) error {
+	c.count++
+	return c.err
+}
+
+func TestMaxRuns(t *testing.T) {
+	var (
+		c = countRunner{}
+		r = schedule.MaxRuns(&c, 2)
+	)
+	if err := r.Run(context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := r.Run(context.TODO()); !errors.Is(err, schedule.ErrDone) {
+		t.Fatalf("expected ErrDone after last run, got %v", err)
+	}
+	if err := r.Run(context.TODO()); !errors.Is(err, schedule.ErrDone) {
+		t.Fatalf("expected ErrDone, got %v", err)
+	}
+	if c.count != 2 {
+		t.Errorf("runs mismatched! want 2, got %d", c.count)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	var (
+		c = countRunner{err: errors.New("fail")}
+		r = schedule.Backoff(&c, 50*time.Millisecond)
+	)
+	for i := 0; i < 3; i++ {
+		if err := r.Run(context.TODO()); err != nil {
+			t.Fatalf("backoff should not return error, got %s", err)
+		}
+	}
+	if c.count != 1 {
+		t.Fatalf("runs mismatched during backoff! want 1, got %d", c.count)
+	}
+	time.Sleep(60 * time.Millisecond)
+	r.Run(context.TODO())
+	if c.count != 2 {
+		t.Fatalf("runs mismatched after backoff! want 2, got %d", c.count)
+	}
+	time.Sleep(60 * time.Millisecond)
+	r.Run(context.TODO())
+	if c.count != 2 {
+		t.Fatalf("backoff should have been doubled! want 2, got %d", c.count)
+	}
+}
@@ -4,28 +4,69 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
-
-	"golang.org/x/sync/errgroup"
 )
 
 var Separator = ";"
 
 type Scheduler struct {
+	sec   Ticker
 	min   Ticker
 	hour  Ticker
 	day   Ticker
 	month Ticker
 	week  Ticker
 
-	when time.Time
+	seconds bool
+	every   time.Duration
+	reboot  bool
+	when    time.Time
+}
+
+var shorthands = map[string][]string{
+	"@hourly":   {"0", "*", "*", "*", "*"},
+	"@daily":    {"0", "0", "*", "*", "*"},
+	"@midnight": {"0", "0", "*", "*", "*"},
+	"@weekly":   {"0", "0", "*", "*", "7"},
+	"@monthly":  {"0", "0", "1", "*", "*"},
+	"@yearly":   {"0", "0", "1", "1", "*"},
+	"@annually": {"0", "0", "1", "1", "*"},
 }
 
+const (
+	every  = "@every"
+	reboot = "@reboot"
+)
+
 func ScheduleFromList(ls []string) (*Scheduler, error) {
-	if len(ls) != 5 {
-		return nil, fmt.Errorf("schedule: not enough argument given! expected 5, got %d", len(ls))
+	if len(ls) > 0 && strings.HasPrefix(ls[0], "@") {
+		return scheduleFromShorthand(strings.Fields(strings.Join(ls, " ")))
+	}
+	switch len(ls) {
+	case 5:
+		return Schedule(ls[0], ls[1], ls[2], ls[3], ls[4])
+	case 6:
+		return ScheduleWithSeconds(ls[0], ls[1], ls[2], ls[3], ls[4], ls[5])
+	default:
+		return nil, fmt.Errorf("schedule: not enough argument given! expected 5 or 6, got %d", len(ls))
+	}
+}
+
+func ScheduleWithSeconds(sec, min, hour, day, month, week string) (*Scheduler, error) {
+	tick, err := Parse(sec, 0, 59, nil)
+	if err != nil {
+		return nil, err
+	}
+	sched, err := Schedule(min, hour, day, month, week)
+	if err != nil {
+		return nil, err
 	}
-	return Schedule(ls[0], ls[1], ls[2], ls[3], ls[4])
+	sched.sec = tick
+	sched.seconds = true
+	sched.Reset(time.Now().Local())
+	return sched, nil
 }
 
 func Schedule(min, hour, day, month, week string) (*Scheduler, error) {
@@ -43,6 +84,7 @@ func Schedule(min, hour, day, month, week string) (*Scheduler, error) {
 	sched.day, err3 = Parse(day, 1, 31, nil)
 	sched.month, err4 = Parse(month, 1, 12, monthnames)
 	sched.week, err5 = Parse(week, 1, 7, daynames)
+	sched.sec = Single(0, 0, 59)
 
 	if err := hasError(err1, err2, err3, err4, err5); err != nil {
 		return nil, err
@@ -51,41 +93,163 @@ func Schedule(min, hour, day, month, week string) (*Scheduler, error) {
 	return &sched, nil
 }
 
+func Every(d time.Duration) (*Scheduler, error) {
+	if d <= 0 {
+		return nil, fmt.Errorf("schedule: interval should be greater than zero (got %s)", d)
+	}
+	sched := Scheduler{
+		every: d,
+	}
+	sched.Reset(time.Now().Local())
+	return &sched, nil
+}
+
+// Reboot returns a scheduler running only once, as soon as it is started.
+func Reboot() *Scheduler {
+	sched := Scheduler{
+		reboot: true,
+	}
+	sched.Reset(time.Now().Local())
+	return &sched
+}
+
+func scheduleFromShorthand(ls []string) (*Scheduler, error) {
+	if ls[0] == every {
+		if len(ls) != 2 {
+			return nil, fmt.Errorf("schedule: %s expects a single duration", every)
+		}
+		d, err := time.ParseDuration(ls[1])
+		if err != nil {
+			return nil, fmt.Errorf("schedule: %w", err)
+		}
+		return Every(d)
+	}
+	if len(ls) != 1 {
+		return nil, fmt.Errorf("schedule: %s does not expect argument", ls[0])
+	}
+	if ls[0] == reboot {
+		return Reboot(), nil
+	}
+	tab, ok := shorthands[ls[0]]
+	if !ok {
+		return nil, fmt.Errorf("schedule: %s: unknown shorthand", ls[0])
+	}
+	return ScheduleFromList(tab)
+}
+
 func (s *Scheduler) RunFunc(ctx context.Context, fn func(context.Context) error) error {
 	return s.Run(ctx, runFunc(fn))
 }
 
 func (s *Scheduler) Run(ctx context.Context, r Runner) error {
-	var grp *errgroup.Group
-	grp, ctx = errgroup.WithContext(ctx)
+	pool := NewPool(DefaultWorkers, DefaultQueue)
+	defer pool.Close()
+	return s.RunPool(ctx, r, pool)
+}
+
+func (s *Scheduler) RunPool(ctx context.Context, r Runner, pool *Pool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if s.reboot {
+		s.Reset(time.Now().Local())
+	}
+
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+		fail error
+	)
+	done := func(err error) {
+		defer wg.Done()
+		if err == nil {
+			return
+		}
+		once.Do(func() {
+			fail = err
+			cancel()
+		})
+	}
 	for now := time.Now(); ; now = time.Now() {
 		var (
 			next = s.Next()
 			wait = next.Sub(now)
+			tick <-chan time.Time
 		)
-		if wait <= 0 {
+		switch {
+		case next.IsZero():
+			wg.Wait()
+			once.Do(func() {
+				fail = ErrDone
+			})
+			cancel()
+		case wait <= 0 && !s.reboot:
 			continue
+		default:
+			tick = time.After(wait)
 		}
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(wait):
+			wg.Wait()
+			if errors.Is(fail, ErrDone) {
+				return nil
+			}
+			if fail == nil {
+				fail = ctx.Err()
+			}
+			return fail
+		case <-tick:
+		}
+		wg.Add(1)
+		if err := pool.Go(ctx, r, done); err != nil {
+			wg.Done()
+			if errors.Is(err, ErrClosed) {
+				cancel()
+				wg.Wait()
+				return err
+			}
 		}
-		grp.Go(func() error {
-			return r.Run(ctx)
-		})
-	}
-	err := grp.Wait()
-	if errors.Is(err, ErrDone) {
-		err = nil
 	}
-	return err
 }
 
 // func (s *Scheduler) Stop() {
 // 	// TODO
 // }
 
+type Spec struct {
+	Reboot   bool
+	Every    time.Duration
+	Seconds  []int
+	Minutes  []int
+	Hours    []int
+	Days     []int
+	Months   []int
+	Weekdays []time.Weekday
+}
+
+func (s *Scheduler) Spec() Spec {
+	if s.reboot {
+		return Spec{Reboot: true}
+	}
+	if s.every > 0 {
+		return Spec{Every: s.every}
+	}
+	spec := Spec{
+		Seconds: s.sec.values(),
+		Minutes: s.min.values(),
+		Hours:   s.hour.values(),
+		Days:    s.day.values(),
+		Months:  s.month.values(),
+	}
+	if !s.seconds {
+		spec.Seconds = []int{0}
+	}
+	for _, w := range s.week.values() {
+		spec.Weekdays = append(spec.Weekdays, getWeekday(w))
+	}
+	return spec
+}
+
 func (s *Scheduler) Now() time.Time {
 	return s.when
 }
@@ -96,6 +260,15 @@ func (s *Scheduler) Next() time.Time {
 }
 
 func (s *Scheduler) Reset(when time.Time) {
+	if s.reboot {
+		s.when = when.Truncate(time.Second)
+		return
+	}
+	if s.every > 0 {
+		s.when = when.Truncate(time.Second).Add(s.every)
+		return
+	}
+	s.sec.reset()
 	s.min.reset()
 	s.hour.reset()
 	s.day = unfreeze(s.day)
@@ -104,13 +277,26 @@ func (s *Scheduler) Reset(when time.Time) {
 	s.month.reset()
 	s.week.reset()
 
-	s.when = when.Truncate(time.Minute)
+	if s.seconds {
+		s.when = when.Truncate(time.Second)
+	} else {
+		s.when = when.Truncate(time.Minute)
+	}
 	s.alignDayOfWeek()
 	s.reset()
 }
 
 func (s *Scheduler) next() time.Time {
+	if s.reboot {
+		s.when = time.Time{}
+		return s.when
+	}
+	if s.every > 0 {
+		s.when = s.when.Add(s.every)
+		return s.when
+	}
 	list := []Ticker{
+		s.sec,
 		s.min,
 		s.hour,
 		s.day,
@@ -127,13 +313,17 @@ func (s *Scheduler) next() time.Time {
 		return s.next()
 	}
 	when = s.adjustNextTime(when)
-	if when.Before(s.when) {
+	if when.Before(s.when) || (when.Equal(s.when) && s.fixed()) {
 		when = when.AddDate(1, 0, 0)
 	}
 	s.when = when
 	return s.when
 }
 
+func (s *Scheduler) fixed() bool {
+	return s.sec.one() && s.min.one() && s.hour.one() && s.day.one() && s.month.one()
+}
+
 func (s *Scheduler) adjustNextTime(when time.Time) time.Time {
 	if s.day.All() && !s.week.All() {
 		return s.adjustByWeekday(when)
@@ -201,6 +391,7 @@ func (s *Scheduler) get() (time.Time, bool) {
 		day   = s.day.Curr()
 		hour  = s.hour.Curr()
 		min   = s.min.Curr()
+		sec   = s.sec.Curr()
 	)
 	n := days[month-1]
 	if month == 2 && isLeap(year) {
@@ -209,7 +400,7 @@ func (s *Scheduler) get() (time.Time, bool) {
 	if day > n {
 		return s.when, false
 	}
-	return time.Date(year, month, day, hour, min, 0, 0, s.when.Location()), true
+	return time.Date(year, month, day, hour, min, sec, 0, s.when.Location()), true
 }
 
 func (s *Scheduler) alignDayOfWeek() {
@@ -1,6 +1,8 @@
 package schedule_test
 
 import (
+	"context"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -213,6 +215,203 @@ func TestScheduler(t *testing.T) {
 	}
 }
 
+func TestSchedulerSeconds(t *testing.T) {
+	data := []struct {
+		Tab  []string
+		Want []time.Time
+	}{
+		{
+			Tab: []string{"*/15", "*", "*", "*", "*", "*"},
+			Want: []time.Time{
+				parseTime("2022-02-12 14:50:45"),
+				parseTime("2022-02-12 14:51:00"),
+				parseTime("2022-02-12 14:51:15"),
+				parseTime("2022-02-12 14:51:30"),
+			},
+		},
+		{
+			Tab: []string{"30", "10", "*", "*", "*", "*"},
+			Want: []time.Time{
+				parseTime("2022-02-12 15:10:30"),
+				parseTime("2022-02-12 16:10:30"),
+				parseTime("2022-02-12 17:10:30"),
+			},
+		},
+		{
+			Tab: []string{"0;20", "0", "0", "1", "1", "*"},
+			Want: []time.Time{
+				parseTime("2023-01-01 00:00:00"),
+				parseTime("2023-01-01 00:00:20"),
+				parseTime("2024-01-01 00:00:00"),
+			},
+		},
+	}
+	for _, d := range data {
+		name := strings.Join(d.Tab, " ")
+		t.Run(name, func(t *testing.T) {
+			sched, err := schedule.ScheduleFromList(d.Tab)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			sched.Reset(today)
+			for j, want := range d.Want {
+				got := sched.Next()
+				if !want.Equal(got) {
+					t.Fatalf("time mismatched at %d! want %s, got %s", j+1, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSchedulerShorthand(t *testing.T) {
+	data := []struct {
+		Tab  []string
+		Want []time.Time
+	}{
+		{
+			Tab: []string{"@hourly"},
+			Want: []time.Time{
+				parseTime("2022-02-12 15:00:00"),
+				parseTime("2022-02-12 16:00:00"),
+			},
+		},
+		{
+			Tab: []string{"@daily"},
+			Want: []time.Time{
+				parseTime("2022-02-13 00:00:00"),
+				parseTime("2022-02-14 00:00:00"),
+			},
+		},
+		{
+			Tab: []string{"@monthly"},
+			Want: []time.Time{
+				parseTime("2022-03-01 00:00:00"),
+				parseTime("2022-04-01 00:00:00"),
+			},
+		},
+		{
+			Tab: []string{"@yearly"},
+			Want: []time.Time{
+				parseTime("2023-01-01 00:00:00"),
+				parseTime("2024-01-01 00:00:00"),
+			},
+		},
+		{
+			Tab: []string{"@every", "15m"},
+			Want: []time.Time{
+				parseTime("2022-02-12 15:05:45"),
+				parseTime("2022-02-12 15:20:45"),
+				parseTime("2022-02-12 15:35:45"),
+			},
+		},
+	}
+	for _, d := range data {
+		name := strings.Join(d.Tab, " ")
+		t.Run(name, func(t *testing.T) {
+			sched, err := schedule.ScheduleFromList(d.Tab)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			sched.Reset(today)
+			for j, want := range d.Want {
+				got := sched.Next()
+				if !want.Equal(got) {
+					t.Fatalf("time mismatched at %d! want %s, got %s", j+1, want, got)
+				}
+			}
+		})
+	}
+	for _, tab := range [][]string{{"@reboot", "now"}, {"@every"}, {"@every", "0s"}, {"@daily", "*"}} {
+		if _, err := schedule.ScheduleFromList(tab); err == nil {
+			t.Errorf("%s: expected error", strings.Join(tab, " "))
+		}
+	}
+}
+
+func TestSchedulerSpec(t *testing.T) {
+	data := []struct {
+		Tab  []string
+		Want schedule.Spec
+	}{
+		{
+			Tab: []string{"*/15", "8-10", "*", "*", "mon;fri"},
+			Want: schedule.Spec{
+				Seconds:  []int{0},
+				Minutes:  []int{0, 15, 30, 45},
+				Hours:    []int{8, 9, 10},
+				Weekdays: []time.Weekday{time.Monday, time.Friday},
+			},
+		},
+		{
+			Tab: []string{"@monthly"},
+			Want: schedule.Spec{
+				Seconds: []int{0},
+				Minutes: []int{0},
+				Hours:   []int{0},
+				Days:    []int{1},
+			},
+		},
+		{
+			Tab: []string{"@weekly"},
+			Want: schedule.Spec{
+				Seconds:  []int{0},
+				Minutes:  []int{0},
+				Hours:    []int{0},
+				Weekdays: []time.Weekday{time.Sunday},
+			},
+		},
+		{
+			Tab:  []string{"@every", "90m"},
+			Want: schedule.Spec{Every: 90 * time.Minute},
+		},
+		{
+			Tab:  []string{"@reboot"},
+			Want: schedule.Spec{Reboot: true},
+		},
+	}
+	for _, d := range data {
+		name := strings.Join(d.Tab, " ")
+		t.Run(name, func(t *testing.T) {
+			sched, err := schedule.ScheduleFromList(d.Tab)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			got := sched.Spec()
+			if !reflect.DeepEqual(d.Want, got) {
+				t.Fatalf("spec mismatched! want %+v, got %+v", d.Want, got)
+			}
+		})
+	}
+}
+
+func TestSchedulerReboot(t *testing.T) {
+	sched, err := schedule.ScheduleFromList([]string{"@reboot"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+	defer cancel()
+
+	var count int
+	err = sched.RunFunc(ctx, func(_ context.Context) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("schedule should stop after its only run, got %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Errorf("schedule should not wait for its context to be done")
+	}
+	if count != 1 {
+		t.Errorf("runs mismatched! want 1, got %d", count)
+	}
+	if next := sched.Next(); !next.IsZero() {
+		t.Errorf("no more run expected, got %s", next)
+	}
+}
+
 func parseTime(str string) time.Time {
 	w, _ := time.Parse("2006-01-02 15:04:05", str)
 	return w
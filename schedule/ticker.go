@@ -3,6 +3,7 @@ package schedule
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -15,6 +16,7 @@ type Ticker interface {
 	one() bool
 	reset()
 	isReset() bool
+	values() []int
 	All() bool
 }
 
@@ -91,6 +93,20 @@ func (s *single) Next() {
 	}
 }
 
+func (s *single) values() []int {
+	if s.all && s.step <= 1 {
+		return nil
+	}
+	if s.step == 0 {
+		return []int{s.base}
+	}
+	var vs []int
+	for i := s.base; i <= s.upper; i += s.step {
+		vs = append(vs, i)
+	}
+	return vs
+}
+
 func (s *single) By(by int) {
 	s.step = by
 }
@@ -148,6 +164,18 @@ func (i *interval) Next() {
 	}
 }
 
+func (i *interval) values() []int {
+	step := i.step
+	if step <= 0 {
+		step = 1
+	}
+	var vs []int
+	for j := i.min; j <= i.max; j += step {
+		vs = append(vs, j)
+	}
+	return vs
+}
+
 func (i *interval) By(by int) {
 	i.step = by
 }
@@ -192,6 +220,28 @@ func (i *list) Next() {
 	}
 }
 
+func (i *list) values() []int {
+	var (
+		seen = make(map[int]struct{})
+		vs   []int
+	)
+	for j := range i.es {
+		es := i.es[j].values()
+		if es == nil {
+			return nil
+		}
+		for _, v := range es {
+			if _, ok := seen[v]; ok {
+				continue
+			}
+			seen[v] = struct{}{}
+			vs = append(vs, v)
+		}
+	}
+	sort.Ints(vs)
+	return vs
+}
+
 func (i *list) By(s int) {
 	for j := range i.es {
 		i.es[j].By(s)
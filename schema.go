@@ -0,0 +1,280 @@
+package maestro
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+)
+
+const schemaVersion = "https://json-schema.org/draft/2020-12/schema"
+
+type schema struct {
+	Schema     string             `json:"$schema,omitempty"`
+	Title      string             `json:"title,omitempty"`
+	Desc       string             `json:"description,omitempty"`
+	Ref        string             `json:"$ref,omitempty"`
+	Type       string             `json:"type,omitempty"`
+	Format     string             `json:"format,omitempty"`
+	Enum       []string           `json:"enum,omitempty"`
+	Minimum    *float64           `json:"minimum,omitempty"`
+	Items      *schema            `json:"items,omitempty"`
+	OneOf      []*schema          `json:"oneOf,omitempty"`
+	Properties map[string]*schema `json:"properties,omitempty"`
+	Additional interface{}        `json:"additionalProperties,omitempty"`
+	Defs       map[string]*schema `json:"$defs,omitempty"`
+}
+
+func schemaString(desc string) *schema {
+	return &schema{Type: "string", Desc: desc}
+}
+
+func schemaInt(desc string) *schema {
+	return &schema{Type: "integer", Desc: desc}
+}
+
+func schemaNumber(desc string, min float64) *schema {
+	return &schema{Type: "number", Desc: desc, Minimum: &min}
+}
+
+func schemaBool(desc string) *schema {
+	return &schema{Type: "boolean", Desc: desc}
+}
+
+func schemaDuration(desc string) *schema {
+	return &schema{Type: "string", Format: "duration", Desc: desc}
+}
+
+func schemaEnum(desc string, values ...string) *schema {
+	return &schema{Type: "string", Desc: desc, Enum: values}
+}
+
+func schemaList(desc string) *schema {
+	return &schema{
+		Type:  "array",
+		Desc:  desc,
+		Items: &schema{Type: "string"},
+	}
+}
+
+func schemaRef(desc, def string) *schema {
+	return &schema{Desc: desc, Ref: "#/$defs/" + def}
+}
+
+func schemaRefList(desc, def string) *schema {
+	return &schema{
+		Desc: desc,
+		OneOf: []*schema{
+			{Ref: "#/$defs/" + def},
+			{Type: "array", Items: &schema{Ref: "#/$defs/" + def}},
+		},
+	}
+}
+
+func schemaObject(desc string, props map[string]*schema) *schema {
+	return &schema{
+		Type:       "object",
+		Desc:       desc,
+		Properties: props,
+		Additional: false,
+	}
+}
+
+var metaSchema = map[string]*schema{
+	metaNamespace:  schemaString("namespace of the commands of the file"),
+	metaWorkDir:    schemaString("working directory of maestro"),
+	metaTrace:      schemaBool("enable tracing information"),
+	metaHistory:    schemaString("file where the executions are recorded"),
+	metaAudit:      schemaString("file (or syslog) where the executions are audited"),
+	metaCache:      schemaString("file where the hashes of the sources are recorded"),
+	metaSpill:      schemaString("size (eg: 64M) of the output kept in memory before being written to a temporary file"),
+	metaWorkers:    schemaInt("maximum number of scheduled runs executed concurrently"),
+	metaQueue:      schemaInt("number of scheduled runs waiting for a free worker"),
+	metaGrace:      schemaDuration("time given to the running scheduled commands to end when the schedules are stopped"),
+	metaExport:     schemaList("patterns selecting the environment variables given to the commands"),
+	metaIgnore:     schemaList("files excluding paths from the features working on files"),
+	metaInclude:    schemaList("directories where included files are searched"),
+	metaEnvFile:    schemaList("dotenv files loaded into the environment of all the commands"),
+	metaAll:        schemaList("commands executed by maestro all"),
+	metaDefault:    schemaString("command executed by maestro default"),
+	metaBefore:     schemaList("commands executed before the called command"),
+	metaAfter:      schemaList("commands executed after the called command"),
+	metaError:      schemaList("commands executed when the called command fails"),
+	metaSuccess:    schemaList("commands executed when the called command succeeds"),
+	metaAuthor:     schemaString("author of the maestro file"),
+	metaEmail:      schemaString("e-mail of the author of the maestro file"),
+	metaVersion:    schemaString("version of the maestro file"),
+	metaUsage:      schemaString("short help message of the maestro file"),
+	metaHelp:       schemaString("longer description of the maestro file"),
+	metaUser:       schemaString("username used to connect to remote servers"),
+	metaPass:       schemaString("password used to connect to remote servers"),
+	metaPubKey:     schemaString("key file used to connect to remote servers"),
+	metaKnownHosts: schemaString("known_hosts file used to validate remote servers"),
+	metaParallel:   schemaInt("number of remote servers a command is executed on simultaneously"),
+	metaCertFile:   schemaString("certificate file of the HTTP server"),
+	metaKeyFile:    schemaString("key file of the HTTP server"),
+	metaSecret:     schemaString("secret used to verify the signature of webhooks"),
+	metaTokens:     schemaRefList("tokens accepted by the HTTP server", "token"),
+	metaProxies:    schemaList("addresses of the proxies trusted to set X-Forwarded-For"),
+	metaSmtpHost:   schemaString("SMTP server (host:port) used to send notifications"),
+	metaSmtpUser:   schemaString("username of the SMTP server"),
+	metaSmtpPass:   schemaString("password of the SMTP server"),
+	metaSmtpFrom:   schemaString("sender of the notifications sent by email"),
+}
+
+var commandSchema = map[string]*schema{
+	propShort:    schemaString("short description of the command"),
+	propHelp:     schemaString("longer description of the command"),
+	propTags:     schemaList("tags of the command"),
+	propAlias:    schemaList("alternative names of the command, optionally with preset options"),
+	propRetry:    schemaInt("number of attempts to run the command"),
+	propDelay:    schemaDuration("duration to wait before retrying the command"),
+	propBackoff:  schemaNumber("multiplier applied to the delay after each failed attempt", 1),
+	propMaxDelay: schemaDuration("upper bound of the delay between two attempts"),
+	propWorkDir:  schemaString("working directory of the script"),
+	propUmask:    schemaString("file mode creation mask (octal) of the script"),
+	propUser:     schemaString("user running the programs called by the script"),
+	propTimeout:  schemaDuration("maximum time given to the command to complete"),
+	propEval:     schemaEnum("when command substitutions of the properties are evaluated", evalEager, evalLazy),
+	propHosts:    schemaList("remote servers where the command is executed"),
+	propOpts:     schemaRefList("options accepted by the command", "option"),
+	propArg:      schemaList("arguments required by the command"),
+	propSchedule: schemaRefList("when the command is executed by maestro schedule", "schedule"),
+	propConfirm:  schemaBool("ask for confirmation before executing the command"),
+	propNeedsEnv: schemaList("environment variables that should be set (non empty) to execute the command"),
+	propBlackout: schemaList("windows (days of week like sat,sun, dates or ranges of dates like 2024-12-24..2024-12-26) during which the command is not executed without --force"),
+	propCost:     schemaNumber("estimated cost of the command checked against --budget", 0),
+	propSandbox:  schemaBool("execute the command in new mount, PID and network namespaces (linux only)"),
+	propNoPrivs:  schemaBool("execute the programs of the script with no_new_privs set (linux only)"),
+	propSeccomp:  schemaList("syscalls (or default) denied to the programs of the script (linux only)"),
+	propInherit:  schemaBool("give the full environment of maestro to the command"),
+	propExpect:   schemaRef("expected result of the command when running maestro test", "expect"),
+	propStdin:    schemaString("file or heredoc given to the standard input of the script"),
+	propMaxConc:  schemaInt("maximum number of instances of the command running at the same time"),
+	propPolicy:   schemaEnum("what to do when max_concurrent is reached", PolicyQueue, PolicyReject),
+	propRate:     schemaInt("maximum number of executions per minute via the HTTP server"),
+	propDebounce: schemaDuration("wait for this duration without new requests before executing"),
+	propSecret:   schemaString("secret used to verify the signature of webhooks"),
+	propHttpMap:  {Type: "object", Desc: "fields of the request body mapped to options and arguments", Additional: schemaString("")},
+	propEnvFile:  schemaList("dotenv files loaded into the environment of the command"),
+	propSources:  schemaList("patterns of the files used by the command"),
+	propTargets:  schemaList("patterns of the files produced by the command"),
+	propUpload:   schemaList("files (local:remote) copied to the remote servers"),
+	propDownload: schemaList("files (remote:local) copied from the remote servers"),
+}
+
+var optionSchema = map[string]*schema{
+	optShort:    schemaString("short name of the option"),
+	optLong:     schemaString("long name of the option"),
+	optRequired: schemaBool("the option should be given"),
+	optDefault:  schemaString("default value of the option"),
+	optFlag:     schemaBool("the option does not expect a value"),
+	optList:     schemaBool("the option can be given multiple times"),
+	optHelp:     schemaString("description of the option"),
+	optValid:    schemaString("validation rules of the value of the option"),
+	optExcl:     schemaString("group of options that can not be given together"),
+}
+
+var scheduleSchema = map[string]*schema{
+	schedTime:           schemaString("crontab like specification or shorthand (@daily, @every 15m,...)"),
+	schedOverlap:        schemaBool("a new run can start while the previous one is still running"),
+	schedNotify:         schemaList("targets notified when a run is done"),
+	schedNotifyOn:       schemaEnum("when targets are notified", NotifyFailure, NotifySuccess, NotifyAlways),
+	schedNotifyTemplate: schemaString("template of the notification message"),
+	schedArgs:           schemaList("arguments given to the command"),
+	schedOut:            schemaRef("where the output of the command is written", "redirect"),
+	schedErr:            schemaRef("where the errors of the command are written", "redirect"),
+	schedJitter:         schemaDuration("maximum random delay added before each run"),
+	schedMaxRuns:        schemaInt("number of runs after which the schedule stops"),
+	schedBackoff:        schemaDuration("delay during which runs are skipped after a failure"),
+	schedBlackout:       schemaList("windows (days of week, dates or ranges of dates) during which runs are skipped"),
+}
+
+var redirectSchema = map[string]*schema{
+	schedRedirectFile:      schemaString("file where the output is written"),
+	schedRedirectCompress:  schemaBool("compress the file"),
+	schedRedirectDuplicate: schemaBool("also write the output to the standard output of maestro"),
+	schedRedirectOverwrite: schemaBool("truncate the file before each run"),
+}
+
+var hostSchema = map[string]*schema{
+	hostAddr:     schemaString("address of the remote server"),
+	hostPort:     schemaInt("SSH port of the remote server"),
+	hostUser:     schemaString("username used to connect to the remote server"),
+	hostPass:     schemaString("password used to connect to the remote server"),
+	hostIdentity: schemaString("private key file used to connect to the remote server"),
+}
+
+var tokenSchema = map[string]*schema{
+	tokenName:     schemaString("name of the token"),
+	tokenValue:    schemaString("value of the token"),
+	tokenCommands: schemaList("commands the token is allowed to execute"),
+	tokenTags:     schemaList("tags of the commands the token is allowed to execute"),
+	tokenView:     schemaBool("the token can only view the commands"),
+}
+
+var expectSchema = map[string]*schema{
+	expectCode:   schemaInt("expected exit code"),
+	expectOutput: schemaString("regular expression the output should match"),
+	expectFiles:  schemaList("files that should exist once the command is done"),
+}
+
+func fileSchema() *schema {
+	props := copySchema(commandSchema)
+	props[propHosts] = &schema{
+		Desc: commandSchema[propHosts].Desc,
+		OneOf: []*schema{
+			schemaList(""),
+			{Type: "array", Items: &schema{Ref: "#/$defs/host"}},
+		},
+	}
+	return &schema{
+		Schema: schemaVersion,
+		Title:  "maestro file",
+		Type:   "object",
+		Properties: map[string]*schema{
+			"metas": schemaObject("metas of the maestro file", metaSchema),
+			"commands": {
+				Type:       "object",
+				Desc:       "commands of the maestro file",
+				Additional: &schema{Ref: "#/$defs/command"},
+			},
+		},
+		Additional: false,
+		Defs: map[string]*schema{
+			"command":  schemaObject("properties of a command", props),
+			"option":   schemaObject("option of a command", optionSchema),
+			"schedule": schemaObject("schedule of a command", scheduleSchema),
+			"redirect": {
+				OneOf: []*schema{
+					schemaString("file where the output is written"),
+					schemaObject("", redirectSchema),
+				},
+			},
+			"host":   schemaObject("SSH settings of a remote server", hostSchema),
+			"token":  schemaObject("token accepted by the HTTP server", tokenSchema),
+			"expect": schemaObject("expected result of a command", expectSchema),
+		},
+	}
+}
+
+func copySchema(set map[string]*schema) map[string]*schema {
+	other := make(map[string]*schema)
+	for k, v := range set {
+		other[k] = v
+	}
+	return other
+}
+
+func WriteSchema(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(fileSchema())
+}
+
+func (m *Maestro) Schema(args []string) error {
+	set := flag.NewFlagSet(CmdSchema, flag.ContinueOnError)
+	if err := parseFlags(set, args); err != nil {
+		return err
+	}
+	return WriteSchema(m.Stdout)
+}
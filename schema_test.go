@@ -0,0 +1,82 @@
+package maestro_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/midbel/maestro"
+)
+
+func TestSchema(t *testing.T) {
+	var buf bytes.Buffer
+	if err := maestro.WriteSchema(&buf); err != nil {
+		t.Fatalf("fail to write schema: %s", err)
+	}
+	type object struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	var schema struct {
+		Properties struct {
+			Metas object `json:"metas"`
+		} `json:"properties"`
+		Defs map[string]object `json:"$defs"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &schema); err != nil {
+		t.Fatalf("invalid JSON: %s", err)
+	}
+	tests := []struct {
+		Name    string
+		Props   map[string]json.RawMessage
+		Pattern string
+		Unknown string
+		Bogus   string
+	}{
+		{
+			Name:    "metas",
+			Props:   schema.Properties.Metas.Properties,
+			Pattern: ".%s = foobar\n",
+			Unknown: "unknown/unsupported meta",
+			Bogus:   "FOOBAR",
+		},
+		{
+			Name:    "command",
+			Props:   schema.Defs["command"].Properties,
+			Pattern: "cmd(%s = foobar): {\n\techo\n}\n",
+			Unknown: "unknown command property",
+			Bogus:   "foobar",
+		},
+		{
+			Name:    "option",
+			Props:   schema.Defs["option"].Properties,
+			Pattern: "cmd(options = (%s = foobar)): {\n\techo\n}\n",
+			Unknown: "unknown option property",
+			Bogus:   "foobar",
+		},
+		{
+			Name:    "schedule",
+			Props:   schema.Defs["schedule"].Properties,
+			Pattern: "cmd(schedule = (%s = foobar)): {\n\techo\n}\n",
+			Unknown: "unknown schedule property",
+			Bogus:   "foobar",
+		},
+	}
+	for _, tt := range tests {
+		if len(tt.Props) == 0 {
+			t.Errorf("%s: no properties in schema", tt.Name)
+			continue
+		}
+		_, err := maestro.Decode(strings.NewReader(fmt.Sprintf(tt.Pattern, tt.Bogus)))
+		if err == nil || !strings.Contains(err.Error(), tt.Unknown) {
+			t.Errorf("%s: %s should be rejected! got %v", tt.Name, tt.Bogus, err)
+		}
+		for p := range tt.Props {
+			_, err := maestro.Decode(strings.NewReader(fmt.Sprintf(tt.Pattern, p)))
+			if err != nil && strings.Contains(err.Error(), tt.Unknown) {
+				t.Errorf("%s: %s not supported by decoder", tt.Name, p)
+			}
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package maestro
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/midbel/maestro/schedule"
+)
+
+const (
+	schtasksPath        = `\maestro\`
+	schtasksProgram     = "maestro.exe"
+	schtasksMaxTriggers = 48
+)
+
+const (
+	calendarByDay         = "ScheduleByDay"
+	calendarByWeek        = "ScheduleByWeek"
+	calendarByMonth       = "ScheduleByMonth"
+	calendarByMonthOfWeek = "ScheduleByMonthDayOfWeek"
+)
+
+type schTask struct {
+	Name     string
+	Desc     string
+	Program  string
+	Args     []string
+	Dir      string
+	Parallel bool
+	Timeout  time.Duration
+	Triggers []schTrigger
+}
+
+type schTrigger struct {
+	Calendar string
+	Start    time.Time
+	Interval time.Duration
+	Duration time.Duration
+	Delay    time.Duration
+	Days     []int
+	Months   []int
+	Weekdays []time.Weekday
+}
+
+func (m *Maestro) schtasks(names []string, program string) ([]schTask, error) {
+	jobs, err := m.scheduledJobs(names)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		tasks []schTask
+		now   = time.Now()
+	)
+	for _, j := range jobs {
+		task := schTask{
+			Name:     j.Name,
+			Desc:     j.Command.Short,
+			Program:  program,
+			Args:     j.Args,
+			Dir:      j.Dir,
+			Parallel: j.Schedule.Overlap,
+			Timeout:  j.Command.Timeout,
+		}
+		task.Triggers, err = schTriggers(j.Schedule.Sched.Spec(), now, j.Schedule.Jitter)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", j.Command.Command(), err)
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+func schTriggers(spec schedule.Spec, now time.Time, jitter time.Duration) ([]schTrigger, error) {
+	if spec.Reboot {
+		return nil, fmt.Errorf("task scheduler can not run a task when maestro schedule starts (@reboot)")
+	}
+	if spec.Every > 0 {
+		if spec.Every < time.Minute {
+			return nil, fmt.Errorf("task scheduler can not repeat a task more than once per minute (got @every %s)", spec.Every)
+		}
+		t := schTrigger{
+			Start:    now.Truncate(time.Minute),
+			Interval: spec.Every,
+			Delay:    jitter,
+		}
+		return []schTrigger{t}, nil
+	}
+	if spec.Seconds == nil {
+		return nil, fmt.Errorf("task scheduler can not repeat a task every second")
+	}
+	var calendars []schTrigger
+	switch {
+	case spec.Days == nil && spec.Months == nil && spec.Weekdays == nil:
+		calendars = append(calendars, schTrigger{Calendar: calendarByDay})
+	case spec.Days == nil && spec.Months == nil:
+		calendars = append(calendars, schTrigger{
+			Calendar: calendarByWeek,
+			Weekdays: spec.Weekdays,
+		})
+	case spec.Days == nil && spec.Weekdays != nil:
+		calendars = append(calendars, schTrigger{
+			Calendar: calendarByMonthOfWeek,
+			Weekdays: spec.Weekdays,
+			Months:   spec.Months,
+		})
+	default:
+		calendars = append(calendars, schTrigger{
+			Calendar: calendarByMonth,
+			Days:     spec.Days,
+			Months:   spec.Months,
+		})
+		if spec.Weekdays == nil {
+			break
+		}
+		t := schTrigger{
+			Calendar: calendarByWeek,
+			Weekdays: spec.Weekdays,
+		}
+		if spec.Months != nil {
+			t.Calendar = calendarByMonthOfWeek
+			t.Months = spec.Months
+		}
+		calendars = append(calendars, t)
+	}
+	var (
+		hours   = spec.Hours
+		minutes = spec.Minutes
+		repeat  time.Duration
+		period  time.Duration
+	)
+	switch {
+	case hours == nil && minutes == nil:
+		hours, minutes = []int{0}, []int{0}
+		repeat, period = time.Minute, 24*time.Hour
+	case minutes == nil:
+		minutes = []int{0}
+		repeat, period = time.Minute, time.Hour
+	case hours == nil:
+		hours = []int{0}
+		repeat, period = time.Hour, 24*time.Hour
+	}
+	var list []schTrigger
+	for _, c := range calendars {
+		for _, h := range hours {
+			for _, m := range minutes {
+				for _, s := range spec.Seconds {
+					t := c
+					t.Start = time.Date(now.Year(), now.Month(), now.Day(), h, m, s, 0, now.Location())
+					t.Interval = repeat
+					t.Duration = period
+					t.Delay = jitter
+					list = append(list, t)
+				}
+			}
+		}
+	}
+	if len(list) > schtasksMaxTriggers {
+		return nil, fmt.Errorf("task scheduler accepts at most %d triggers per task (got %d)", schtasksMaxTriggers, len(list))
+	}
+	return list, nil
+}
+
+func writeSchtasks(w io.Writer, tasks []schTask, file string) {
+	fmt.Fprintf(w, "# scheduled tasks of %s - register them by running this script with PowerShell", file)
+	fmt.Fprintln(w)
+	for _, t := range tasks {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Register-ScheduledTask -TaskPath '%s' -TaskName '%s' -Force -Xml @'", schtasksPath, t.Name)
+		fmt.Fprintln(w)
+		writeTaskXML(w, t)
+		fmt.Fprintln(w, "'@")
+	}
+}
+
+func writeTaskXML(w io.Writer, t schTask) {
+	fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-16"?>`)
+	fmt.Fprintln(w, `<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">`)
+	fmt.Fprintln(w, "  <RegistrationInfo>")
+	fmt.Fprintln(w, "    <Author>maestro</Author>")
+	if t.Desc != "" {
+		fmt.Fprintf(w, "    <Description>%s</Description>", xmlText(t.Desc))
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "  </RegistrationInfo>")
+	fmt.Fprintln(w, "  <Triggers>")
+	for _, g := range t.Triggers {
+		writeTaskTrigger(w, g)
+	}
+	fmt.Fprintln(w, "  </Triggers>")
+	fmt.Fprintln(w, "  <Settings>")
+	policy := "IgnoreNew"
+	if t.Parallel {
+		policy = "Parallel"
+	}
+	fmt.Fprintf(w, "    <MultipleInstancesPolicy>%s</MultipleInstancesPolicy>", policy)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "    <Enabled>true</Enabled>")
+	if t.Timeout > 0 {
+		fmt.Fprintf(w, "    <ExecutionTimeLimit>%s</ExecutionTimeLimit>", isoDuration(t.Timeout))
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "  </Settings>")
+	fmt.Fprintln(w, `  <Actions Context="Author">`)
+	fmt.Fprintln(w, "    <Exec>")
+	fmt.Fprintf(w, "      <Command>%s</Command>", xmlText(t.Program))
+	fmt.Fprintln(w)
+	args := make([]string, len(t.Args))
+	for i := range t.Args {
+		args[i] = quoteWindows(t.Args[i])
+	}
+	fmt.Fprintf(w, "      <Arguments>%s</Arguments>", xmlText(strings.Join(args, " ")))
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "      <WorkingDirectory>%s</WorkingDirectory>", xmlText(t.Dir))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "    </Exec>")
+	fmt.Fprintln(w, "  </Actions>")
+	fmt.Fprintln(w, "</Task>")
+}
+
+func writeTaskTrigger(w io.Writer, t schTrigger) {
+	elem := "CalendarTrigger"
+	if t.Calendar == "" {
+		elem = "TimeTrigger"
+	}
+	fmt.Fprintf(w, "    <%s>", elem)
+	fmt.Fprintln(w)
+	if t.Interval > 0 {
+		fmt.Fprintln(w, "      <Repetition>")
+		fmt.Fprintf(w, "        <Interval>%s</Interval>", isoDuration(t.Interval))
+		fmt.Fprintln(w)
+		if t.Duration > 0 {
+			fmt.Fprintf(w, "        <Duration>%s</Duration>", isoDuration(t.Duration))
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, "      </Repetition>")
+	}
+	fmt.Fprintf(w, "      <StartBoundary>%s</StartBoundary>", t.Start.Format("2006-01-02T15:04:05"))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "      <Enabled>true</Enabled>")
+	if t.Delay > 0 {
+		fmt.Fprintf(w, "      <RandomDelay>%s</RandomDelay>", isoDuration(t.Delay))
+		fmt.Fprintln(w)
+	}
+	switch t.Calendar {
+	case calendarByDay:
+		fmt.Fprintln(w, "      <ScheduleByDay>")
+		fmt.Fprintln(w, "        <DaysInterval>1</DaysInterval>")
+		fmt.Fprintln(w, "      </ScheduleByDay>")
+	case calendarByWeek:
+		fmt.Fprintln(w, "      <ScheduleByWeek>")
+		fmt.Fprintln(w, "        <WeeksInterval>1</WeeksInterval>")
+		writeTaskWeekdays(w, t.Weekdays)
+		fmt.Fprintln(w, "      </ScheduleByWeek>")
+	case calendarByMonth:
+		fmt.Fprintln(w, "      <ScheduleByMonth>")
+		fmt.Fprintln(w, "        <DaysOfMonth>")
+		days := t.Days
+		if days == nil {
+			days = makeRange(1, 31)
+		}
+		for _, d := range days {
+			fmt.Fprintf(w, "          <Day>%d</Day>", d)
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, "        </DaysOfMonth>")
+		writeTaskMonths(w, t.Months)
+		fmt.Fprintln(w, "      </ScheduleByMonth>")
+	case calendarByMonthOfWeek:
+		fmt.Fprintln(w, "      <ScheduleByMonthDayOfWeek>")
+		fmt.Fprintln(w, "        <Weeks>")
+		for _, k := range []string{"1", "2", "3", "4", "Last"} {
+			fmt.Fprintf(w, "          <Week>%s</Week>", k)
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, "        </Weeks>")
+		writeTaskWeekdays(w, t.Weekdays)
+		writeTaskMonths(w, t.Months)
+		fmt.Fprintln(w, "      </ScheduleByMonthDayOfWeek>")
+	}
+	fmt.Fprintf(w, "    </%s>", elem)
+	fmt.Fprintln(w)
+}
+
+func writeTaskWeekdays(w io.Writer, days []time.Weekday) {
+	fmt.Fprintln(w, "        <DaysOfWeek>")
+	for _, d := range days {
+		fmt.Fprintf(w, "          <%s />", d)
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "        </DaysOfWeek>")
+}
+
+func writeTaskMonths(w io.Writer, months []int) {
+	if months == nil {
+		months = makeRange(1, 12)
+	}
+	fmt.Fprintln(w, "        <Months>")
+	for _, m := range months {
+		fmt.Fprintf(w, "          <%s />", time.Month(m))
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "        </Months>")
+}
+
+func makeRange(from, to int) []int {
+	var list []int
+	for i := from; i <= to; i++ {
+		list = append(list, i)
+	}
+	return list
+}
+
+func isoDuration(d time.Duration) string {
+	var (
+		str  strings.Builder
+		days = d / (24 * time.Hour)
+	)
+	str.WriteString("P")
+	if days > 0 {
+		fmt.Fprintf(&str, "%dD", days)
+		d -= days * 24 * time.Hour
+	}
+	if d == 0 && days > 0 {
+		return str.String()
+	}
+	str.WriteString("T")
+	var (
+		hours = d / time.Hour
+		mins  = (d % time.Hour) / time.Minute
+		secs  = (d % time.Minute) / time.Second
+	)
+	if hours > 0 {
+		fmt.Fprintf(&str, "%dH", hours)
+	}
+	if mins > 0 {
+		fmt.Fprintf(&str, "%dM", mins)
+	}
+	if secs > 0 || (hours == 0 && mins == 0) {
+		fmt.Fprintf(&str, "%dS", secs)
+	}
+	return str.String()
+}
+
+func quoteWindows(str string) string {
+	if str != "" && !strings.ContainsAny(str, " \t\"") {
+		return str
+	}
+	return `"` + strings.ReplaceAll(str, `"`, `\"`) + `"`
+}
+
+func xmlText(str string) string {
+	var buf strings.Builder
+	xml.EscapeText(&buf, []byte(str))
+	return buf.String()
+}
@@ -0,0 +1,124 @@
+package maestro
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const DefaultSpillThreshold = 32 << 20
+
+type spillBuffer struct {
+	mu        sync.Mutex
+	threshold int64
+	size      int64
+	mem       bytes.Buffer
+	file      *os.File
+}
+
+func newSpillBuffer(threshold int64) *spillBuffer {
+	return &spillBuffer{
+		threshold: threshold,
+	}
+}
+
+func (s *spillBuffer) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil && s.threshold > 0 && int64(s.mem.Len()+len(b)) > s.threshold {
+		if err := s.spill(); err != nil {
+			return 0, err
+		}
+	}
+	var (
+		n   int
+		err error
+	)
+	if s.file != nil {
+		n, err = s.file.Write(b)
+	} else {
+		n, err = s.mem.Write(b)
+	}
+	s.size += int64(n)
+	return n, err
+}
+
+func (s *spillBuffer) spill() error {
+	f, err := os.CreateTemp("", "maestro-*.out")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(s.mem.Bytes()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	s.mem.Reset()
+	s.file = f
+	return nil
+}
+
+func (s *spillBuffer) Reader() io.Reader {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return bytes.NewReader(s.mem.Bytes())
+	}
+	return io.NewSectionReader(s.file, 0, s.size)
+}
+
+func (s *spillBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return s.mem.String()
+	}
+	var (
+		offset = s.size - s.threshold
+		buf    = make([]byte, s.threshold)
+	)
+	n, _ := s.file.ReadAt(buf, offset)
+	return fmt.Sprintf("[%d bytes truncated]\n%s", offset, buf[:n])
+}
+
+func (s *spillBuffer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	defer func() {
+		s.file = nil
+	}()
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
+
+func parseSize(str string) (int64, error) {
+	var (
+		unit int64 = 1
+		num        = strings.TrimSuffix(strings.ToUpper(str), "B")
+	)
+	if n := len(num); n > 0 {
+		switch num[n-1] {
+		case 'K':
+			unit = 1 << 10
+		case 'M':
+			unit = 1 << 20
+		case 'G':
+			unit = 1 << 30
+		}
+		if unit > 1 {
+			num = num[:n-1]
+		}
+	}
+	size, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("%s: invalid size", str)
+	}
+	return size * unit, nil
+}
@@ -0,0 +1,45 @@
+package maestro_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/midbel/maestro"
+)
+
+func TestRunTestSpill(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(spilled))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	if mst.MetaExec.SpillThreshold != 1024 {
+		t.Fatalf("threshold mismatched! want %d, got %d", 1024, mst.MetaExec.SpillThreshold)
+	}
+	file := filepath.Join(t.TempDir(), "report.xml")
+	if err := mst.Test([]string{"-f", "junit", "-o", file}); err != nil {
+		t.Fatalf("test should succeed: %s", err)
+	}
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("fail to read report: %s", err)
+	}
+	if !bytes.Contains(buf, []byte("bytes truncated")) || !bytes.Contains(buf, []byte("last")) {
+		t.Errorf("report should only contain the end of the output: %s", buf)
+	}
+}
+
+const spilled = `
+.SPILL_THRESHOLD = 1K
+
+large(
+	tag    = test,
+	expect = (output = "(?s)^first.*last"),
+): {
+	echo first
+	seq 1 1000
+	echo last
+}
+`
@@ -0,0 +1,409 @@
+package maestro
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const DefaultScriptDir = "bin"
+
+type standaloneStep struct {
+	Func string
+	Args []string
+	Bg   bool
+}
+
+type standaloneScript struct {
+	Name    string
+	Command CommandSettings
+	Deps    []CommandSettings
+	Steps   []standaloneStep
+}
+
+func (m *Maestro) standalone(names []string) ([]standaloneScript, error) {
+	if len(names) == 0 {
+		for _, c := range m.Commands.Values() {
+			if c.Visible {
+				names = append(names, c.Command())
+			}
+		}
+		sort.Strings(names)
+	}
+	var list []standaloneScript
+	for _, n := range names {
+		cmd, err := m.Commands.Lookup(n)
+		if err != nil {
+			return nil, err
+		}
+		if err := standaloneSecrets(cmd); err != nil {
+			return nil, err
+		}
+		steps, err := m.explainSteps(cmd, ctreeOption{})
+		if err != nil {
+			return nil, err
+		}
+		script := standaloneScript{
+			Name:    jobIdent(cmd.Command()),
+			Command: cmd,
+		}
+		seen := make(map[string]struct{})
+		for _, s := range steps {
+			if s.Reason != "" {
+				continue
+			}
+			dep, err := m.Commands.Lookup(s.Name)
+			if err != nil {
+				return nil, err
+			}
+			if err := standaloneSecrets(dep); err != nil {
+				return nil, err
+			}
+			if _, ok := seen[dep.Command()]; !ok {
+				seen[dep.Command()] = struct{}{}
+				script.Deps = append(script.Deps, dep)
+			}
+			script.Steps = append(script.Steps, standaloneStep{
+				Func: funcIdent(dep.Command()),
+				Args: s.Args,
+				Bg:   s.Bg,
+			})
+		}
+		list = append(list, script)
+	}
+	return list, nil
+}
+
+func writeStandaloneDir(dir string, scripts []standaloneScript, file string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, s := range scripts {
+		f, err := os.OpenFile(filepath.Join(dir, s.Name), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+		if err != nil {
+			return err
+		}
+		writeStandalone(f, s, file)
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeStandalone(w io.Writer, s standaloneScript, file string) {
+	fmt.Fprintln(w, "#!/bin/sh")
+	fmt.Fprintf(w, "# %s", s.Command.Command())
+	if about := firstLine(s.Command.About()); about != "" {
+		fmt.Fprintf(w, ": %s", about)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "# generated by maestro from %s - do not edit", file)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "set -e")
+	fmt.Fprintln(w)
+	for _, d := range s.Deps {
+		fmt.Fprintf(w, "%s() (", funcIdent(d.Command()))
+		fmt.Fprintln(w)
+		writeStandaloneBody(w, d, "\t", nil)
+		fmt.Fprintln(w, ")")
+		fmt.Fprintln(w)
+	}
+	writeStandaloneBody(w, s.Command, "", s.Steps)
+}
+
+func writeStandaloneBody(w io.Writer, cmd CommandSettings, indent string, steps []standaloneStep) {
+	line := func(format string, args ...interface{}) {
+		fmt.Fprint(w, indent)
+		fmt.Fprintf(w, format, args...)
+		fmt.Fprintln(w)
+	}
+	for _, k := range cmd.Ev.Keys() {
+		if cmd.secrets.Has(k) {
+			line(": \"${%s:?must be set}\"", k)
+			line("export %s", k)
+			continue
+		}
+		v, _ := cmd.Ev.Get(k)
+		line("export %s=%s", k, shellQuote(v))
+	}
+	for _, n := range cmd.NeedsEnv {
+		line(": \"${%s:?must be set}\"", n)
+	}
+	for _, v := range standaloneLocals(cmd) {
+		if cmd.secrets.Has(v) {
+			line(": \"${%s:?must be set}\"", v)
+			continue
+		}
+		vs, _ := cmd.locals.Resolve(v)
+		line("%s=%s", v, shellQuote(strings.Join(vs, " ")))
+	}
+	writeStandaloneOptions(line, cmd)
+	writeStandaloneArgs(line, cmd)
+
+	var bg bool
+	for _, s := range steps {
+		str := s.Func
+		for _, a := range s.Args {
+			str += " " + shellQuote(a)
+		}
+		if s.Bg {
+			str += " &"
+			bg = true
+		}
+		line("%s", str)
+	}
+	if bg {
+		line("wait")
+	}
+	if cmd.WorkDir != "" {
+		line("cd %s", shellQuote(cmd.WorkDir))
+	}
+	for _, str := range cmd.Lines {
+		line("%s", str)
+	}
+}
+
+func writeStandaloneOptions(line func(string, ...interface{}), cmd CommandSettings) {
+	if len(cmd.Options) == 0 {
+		return
+	}
+	var (
+		spec  strings.Builder
+		longs []CommandOption
+	)
+	for _, o := range cmd.Options {
+		switch {
+		case o.Flag:
+			line("%s", assignOption(o, strconv.FormatBool(o.DefaultFlag), false))
+		case o.Default != "":
+			line("%s", assignOption(o, shellQuote(o.Default), false))
+		default:
+			line("%s", assignOption(o, "", false))
+		}
+		if len(o.Short) == 1 {
+			spec.WriteString(o.Short)
+			if !o.Flag {
+				spec.WriteString(":")
+			}
+		}
+		if len(o.Short) > 1 || o.Long != "" {
+			longs = append(longs, o)
+		}
+	}
+	value := func(o CommandOption, val string) string {
+		if o.Flag {
+			return assignOption(o, "true", false)
+		}
+		return assignOption(o, val, o.List)
+	}
+	spec.WriteString("-:")
+	line("OPTIND=1")
+	line("while getopts ':%s' opt; do", spec.String())
+	line("\tcase \"$opt\" in")
+	for _, o := range cmd.Options {
+		if len(o.Short) == 1 {
+			line("\t%s) %s ;;", o.Short, value(o, `"$OPTARG"`))
+		}
+	}
+	line("\t-)")
+	line("\t\tcase \"$OPTARG\" in")
+	for _, o := range longs {
+		names := longNames(o)
+		if o.Flag {
+			line("\t\t%s) %s ;;", strings.Join(names, "|"), value(o, ""))
+			continue
+		}
+		var with []string
+		for _, n := range names {
+			with = append(with, n+"=*")
+		}
+		line("\t\t%s) %s ;;", strings.Join(with, "|"), value(o, `"${OPTARG#*=}"`))
+		line("\t\t%s)", strings.Join(names, "|"))
+		line("\t\t\t[ \"$OPTIND\" -le $# ] || { echo \"$0: missing value for --$OPTARG\" >&2; exit 2; }")
+		line("\t\t\teval \"val=\\${$OPTIND}\"")
+		line("\t\t\tOPTIND=$((OPTIND + 1))")
+		line("\t\t\t%s", value(o, `"$val"`))
+		line("\t\t\t;;")
+	}
+	line("\t\t*) echo \"$0: unknown option --$OPTARG\" >&2; exit 2 ;;")
+	line("\t\tesac")
+	line("\t\t;;")
+	line("\t:) echo \"$0: missing value for -$OPTARG\" >&2; exit 2 ;;")
+	line("\t*) echo \"$0: unknown option -$OPTARG\" >&2; exit 2 ;;")
+	line("\tesac")
+	line("done")
+	line("shift $((OPTIND - 1))")
+	for _, o := range cmd.Options {
+		name := standaloneVar(o)
+		if name == "" || o.Flag {
+			continue
+		}
+		if o.Required {
+			line("[ -n \"$%s\" ] || { echo \"$0: %s is required\" >&2; exit 2; }", name, strings.Join(optionNames(o), "/"))
+		}
+		if len(o.Choices) > 0 && !o.List {
+			line("case \"$%s\" in", name)
+			line("''|%s) ;;", strings.Join(quoteChoices(o.Choices), "|"))
+			line("*) echo \"$0: %s: invalid value $%s (%s expected)\" >&2; exit 2 ;;", strings.Join(optionNames(o), "/"), name, strings.Join(o.Choices, ", "))
+			line("esac")
+		}
+	}
+}
+
+func writeStandaloneArgs(line func(string, ...interface{}), cmd CommandSettings) {
+	if len(cmd.Args) == 0 {
+		return
+	}
+	if n := requiredArgs(cmd.Args); n > 0 {
+		line("[ $# -ge %d ] || { echo \"usage: $0 %s\" >&2; exit 2; }", n, strings.TrimPrefix(cmd.Usage(), cmd.Command()+" "))
+	}
+	for i, a := range cmd.Args {
+		if !isShellIdent(a.Name) {
+			continue
+		}
+		if !a.Variadic {
+			line("%s=\"${%d:-}\"", a.Name, i+1)
+			continue
+		}
+		line("%s=", a.Name)
+		line("i=0")
+		line("for arg; do")
+		line("\ti=$((i + 1))")
+		line("\tif [ \"$i\" -gt %d ]; then %s=\"${%s:+$%s }$arg\"; fi", i, a.Name, a.Name, a.Name)
+		line("done")
+	}
+}
+
+// standaloneSecrets refuses the commands whose exported scripts would contain
+// a decrypted value that is not given by its own variable (eg: a variable
+// built from an encrypted one). Variables given encrypted are looked up in
+// the environment of the script instead.
+func standaloneSecrets(cmd CommandSettings) error {
+	leak := func(what, value string) error {
+		if !cmd.secrets.Contains(value) {
+			return nil
+		}
+		return fmt.Errorf("%s: %s: value built from an encrypted value can not be exported", cmd.Command(), what)
+	}
+	for _, k := range cmd.Ev.Keys() {
+		if cmd.secrets.Has(k) {
+			continue
+		}
+		v, _ := cmd.Ev.Get(k)
+		if err := leak(k, v); err != nil {
+			return err
+		}
+	}
+	for _, v := range standaloneLocals(cmd) {
+		if cmd.secrets.Has(v) {
+			continue
+		}
+		vs, _ := cmd.locals.Resolve(v)
+		if err := leak(v, strings.Join(vs, " ")); err != nil {
+			return err
+		}
+	}
+	for _, o := range cmd.Options {
+		if err := leak(standaloneVar(o), o.Default); err != nil {
+			return err
+		}
+	}
+	return leak(propWorkDir, cmd.WorkDir)
+}
+
+func standaloneLocals(cmd CommandSettings) []string {
+	var (
+		refs    = make(map[string]bool)
+		defined = make(map[string]struct{})
+		list    []string
+	)
+	for _, str := range cmd.Lines {
+		scanReferences(str, refs, defined)
+	}
+	for _, o := range cmd.Options {
+		defined[o.Short] = struct{}{}
+		defined[o.Long] = struct{}{}
+	}
+	for _, a := range cmd.Args {
+		defined[a.Name] = struct{}{}
+	}
+	for ident := range refs {
+		if _, ok := defined[ident]; ok || cmd.Ev.Has(ident) {
+			continue
+		}
+		if isShellIdent(ident) && cmd.locals.Defined(ident) {
+			list = append(list, ident)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+func assignOption(o CommandOption, value string, appendValue bool) string {
+	var list []string
+	for _, n := range []string{o.Short, o.Long} {
+		if !isShellIdent(n) {
+			continue
+		}
+		str := fmt.Sprintf("%s=%s", n, value)
+		if appendValue {
+			str = fmt.Sprintf("%s=\"${%s:+$%s }\"%s", n, n, n, value)
+		}
+		list = append(list, str)
+	}
+	if len(list) == 0 {
+		return ":"
+	}
+	return strings.Join(list, "; ")
+}
+
+func standaloneVar(o CommandOption) string {
+	for _, n := range []string{o.Long, o.Short} {
+		if isShellIdent(n) {
+			return n
+		}
+	}
+	return ""
+}
+
+func longNames(o CommandOption) []string {
+	var names []string
+	if len(o.Short) > 1 {
+		names = append(names, o.Short)
+	}
+	if o.Long != "" {
+		names = append(names, o.Long)
+	}
+	return names
+}
+
+func quoteChoices(list []string) []string {
+	var res []string
+	for _, c := range list {
+		res = append(res, shellQuote(c))
+	}
+	return res
+}
+
+func funcIdent(name string) string {
+	return "maestro_" + strings.ReplaceAll(jobIdent(name), "-", "_")
+}
+
+func isShellIdent(str string) bool {
+	if str == "" {
+		return false
+	}
+	for i, r := range str {
+		if isLetter(r) || r == underscore || (i > 0 && isDigit(r)) {
+			continue
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package maestro_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/midbel/maestro"
+)
+
+func TestExportShellSecrets(t *testing.T) {
+	t.Setenv("MAESTRO_AGE_KEY", "AGE-SECRET-KEY-1VU6QY6MKD3C0JUT9GG6ML85U35ASP5J5TN9MVX0MSLJRK0E73Z5Q25MYAD")
+	mst, err := maestro.Decode(strings.NewReader(standaloneSecrets))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	dir := t.TempDir()
+	if err := mst.Export([]string{"--format", "shell", "-o", dir, "deploy"}); err != nil {
+		t.Fatalf("fail to export: %s", err)
+	}
+	buf, err := os.ReadFile(filepath.Join(dir, "deploy"))
+	if err != nil {
+		t.Fatalf("script not written: %s", err)
+	}
+	if strings.Contains(string(buf), "s3cr3t") {
+		t.Errorf("decrypted value written in script")
+	}
+	for _, p := range []string{`: "${TOKEN:?must be set}"`, `: "${API_KEY:?must be set}"`, "export API_KEY"} {
+		if !strings.Contains(string(buf), p) {
+			t.Errorf("%q not found in script", p)
+		}
+	}
+	err = mst.Export([]string{"--format", "shell", "-o", t.TempDir(), "leak"})
+	if err == nil {
+		t.Errorf("value built from an encrypted value should not be exported")
+	}
+}
+
+const standaloneSecrets = `
+TOKEN = "enc[AGE-YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IFgyNTUxOSBWem5nbGxQQUFwYWROTjJjNkM1NlR5WVNzRmp1dFZ4R0tKdkErcGx1NmpBCm9RalpEUGVDK0pNZVpvcnZtOTdhT1c2a3NPWDNHSkNFUDVYY0lOVHRZYk0KLS0tIFUrektkbzRCdnNqOWJmTVROOFlRcUVSU2xNMjFhQlFKbWpuOWNRWXdTaHMKTh7Stz1B37oE6ToIY7Fnnxbpe1AAfOH7xlZqfmXtYQzmdFWTlh4=]"
+URL   = "https://$TOKEN@example.org"
+
+deploy(
+	export API_KEY = "enc[AGE-YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IFgyNTUxOSBWem5nbGxQQUFwYWROTjJjNkM1NlR5WVNzRmp1dFZ4R0tKdkErcGx1NmpBCm9RalpEUGVDK0pNZVpvcnZtOTdhT1c2a3NPWDNHSkNFUDVYY0lOVHRZYk0KLS0tIFUrektkbzRCdnNqOWJmTVROOFlRcUVSU2xNMjFhQlFKbWpuOWNRWXdTaHMKTh7Stz1B37oE6ToIY7Fnnxbpe1AAfOH7xlZqfmXtYQzmdFWTlh4=]",
+): {
+	curl -H "token: $TOKEN" https://example.org
+}
+
+leak: {
+	curl $URL
+}
+`
+
+func TestExportShellDir(t *testing.T) {
+	mst, err := maestro.Decode(strings.NewReader(standaloneDir))
+	if err != nil {
+		t.Fatalf("fail to decode: %s", err)
+	}
+	dir := filepath.Join(t.TempDir(), "bin")
+	if err := mst.Export([]string{"-dir", dir, "build"}); err != nil {
+		t.Fatalf("fail to export: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "build")); err != nil {
+		t.Errorf("script not written: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "clean")); err == nil {
+		t.Errorf("clean should not be exported")
+	}
+	if err := mst.Export([]string{"-f", "gitlab", "-dir", dir}); err == nil {
+		t.Errorf("-dir should only be used with the shell format")
+	}
+	if err := mst.Export([]string{"-dir", dir, "-o", t.TempDir()}); err == nil {
+		t.Errorf("-dir and -o should not be used together")
+	}
+}
+
+const standaloneDir = `
+build: {
+	go build
+}
+
+clean: {
+	rm -rf bin
+}
+`
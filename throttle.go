@@ -0,0 +1,74 @@
+package maestro
+
+import (
+	"sync"
+	"time"
+)
+
+type throttle struct {
+	mu      sync.Mutex
+	hits    map[string][]time.Time
+	pending map[string]*debounced
+}
+
+type debounced struct {
+	timer *time.Timer
+	args  []string
+}
+
+func (t *throttle) allow(cmd CommandSettings, now time.Time) bool {
+	if cmd.RateLimit <= 0 {
+		return true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.hits == nil {
+		t.hits = make(map[string][]time.Time)
+	}
+	var (
+		name  = cmd.Command()
+		since = now.Add(-time.Minute)
+		list  = t.hits[name]
+		i     int
+	)
+	for i < len(list) && !list[i].After(since) {
+		i++
+	}
+	list = list[i:]
+	if int64(len(list)) >= cmd.RateLimit {
+		t.hits[name] = list
+		return false
+	}
+	t.hits[name] = append(list, now)
+	return true
+}
+
+func (t *throttle) debounce(cmd CommandSettings, args []string, run func([]string)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pending == nil {
+		t.pending = make(map[string]*debounced)
+	}
+	name := cmd.Command()
+	if d, ok := t.pending[name]; ok && d.timer.Stop() {
+		d.args = args
+		d.timer.Reset(cmd.Debounce)
+		return
+	}
+	// the timer of the pending execution has already fired: its callback
+	// runs (or waits for the lock) with the arguments it had, so a new
+	// execution is scheduled for the arguments of this request
+	d := debounced{
+		args: args,
+	}
+	d.timer = time.AfterFunc(cmd.Debounce, func() {
+		t.mu.Lock()
+		if t.pending[name] == &d {
+			delete(t.pending, name)
+		}
+		args := d.args
+		t.mu.Unlock()
+		run(args)
+	})
+	t.pending[name] = &d
+}
@@ -11,6 +11,13 @@ const (
 	kwExport  = "export"
 	kwDelete  = "delete"
 	kwAlias   = "alias"
+	kwGlobal  = "global"
+	kwAs      = "as"
+	kwRuns    = "runs"
+	kwIfdef   = "ifdef"
+	kwIfndef  = "ifndef"
+	kwElse    = "else"
+	kwEndif   = "endif"
 )
 
 const (
@@ -21,13 +28,16 @@ const (
 	Ident
 	Keyword
 	String
+	Heredoc
 	Boolean
 	Variable
 	Meta
 	Script
+	Arithmetic
 	Quote
 	Assign
 	Append
+	Default
 	Comma
 	Background
 	Dependency
@@ -41,6 +51,7 @@ const (
 	Mandatory
 	Hidden
 	Resolution
+	Variadic
 )
 
 type Position struct {
@@ -76,6 +87,8 @@ func (t Token) String() string {
 		return "<mandatory>"
 	case Hidden:
 		return "<hidden>"
+	case Variadic:
+		return "<variadic>"
 	case Reverse:
 		return "<reverse>"
 	case Eof:
@@ -88,6 +101,8 @@ func (t Token) String() string {
 		return "<assign>"
 	case Append:
 		return "<append>"
+	case Default:
+		return "<default>"
 	case Comma:
 		return "<comma>"
 	case Dependency:
@@ -112,6 +127,8 @@ func (t Token) String() string {
 		prefix = "ident"
 	case String:
 		prefix = "string"
+	case Heredoc:
+		prefix = "heredoc"
 	case Boolean:
 		prefix = "boolean"
 	case Meta:
@@ -122,6 +139,8 @@ func (t Token) String() string {
 		prefix = "comment"
 	case Script:
 		prefix = "script"
+	case Arithmetic:
+		prefix = "arithmetic"
 	case Keyword:
 		prefix = "keyword"
 	}
@@ -129,7 +148,7 @@ func (t Token) String() string {
 }
 
 func (t Token) IsAssign() bool {
-	return t.Type == Append || t.Type == Assign
+	return t.Type == Append || t.Type == Assign || t.Type == Default
 }
 
 func (t Token) IsVariable() bool {
@@ -137,7 +156,11 @@ func (t Token) IsVariable() bool {
 }
 
 func (t Token) IsValue() bool {
-	return t.IsVariable() || t.IsPrimitive() || t.IsScript()
+	return t.IsVariable() || t.IsPrimitive() || t.IsScript() || t.IsArithmetic()
+}
+
+func (t Token) IsArithmetic() bool {
+	return t.Type == Arithmetic
 }
 
 func (t Token) IsScript() bool {
@@ -145,7 +168,7 @@ func (t Token) IsScript() bool {
 }
 
 func (t Token) IsPrimitive() bool {
-	return t.Type == Ident || t.Type == String || t.Type == Boolean || t.Type == Quote
+	return t.Type == Ident || t.Type == String || t.Type == Heredoc || t.Type == Boolean || t.Type == Quote
 }
 
 func (t Token) IsEOF() bool {
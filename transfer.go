@@ -0,0 +1,174 @@
+package maestro
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+type Transfer struct {
+	Local  string
+	Remote string
+}
+
+func parseTransfer(str string, upload bool) (Transfer, error) {
+	var t Transfer
+	src, dst, ok := strings.Cut(str, ":")
+	if !ok || src == "" || dst == "" {
+		return t, fmt.Errorf("%s: invalid transfer (source:destination expected)", str)
+	}
+	if upload {
+		t.Local, t.Remote = src, dst
+	} else {
+		t.Remote, t.Local = src, dst
+	}
+	return t, nil
+}
+
+func (t Transfer) localFor(host string, multi bool) string {
+	if !multi {
+		return t.Local
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return fmt.Sprintf("%s-%s", t.Local, host)
+}
+
+func upload(client *ssh.Client, t Transfer) error {
+	r, err := os.Open(t.Local)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	info, err := r.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s: not a regular file", t.Local)
+	}
+	sess, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	stdin, err := sess.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := sess.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := sess.Start(fmt.Sprintf("scp -t %s", shellQuote(t.Remote))); err != nil {
+		return err
+	}
+	ack := bufio.NewReader(stdout)
+	if err := readAck(ack); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdin, "C%04o %d %s\n", info.Mode().Perm(), info.Size(), filepath.Base(t.Remote))
+	if err := readAck(ack); err != nil {
+		return err
+	}
+	if _, err := io.Copy(stdin, r); err != nil {
+		return err
+	}
+	stdin.Write([]byte{0})
+	if err := readAck(ack); err != nil {
+		return err
+	}
+	stdin.Close()
+	return sess.Wait()
+}
+
+func download(client *ssh.Client, t Transfer, local string) error {
+	sess, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	stdin, err := sess.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := sess.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := sess.Start(fmt.Sprintf("scp -f %s", shellQuote(t.Remote))); err != nil {
+		return err
+	}
+	rs := bufio.NewReader(stdout)
+	stdin.Write([]byte{0})
+
+	line, err := rs.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if len(line) > 0 && (line[0] == 1 || line[0] == 2) {
+		return fmt.Errorf("%s: %s", t.Remote, strings.TrimSpace(line[1:]))
+	}
+	if !strings.HasPrefix(line, "C") {
+		return fmt.Errorf("%s: unexpected scp response", t.Remote)
+	}
+	parts := strings.SplitN(strings.TrimSpace(line[1:]), " ", 3)
+	if len(parts) != 3 {
+		return fmt.Errorf("%s: unexpected scp response", t.Remote)
+	}
+	perm, err := strconv.ParseUint(parts[0], 8, 32)
+	if err != nil {
+		return err
+	}
+	size, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(local); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	w, err := os.OpenFile(local, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(perm))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	stdin.Write([]byte{0})
+	if _, err := io.CopyN(w, rs, size); err != nil {
+		return err
+	}
+	if err := readAck(rs); err != nil {
+		return err
+	}
+	stdin.Write([]byte{0})
+	stdin.Close()
+	return sess.Wait()
+}
+
+func readAck(r *bufio.Reader) error {
+	b, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b == 0 {
+		return nil
+	}
+	msg, _ := r.ReadString('\n')
+	return fmt.Errorf("scp: %s", strings.TrimSpace(msg))
+}
+
+func shellQuote(str string) string {
+	return "'" + strings.ReplaceAll(str, "'", `'\''`) + "'"
+}
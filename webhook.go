@@ -0,0 +1,87 @@
+package maestro
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	httpHdrGithubSign  = "X-Hub-Signature-256"
+	httpHdrGitlabToken = "X-Gitlab-Token"
+)
+
+const (
+	secretEnv  = "env:"
+	secretFile = "file:"
+)
+
+var errUnauthorized = errors.New("invalid webhook signature")
+
+func (m *Maestro) webhookSecret(cmd CommandSettings) (string, error) {
+	secret := cmd.Secret
+	if secret == "" {
+		secret = m.MetaHttp.Secret
+	}
+	return resolveSecret(secret)
+}
+
+func resolveSecret(str string) (string, error) {
+	switch {
+	case strings.HasPrefix(str, secretEnv):
+		key := strings.TrimPrefix(str, secretEnv)
+		val, ok := os.LookupEnv(key)
+		if !ok || val == "" {
+			return "", fmt.Errorf("%s: secret not defined in environment", key)
+		}
+		return val, nil
+	case strings.HasPrefix(str, secretFile):
+		buf, err := os.ReadFile(strings.TrimPrefix(str, secretFile))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(buf)), nil
+	default:
+		return str, nil
+	}
+}
+
+func verifyWebhook(r *http.Request, secret string) error {
+	if secret == "" {
+		return nil
+	}
+	if token := r.Header.Get(httpHdrGitlabToken); token != "" {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			return errUnauthorized
+		}
+		return nil
+	}
+	sign := r.Header.Get(httpHdrGithubSign)
+	if !strings.HasPrefix(sign, "sha256=") {
+		return errUnauthorized
+	}
+	want, err := hex.DecodeString(strings.TrimPrefix(sign, "sha256="))
+	if err != nil {
+		return errUnauthorized
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), want) {
+		return errUnauthorized
+	}
+	return nil
+}
@@ -0,0 +1,501 @@
+package maestro
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	yamlMeta      = "meta"
+	yamlVariables = "variables"
+	yamlInclude   = "include"
+	yamlCommands  = "commands"
+
+	yamlHidden = "hidden"
+	yamlDeps   = "deps"
+	yamlScript = "script"
+	yamlEnv    = "env"
+
+	yamlIncludeFile     = "file"
+	yamlIncludeAs       = "as"
+	yamlIncludeOptional = "optional"
+
+	yamlMerge    = "<<"
+	yamlMaxDepth = 64
+)
+
+func isYAML(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
+
+// yamlNode is a node of a YAML document whose aliases are resolved and whose
+// merge keys are applied
+type yamlNode struct {
+	Kind   yaml.Kind
+	Line   int
+	Value  string
+	Items  []*yamlNode
+	Fields []yamlField
+
+	null bool
+}
+
+type yamlField struct {
+	Key   string
+	Value *yamlNode
+}
+
+func (n *yamlNode) Get(key string) (*yamlNode, bool) {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil, false
+	}
+	for _, f := range n.Fields {
+		if f.Key == key {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+func (n *yamlNode) Null() bool {
+	return n == nil || (n.Kind == yaml.ScalarNode && n.null)
+}
+
+func readYAML(r io.Reader) (io.Reader, error) {
+	var root yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&root); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	doc, err := convertYAML(&root, 0)
+	if err != nil {
+		return nil, fmt.Errorf("yaml: %w", err)
+	}
+	if doc.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("yaml: mapping expected at top level")
+	}
+	var w yamlWriter
+	for _, f := range doc.Fields {
+		switch f.Key {
+		case yamlMeta:
+			err = w.writeMetas(f.Value)
+		case yamlVariables:
+			err = w.writeVariables(f.Value)
+		case yamlInclude:
+			err = w.writeIncludes(f.Value)
+		case yamlCommands:
+			err = w.writeCommands(f.Value)
+		default:
+			err = fmt.Errorf("%s: unknown section", f.Key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("yaml: %w", err)
+		}
+	}
+	return strings.NewReader(w.String()), nil
+}
+
+type yamlWriter struct {
+	strings.Builder
+}
+
+func (w *yamlWriter) writeMetas(node *yamlNode) error {
+	if node.Null() {
+		return nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return yamlExpected(yamlMeta, node, "mapping")
+	}
+	for _, f := range node.Fields {
+		if f.Value.Null() {
+			continue
+		}
+		str, err := yamlValue(f.Key, f.Value)
+		if err != nil {
+			return err
+		}
+		name := strings.ToUpper(strings.TrimPrefix(f.Key, "."))
+		fmt.Fprintf(w, ".%s = %s", name, str)
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+func (w *yamlWriter) writeVariables(node *yamlNode) error {
+	if node.Null() {
+		return nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return yamlExpected(yamlVariables, node, "mapping")
+	}
+	for _, f := range node.Fields {
+		if !isIdentString(f.Key) {
+			return fmt.Errorf("%s: invalid variable name", f.Key)
+		}
+		str, err := yamlValue(f.Key, f.Value)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s = %s", f.Key, str)
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+func (w *yamlWriter) writeIncludes(node *yamlNode) error {
+	var list []*yamlNode
+	switch node.Kind {
+	case yaml.ScalarNode:
+		list = append(list, node)
+	case yaml.SequenceNode:
+		list = node.Items
+	default:
+		return yamlExpected(yamlInclude, node, "file or list of files")
+	}
+	for _, n := range list {
+		var (
+			file     = n
+			space    *yamlNode
+			optional bool
+		)
+		if n.Kind == yaml.MappingNode {
+			file, _ = n.Get(yamlIncludeFile)
+			space, _ = n.Get(yamlIncludeAs)
+			if opt, ok := n.Get(yamlIncludeOptional); ok {
+				optional = opt.Value == "true"
+			}
+		}
+		if file == nil || file.Kind != yaml.ScalarNode {
+			return yamlExpected(yamlInclude, n, "file")
+		}
+		str, err := quoteValue(file.Value, true)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s %s", kwInclude, str)
+		if optional {
+			fmt.Fprint(w, "?")
+		}
+		if space != nil && !space.Null() {
+			if !isIdentString(space.Value) {
+				return fmt.Errorf("%s: invalid namespace", space.Value)
+			}
+			fmt.Fprintf(w, " %s %s", kwAs, space.Value)
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+func (w *yamlWriter) writeCommands(node *yamlNode) error {
+	if node.Null() {
+		return nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return yamlExpected(yamlCommands, node, "mapping")
+	}
+	for _, f := range node.Fields {
+		if err := w.writeCommand(f.Key, f.Value); err != nil {
+			return fmt.Errorf("%s: %w", f.Key, err)
+		}
+	}
+	return nil
+}
+
+func (w *yamlWriter) writeCommand(name string, node *yamlNode) error {
+	if !isIdentString(name) {
+		return fmt.Errorf("invalid command name")
+	}
+	var (
+		props  []string
+		deps   []string
+		script string
+		hidden bool
+	)
+	switch node.Kind {
+	case yaml.ScalarNode:
+		script = node.Value
+	case yaml.MappingNode:
+	default:
+		return yamlExpected(name, node, "mapping or script")
+	}
+	for _, f := range node.Fields {
+		if f.Value.Null() {
+			continue
+		}
+		var err error
+		switch f.Key {
+		case yamlHidden:
+			hidden = f.Value.Value == "true"
+		case yamlScript:
+			if f.Value.Kind != yaml.ScalarNode {
+				return yamlExpected(f.Key, f.Value, "string")
+			}
+			script = f.Value.Value
+		case yamlDeps:
+			deps, err = yamlRaw(f.Key, f.Value)
+		case yamlEnv:
+			var str string
+			if str, err = yamlValue(f.Key, f.Value); err == nil {
+				props = append(props, fmt.Sprintf("%s %s", kwExport, str))
+			}
+		case propArg:
+			var list []string
+			if list, err = yamlRaw(f.Key, f.Value); err == nil {
+				props = append(props, fmt.Sprintf("%s = %s", f.Key, strings.Join(list, " ")))
+			}
+		default:
+			var str string
+			if str, err = yamlValue(f.Key, f.Value); err == nil {
+				props = append(props, fmt.Sprintf("%s = %s", f.Key, str))
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(w)
+	if hidden {
+		fmt.Fprint(w, "%")
+	}
+	fmt.Fprint(w, name)
+	if len(props) > 0 {
+		fmt.Fprintln(w, "(")
+		for _, p := range props {
+			fmt.Fprintf(w, "\t%s,", p)
+			fmt.Fprintln(w)
+		}
+		fmt.Fprint(w, ")")
+	}
+	fmt.Fprint(w, ":")
+	if len(deps) > 0 {
+		fmt.Fprintf(w, " %s", strings.Join(deps, ", "))
+	}
+	fmt.Fprintln(w, " {")
+	for _, line := range strings.Split(strings.TrimRight(script, "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fmt.Fprintf(w, "\t%s", line)
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "}")
+	return nil
+}
+
+func convertYAML(node *yaml.Node, depth int) (*yamlNode, error) {
+	if depth > yamlMaxDepth {
+		return nil, fmt.Errorf("line %d: document nested too deeply", node.Line)
+	}
+	n := yamlNode{
+		Kind: node.Kind,
+		Line: node.Line,
+	}
+	switch node.Kind {
+	case 0:
+		n.Kind, n.null = yaml.ScalarNode, true
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return &yamlNode{Kind: yaml.ScalarNode, Line: node.Line, null: true}, nil
+		}
+		return convertYAML(node.Content[0], depth+1)
+	case yaml.AliasNode:
+		return convertYAML(node.Alias, depth+1)
+	case yaml.ScalarNode:
+		switch tag := node.ShortTag(); tag {
+		case "!!null":
+			n.null = true
+		case "!!str", "!!int", "!!float", "!!bool", "!!timestamp":
+		default:
+			return nil, fmt.Errorf("line %d: %s: unsupported tag", node.Line, tag)
+		}
+		n.Value = node.Value
+	case yaml.SequenceNode:
+		for _, c := range node.Content {
+			x, err := convertYAML(c, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			n.Items = append(n.Items, x)
+		}
+	case yaml.MappingNode:
+		fields, err := convertFields(node, depth)
+		if err != nil {
+			return nil, err
+		}
+		n.Fields = fields
+	}
+	return &n, nil
+}
+
+// convertFields gives the fields of a mapping in the order of the document.
+// The fields of the mappings given to a merge key are inserted in place of the
+// key unless they are set by the mapping itself or by a previous merge
+func convertFields(node *yaml.Node, depth int) ([]yamlField, error) {
+	var (
+		fields []yamlField
+		seen   = make(map[string]struct{})
+		empty  = struct{}{}
+	)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if k := node.Content[i]; k.Kind == yaml.ScalarNode && k.Value != yamlMerge {
+			seen[k.Value] = empty
+		}
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("line %d: scalar key expected", key.Line)
+		}
+		if key.Value != yamlMerge || key.Style != 0 {
+			x, err := convertYAML(value, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, yamlField{Key: key.Value, Value: x})
+			continue
+		}
+		list := []*yaml.Node{value}
+		if value.Kind == yaml.SequenceNode {
+			list = value.Content
+		}
+		for _, m := range list {
+			x, err := convertYAML(m, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if x.Kind != yaml.MappingNode {
+				return nil, fmt.Errorf("line %d: %s: mapping expected", m.Line, yamlMerge)
+			}
+			for _, f := range x.Fields {
+				if _, ok := seen[f.Key]; ok {
+					continue
+				}
+				seen[f.Key] = empty
+				fields = append(fields, f)
+			}
+		}
+	}
+	return fields, nil
+}
+
+func yamlValue(key string, node *yamlNode) (string, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if key == schedTime {
+			return yamlQuoteList(strings.Fields(node.Value))
+		}
+		if key == optValid {
+			return yamlRawScalar(key, node)
+		}
+		return quoteValue(node.Value, true)
+	case yaml.MappingNode:
+		var list []string
+		for _, f := range node.Fields {
+			if f.Value.Null() {
+				continue
+			}
+			if !isIdentString(f.Key) {
+				return "", fmt.Errorf("%s: invalid property name", f.Key)
+			}
+			str, err := yamlValue(f.Key, f.Value)
+			if err != nil {
+				return "", err
+			}
+			list = append(list, fmt.Sprintf("%s = %s,", f.Key, str))
+		}
+		return fmt.Sprintf("(%s)", strings.Join(list, " ")), nil
+	case yaml.SequenceNode:
+		var (
+			list    []string
+			objects bool
+		)
+		for _, n := range node.Items {
+			if n.Kind == yaml.SequenceNode {
+				return "", yamlExpected(key, n, "scalar or mapping")
+			}
+			objects = objects || n.Kind == yaml.MappingNode
+			str, err := yamlValue(key, n)
+			if err != nil {
+				return "", err
+			}
+			list = append(list, str)
+		}
+		if objects {
+			return strings.Join(list, ", "), nil
+		}
+		return strings.Join(list, " "), nil
+	default:
+		return "", yamlExpected(key, node, "value")
+	}
+}
+
+func yamlRaw(key string, node *yamlNode) ([]string, error) {
+	var list []string
+	switch node.Kind {
+	case yaml.ScalarNode:
+		str, err := yamlRawScalar(key, node)
+		if err != nil {
+			return nil, err
+		}
+		list = strings.Fields(str)
+	case yaml.SequenceNode:
+		for _, n := range node.Items {
+			str, err := yamlRawScalar(key, n)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, str)
+		}
+	default:
+		return nil, yamlExpected(key, node, "list")
+	}
+	return list, nil
+}
+
+func yamlRawScalar(key string, node *yamlNode) (string, error) {
+	if node.Kind != yaml.ScalarNode {
+		return "", yamlExpected(key, node, "string")
+	}
+	if strings.ContainsAny(node.Value, "\n{}") {
+		return "", fmt.Errorf("%s: %q: invalid value", key, node.Value)
+	}
+	return node.Value, nil
+}
+
+func yamlQuoteList(list []string) (string, error) {
+	for i := range list {
+		str, err := quoteValue(list[i], true)
+		if err != nil {
+			return "", err
+		}
+		list[i] = str
+	}
+	return strings.Join(list, " "), nil
+}
+
+func yamlExpected(key string, node *yamlNode, want string) error {
+	return fmt.Errorf("line %d: %s: %s expected", node.Line, key, want)
+}
+
+func isIdentString(str string) bool {
+	if str == "" {
+		return false
+	}
+	for i, r := range str {
+		if isLetter(r) || r == underscore || (i > 0 && (isIdent(r) || r == minus)) {
+			continue
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package maestro_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/midbel/maestro"
+)
+
+func TestLoadYAML(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "maestro.yaml")
+	if err := os.WriteFile(file, []byte(yamlFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mst := maestro.New()
+	if err := mst.Load(file); err != nil {
+		t.Fatalf("fail to load file: %s", err)
+	}
+	if mst.MetaExec.Default != "build" || len(mst.MetaExec.Before) != 1 {
+		t.Errorf("metas not decoded properly")
+	}
+	if _, err := mst.Commands.Lookup("prep"); err != nil {
+		t.Errorf("prep: command not found: %s", err)
+	}
+	cmd, err := mst.Commands.Lookup("build")
+	if err != nil {
+		t.Fatalf("build: command not found: %s", err)
+	}
+	if cmd.Short != "build the project" {
+		t.Errorf("short mismatched! got %q", cmd.Short)
+	}
+	if cmd.Retry != 3 || cmd.Timeout != 10*time.Second {
+		t.Errorf("retry/timeout mismatched! got %d/%s", cmd.Retry, cmd.Timeout)
+	}
+	if len(cmd.Deps) != 2 || cmd.Deps[0].Key() != "gen" || !cmd.Deps[1].Optional {
+		t.Errorf("dependencies mismatched! got %v", cmd.Deps)
+	}
+	if len(cmd.Options) != 2 || !cmd.Options[0].Flag || cmd.Options[1].Default != "fast" {
+		t.Errorf("options mismatched! got %v", cmd.Options)
+	}
+	if len(cmd.Schedules) != 1 || cmd.Schedules[0].Time != "0 2 * * *" || cmd.Schedules[0].Stdout.File != "logs/build.log" {
+		t.Errorf("schedules mismatched! got %v", cmd.Schedules)
+	}
+	want := []string{"echo build $VERSION", `echo "it's done"`}
+	if strings.Join(cmd.Lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("script mismatched! want %q, got %q", want, cmd.Lines)
+	}
+	script, err := cmd.Prepare()
+	if err != nil {
+		t.Fatalf("fail to prepare command: %s", err)
+	}
+	lines, err := script.Script(nil)
+	if err != nil || len(lines) == 0 || lines[0] != "echo build 1.0" {
+		t.Errorf("variable not resolved! got %q (%v)", lines, err)
+	}
+	gen, _ := mst.Commands.Lookup("gen")
+	if gen.Visible {
+		t.Errorf("gen should be hidden")
+	}
+
+	if err := os.WriteFile(file, []byte(yamlAnchors), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mst = maestro.New()
+	if err := mst.Load(file); err != nil {
+		t.Fatalf("fail to load file with anchors: %s", err)
+	}
+	release, err := mst.Commands.Lookup("release")
+	if err != nil {
+		t.Fatalf("release: command not found: %s", err)
+	}
+	if release.Retry != 1 || release.Timeout != 10*time.Second || strings.Join(release.Lines, "\n") != "echo build" {
+		t.Errorf("merged command mismatched! got %d/%s/%q", release.Retry, release.Timeout, release.Lines)
+	}
+	if err := os.WriteFile(file, []byte("commands:\n  build: !custom echo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := maestro.New().Load(file); err == nil || !strings.Contains(err.Error(), "unsupported tag") {
+		t.Errorf("custom tag should be rejected, got %v", err)
+	}
+}
+
+const yamlFile = `
+meta:
+  default: build
+  before: [prep]
+variables:
+  VERSION: 1.0
+commands:
+  prep: echo prep
+  gen:
+    hidden: true
+    script: echo gen
+  build:
+    short: build the project
+    retry: 3
+    timeout: 10s
+    deps: [gen, "?lint"]
+    options:
+      - short: v
+        long: verbose
+        flag: true
+      - long: mode
+        default: fast
+        check: oneof("fast" "slow")
+    schedule:
+      - time: 0 2 * * *
+        stdout: logs/build.log
+    script: |
+      echo build $VERSION
+      echo "it's done"
+`
+
+const yamlAnchors = `
+commands:
+  build: &build
+    retry: 3
+    timeout: 10s
+    script: echo build
+  release:
+    <<: *build
+    retry: 1
+`